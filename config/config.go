@@ -9,9 +9,12 @@ import (
 
 // GlobalConfig 全局配置
 type GlobalConfig struct {
-	Server  ServerConfig  `yaml:"server"`  // 服务器配置
-	AOF     AOFConfig     `yaml:"aof"`     // aof 配置
-	Cluster ClusterConfig `yaml:"cluster"` // 集群配置
+	Server   ServerConfig   `yaml:"server"`   // 服务器配置
+	AOF      AOFConfig      `yaml:"aof"`      // aof 配置
+	Expire   ExpireConfig   `yaml:"expire"`   // 过期回收配置
+	Memory   MemoryConfig   `yaml:"memory"`   // 内存配置
+	Encoding EncodingConfig `yaml:"encoding"` // 紧凑编码配置
+	Cluster  ClusterConfig  `yaml:"cluster"`  // 集群配置
 }
 
 // ServerConfig 服务器配置
@@ -28,6 +31,31 @@ type AOFConfig struct {
 	RewriteInterval int    `yaml:"aof_rewrite_interval"` // 每执行多少次 aof 操作后，进行一次重写
 }
 
+// ExpireConfig 过期回收配置
+type ExpireConfig struct {
+	Hz                int `yaml:"hz"`                   // 每秒执行主动过期回收周期的次数
+	KeysPerLoop       int `yaml:"keys_per_loop"`        // 每轮检查的 key 数量
+	CycleTimeBudgetMs int `yaml:"cycle_time_budget_ms"` // 单个回收周期的时间预算
+}
+
+// MemoryConfig 内存配置
+type MemoryConfig struct {
+	MaxMemory        string `yaml:"maxmemory"`         // 内存上限，支持 kb/mb/gb 单位，0 表示不限制
+	MaxMemoryPolicy  string `yaml:"maxmemory_policy"`  // 内存淘汰策略
+	MaxMemorySamples int    `yaml:"maxmemory_samples"` // 每次淘汰抽样的 key 数量
+	LFULogFactor     int    `yaml:"lfu_log_factor"`    // lfu 计数器对数增长因子
+	LFUDecayTime     int    `yaml:"lfu_decay_time"`    // lfu 计数器衰减周期，单位分钟
+}
+
+// EncodingConfig 紧凑编码配置，超出阈值后转换为哈希表编码
+type EncodingConfig struct {
+	SetMaxIntsetEntries    int `yaml:"set_max_intset_entries"`    // 整数集合编码的最大成员数
+	SetMaxListpackEntries  int `yaml:"set_max_listpack_entries"`  // 集合紧凑列表编码的最大成员数
+	SetMaxListpackValue    int `yaml:"set_max_listpack_value"`    // 集合紧凑列表编码的最大成员长度
+	HashMaxListpackEntries int `yaml:"hash_max_listpack_entries"` // hash 表紧凑列表编码的最大字段数
+	HashMaxListpackValue   int `yaml:"hash_max_listpack_value"`   // hash 表紧凑列表编码的最大字段及值长度
+}
+
 // ClusterConfig 集群配置
 type ClusterConfig struct {
 	IsEnabled    bool    `yaml:"is_enabled"`    // 是否启用集群
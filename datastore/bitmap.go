@@ -0,0 +1,542 @@
+package datastore
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	mstring "github.com/lovelydayss/goredis/datastruct/string"
+	def "github.com/lovelydayss/goredis/interface"
+)
+
+// bitmap 类型指令
+
+// SetBit SETBIT key offset value，返回该位原来的值，位图长度不足时以 0 填充
+func (k *KVStore) SetBit(cmd *def.Command) def.Reply {
+	args := cmd.Args
+	if len(args) != 3 {
+		return def.NewSyntaxErrReply()
+	}
+
+	offset, err := parseBitOffset(args[1])
+	if err != nil {
+		return def.NewErrReply(err.Error())
+	}
+
+	val := string(args[2])
+	if val != "0" && val != "1" {
+		return def.NewErrReply("ERR bit is not an integer or out of range")
+	}
+
+	key := string(args[0])
+	bmap, err := k.getAsBitmap(key)
+	if err != nil {
+		return def.NewErrReply(err.Error())
+	}
+
+	if bmap == nil {
+		bmap = k.putAsBitmap(key)
+	}
+	old := bmap.SetBit(offset, val[0]-'0')
+
+	k.persister.PersistCmd(cmd.Ctx, cmd.GetCmd()) // 持久化
+	return def.NewIntReply(int64(old))
+}
+
+// GetBit GETBIT key offset，超出位图长度或 key 不存在时返回 0
+func (k *KVStore) GetBit(cmd *def.Command) def.Reply {
+	args := cmd.Args
+	if len(args) != 2 {
+		return def.NewSyntaxErrReply()
+	}
+
+	offset, err := parseBitOffset(args[1])
+	if err != nil {
+		return def.NewErrReply(err.Error())
+	}
+
+	bmap, err := k.getAsBitmap(string(args[0]))
+	if err != nil {
+		return def.NewErrReply(err.Error())
+	}
+
+	if bmap == nil {
+		return def.NewIntReply(0)
+	}
+	return def.NewIntReply(int64(bmap.GetBit(offset)))
+}
+
+// BitCount BITCOUNT key [start end [BYTE | BIT]]
+// 统计区间内 1 的位数，区间默认以字节为单位，支持负数下标，越界部分被截断
+func (k *KVStore) BitCount(cmd *def.Command) def.Reply {
+	args := cmd.Args
+	if len(args) != 1 && len(args) != 3 && len(args) != 4 {
+		return def.NewSyntaxErrReply()
+	}
+
+	var (
+		start, end int64
+		ranged     = len(args) > 1
+		bitUnit    bool
+	)
+	if ranged {
+		var err1, err2 error
+		start, err1 = strconv.ParseInt(string(args[1]), 10, 64)
+		end, err2 = strconv.ParseInt(string(args[2]), 10, 64)
+		if err1 != nil || err2 != nil {
+			return def.NewErrReply("ERR value is not an integer or out of range")
+		}
+	}
+	if len(args) == 4 {
+		switch strings.ToLower(string(args[3])) {
+		case "byte":
+		case "bit":
+			bitUnit = true
+		default:
+			return def.NewSyntaxErrReply()
+		}
+	}
+
+	bmap, err := k.getAsBitmap(string(args[0]))
+	if err != nil {
+		return def.NewErrReply(err.Error())
+	}
+
+	if bmap == nil || bmap.Len() == 0 {
+		return def.NewIntReply(0)
+	}
+
+	if !ranged {
+		return def.NewIntReply(bmap.Count(0, bmap.Len()*8-1))
+	}
+
+	size := bmap.Len()
+	if bitUnit {
+		size *= 8
+	}
+	start, end, ok := normalizeBitRange(start, end, size)
+	if !ok {
+		return def.NewIntReply(0)
+	}
+	if !bitUnit {
+		start, end = start*8, end*8+7
+	}
+	return def.NewIntReply(bmap.Count(start, end))
+}
+
+// BitOp BITOP AND | OR | XOR | NOT destkey key [key ...]
+// 按位运算结果写入 destkey，长度较短及不存在的 key 以 0 填充，返回结果的字节数，结果为空时删除 destkey
+func (k *KVStore) BitOp(cmd *def.Command) def.Reply {
+	args := cmd.Args
+	if len(args) < 3 {
+		return def.NewSyntaxErrReply()
+	}
+
+	op := strings.ToLower(string(args[0]))
+	switch op {
+	case "and", "or", "xor":
+	case "not":
+		if len(args) != 3 {
+			return def.NewErrReply("ERR BITOP NOT must be called with a single source key.")
+		}
+	default:
+		return def.NewSyntaxErrReply()
+	}
+
+	srcs := make([][]byte, 0, len(args)-2)
+	var size int
+	for _, arg := range args[2:] {
+		bmap, err := k.getAsBitmap(string(arg))
+		if err != nil {
+			return def.NewErrReply(err.Error())
+		}
+
+		var src []byte
+		if bmap != nil {
+			src = bmap.Bytes()
+		}
+		srcs = append(srcs, src)
+		size = max(size, len(src))
+	}
+
+	res := make([]byte, size)
+	for i := range res {
+		res[i] = byteAt(srcs[0], i)
+		for _, src := range srcs[1:] {
+			switch op {
+			case "and":
+				res[i] &= byteAt(src, i)
+			case "or":
+				res[i] |= byteAt(src, i)
+			case "xor":
+				res[i] ^= byteAt(src, i)
+			}
+		}
+		if op == "not" {
+			res[i] = ^res[i]
+		}
+	}
+
+	dst := string(args[1])
+	k.remove(dst)
+	if size > 0 {
+		k.data.Put(dst, mstring.NewString(dst, string(res)))
+	}
+
+	k.persister.PersistCmd(cmd.Ctx, cmd.GetCmd()) // 持久化
+	return def.NewIntReply(int64(size))
+}
+
+// byteAt 获取第 i 个字节，越界时为 0
+func byteAt(data []byte, i int) byte {
+	if i < len(data) {
+		return data[i]
+	}
+	return 0
+}
+
+// BitPos BITPOS key bit [start [end [BYTE | BIT]]]
+// 返回区间内首个值为 bit 的位，不存在时返回 -1；
+// 查找 0 且未指定 end 时，位图之后的位视为 0
+func (k *KVStore) BitPos(cmd *def.Command) def.Reply {
+	args := cmd.Args
+	if len(args) < 2 || len(args) > 5 {
+		return def.NewSyntaxErrReply()
+	}
+
+	bit := string(args[1])
+	if bit != "0" && bit != "1" {
+		return def.NewErrReply("ERR The bit argument must be 1 or 0.")
+	}
+
+	var (
+		start, end int64 = 0, -1
+		bitUnit    bool
+		err1, err2 error
+	)
+	if len(args) > 2 {
+		start, err1 = strconv.ParseInt(string(args[2]), 10, 64)
+	}
+	if len(args) > 3 {
+		end, err2 = strconv.ParseInt(string(args[3]), 10, 64)
+	}
+	if err1 != nil || err2 != nil {
+		return def.NewErrReply("ERR value is not an integer or out of range")
+	}
+	if len(args) == 5 {
+		switch strings.ToLower(string(args[4])) {
+		case "byte":
+		case "bit":
+			bitUnit = true
+		default:
+			return def.NewSyntaxErrReply()
+		}
+	}
+
+	bmap, err := k.getAsBitmap(string(args[0]))
+	if err != nil {
+		return def.NewErrReply(err.Error())
+	}
+
+	if bmap == nil || bmap.Len() == 0 {
+		if bit == "1" {
+			return def.NewIntReply(-1)
+		}
+		return def.NewIntReply(0)
+	}
+
+	size := bmap.Len()
+	if bitUnit {
+		size *= 8
+	}
+	start, end, ok := normalizeBitRange(start, end, size)
+	if !ok {
+		return def.NewIntReply(-1)
+	}
+	if !bitUnit {
+		start, end = start*8, end*8+7
+	}
+
+	pos := bmap.Pos(bit[0]-'0', start, end)
+	if pos < 0 && bit == "0" && len(args) < 4 {
+		pos = bmap.Len() * 8
+	}
+	return def.NewIntReply(pos)
+}
+
+// bitfield 溢出处理方式
+const (
+	overflowWrap = iota // 回绕
+	overflowSat         // 饱和到最大值或最小值
+	overflowFail        // 不执行并返回 nil
+)
+
+// bitfieldOp BITFIELD 的单个子操作
+type bitfieldOp struct {
+	op       string // get、set 或 incrby
+	signed   bool
+	width    int
+	offset   int64
+	value    int64 // set 的值或 incrby 的增量
+	overflow int
+}
+
+// BitField BITFIELD key [GET type offset] [SET type offset value] [INCRBY type offset increment] [OVERFLOW WRAP | SAT | FAIL] ...
+// 将位图视为定长整数数组读写，type 为 i1 至 i64 或 u1 至 u63，offset 以 # 开头时乘以类型的位数
+// 依次返回每个子操作的结果，OVERFLOW FAIL 下溢出的子操作不执行并返回 nil
+func (k *KVStore) BitField(cmd *def.Command) def.Reply {
+	args := cmd.Args
+	if len(args) < 1 {
+		return def.NewSyntaxErrReply()
+	}
+
+	ops, err := parseBitfieldOps(args[1:])
+	if err != nil {
+		return def.NewErrReply(err.Error())
+	}
+
+	key := string(args[0])
+	bmap, err := k.getAsBitmap(key)
+	if err != nil {
+		return def.NewErrReply(err.Error())
+	}
+
+	var (
+		replies = make([]def.Reply, 0, len(ops))
+		written bool
+	)
+	for _, op := range ops {
+		var old uint64
+		if bmap != nil {
+			old = bmap.GetBits(op.offset, op.width)
+		}
+
+		if op.op == "get" {
+			replies = append(replies, def.NewIntReply(bitfieldValue(old, op.signed, op.width)))
+			continue
+		}
+
+		var (
+			value uint64
+			ok    bool
+		)
+		if op.op == "set" {
+			value, ok = fitBitfield(op.value, 0, op)
+		} else {
+			value, ok = fitBitfield(bitfieldValue(old, op.signed, op.width), op.value, op)
+		}
+		if !ok {
+			replies = append(replies, def.NewNillReply())
+			continue
+		}
+
+		if bmap == nil {
+			bmap = k.putAsBitmap(key)
+		}
+		bmap.SetBits(op.offset, op.width, value)
+		written = true
+
+		if op.op == "set" {
+			replies = append(replies, def.NewIntReply(bitfieldValue(old, op.signed, op.width)))
+		} else {
+			replies = append(replies, def.NewIntReply(bitfieldValue(value, op.signed, op.width)))
+		}
+	}
+
+	if written {
+		k.persister.PersistCmd(cmd.Ctx, cmd.GetCmd()) // 持久化
+	}
+	return def.NewArrayReply(replies)
+}
+
+// parseBitfieldOps 解析 BITFIELD 的子操作
+func parseBitfieldOps(args [][]byte) ([]*bitfieldOp, error) {
+	var (
+		ops      []*bitfieldOp
+		overflow = overflowWrap
+	)
+	for i := 0; i < len(args); i++ {
+		name := strings.ToLower(string(args[i]))
+		switch name {
+		case "overflow":
+			if i+1 >= len(args) {
+				return nil, def.NewSyntaxErrReply()
+			}
+			switch strings.ToLower(string(args[i+1])) {
+			case "wrap":
+				overflow = overflowWrap
+			case "sat":
+				overflow = overflowSat
+			case "fail":
+				overflow = overflowFail
+			default:
+				return nil, def.NewErrReply("ERR Invalid OVERFLOW type specified")
+			}
+			i++
+			continue
+		case "get":
+			if i+2 >= len(args) {
+				return nil, def.NewSyntaxErrReply()
+			}
+		case "set", "incrby":
+			if i+3 >= len(args) {
+				return nil, def.NewSyntaxErrReply()
+			}
+		default:
+			return nil, def.NewSyntaxErrReply()
+		}
+
+		op := &bitfieldOp{op: name, overflow: overflow}
+		if err := op.parseType(args[i+1]); err != nil {
+			return nil, err
+		}
+		if err := op.parseOffset(args[i+2]); err != nil {
+			return nil, err
+		}
+		i += 2
+
+		if name != "get" {
+			value, err := strconv.ParseInt(string(args[i+1]), 10, 64)
+			if err != nil {
+				return nil, def.NewErrReply("ERR value is not an integer or out of range")
+			}
+			op.value = value
+			i++
+		}
+		ops = append(ops, op)
+	}
+	return ops, nil
+}
+
+// parseType 解析 i1 至 i64 或 u1 至 u63
+func (op *bitfieldOp) parseType(raw []byte) error {
+	invalid := def.NewErrReply("ERR Invalid bitfield type. Use something like i16 u8. Note that u64 is not supported but i64 is.")
+	if len(raw) < 2 {
+		return invalid
+	}
+
+	switch raw[0] {
+	case 'i', 'I':
+		op.signed = true
+	case 'u', 'U':
+	default:
+		return invalid
+	}
+
+	width, err := strconv.Atoi(string(raw[1:]))
+	if err != nil || width < 1 || (op.signed && width > 64) || (!op.signed && width > 63) {
+		return invalid
+	}
+	op.width = width
+	return nil
+}
+
+// parseOffset 解析位偏移量，以 # 开头时乘以类型的位数，需先解析类型
+func (op *bitfieldOp) parseOffset(raw []byte) error {
+	invalid := def.NewErrReply("ERR bit offset is not an integer or out of range")
+	multiply := len(raw) > 0 && raw[0] == '#'
+	if multiply {
+		raw = raw[1:]
+	}
+
+	offset, err := strconv.ParseInt(string(raw), 10, 64)
+	if err != nil || offset < 0 {
+		return invalid
+	}
+	if multiply {
+		if offset > maxStringSize*8/int64(op.width) {
+			return invalid
+		}
+		offset *= int64(op.width)
+	}
+	if offset+int64(op.width) > maxStringSize*8 {
+		return invalid
+	}
+	op.offset = offset
+	return nil
+}
+
+// bitfieldValue 将读取的 width 位解释为有符号或无符号整数
+func bitfieldValue(raw uint64, signed bool, width int) int64 {
+	if signed && width < 64 && raw&(1<<(width-1)) != 0 {
+		raw |= ^uint64(0) << width
+	}
+	return int64(raw)
+}
+
+// fitBitfield 计算 value + incr 按子操作的类型及溢出处理方式写入的值，与 redis 一致
+// OVERFLOW FAIL 下溢出时返回 false
+func fitBitfield(value, incr int64, op *bitfieldOp) (uint64, bool) {
+	var (
+		overflow, underflow bool
+		sat                 uint64
+	)
+	if op.signed {
+		max := int64(math.MaxInt64)
+		if op.width < 64 {
+			max = 1<<(op.width-1) - 1
+		}
+		min := -max - 1
+		maxIncr, minIncr := max-value, min-value
+
+		switch {
+		case value > max || (op.width != 64 && incr > maxIncr) || (value >= 0 && incr > 0 && incr > maxIncr):
+			overflow, sat = true, uint64(max)
+		case value < min || (op.width != 64 && incr < minIncr) || (value < 0 && incr < 0 && incr < minIncr):
+			underflow, sat = true, uint64(min)
+		}
+	} else {
+		// 无符号类型的值按 uint64 解释，负数视为溢出
+		max := uint64(1)<<op.width - 1
+		uvalue := uint64(value)
+		maxIncr, minIncr := int64(max-uvalue), -value
+
+		switch {
+		case uvalue > max || incr > maxIncr:
+			overflow, sat = true, max
+		case incr < 0 && incr < minIncr:
+			underflow, sat = true, 0
+		}
+	}
+
+	mask := ^uint64(0)
+	if op.width < 64 {
+		mask = 1<<op.width - 1
+	}
+	if !overflow && !underflow || op.overflow == overflowWrap {
+		return (uint64(value) + uint64(incr)) & mask, true
+	}
+	if op.overflow == overflowSat {
+		return sat & mask, true
+	}
+	return 0, false
+}
+
+// parseBitOffset 解析位偏移量，上限与字符串长度上限一致
+func parseBitOffset(raw []byte) (int64, error) {
+	offset, err := strconv.ParseInt(string(raw), 10, 64)
+	if err != nil || offset < 0 || offset >= maxStringSize*8 {
+		return 0, def.NewErrReply("ERR bit offset is not an integer or out of range")
+	}
+	return offset, nil
+}
+
+// normalizeBitRange 规范化闭区间 [start, end]，负数从末尾开始计数，越界部分被截断，区间为空时返回 false
+func normalizeBitRange(start, end, size int64) (int64, int64, bool) {
+	if start < 0 {
+		start += size
+	}
+	if end < 0 {
+		end += size
+	}
+	if start < 0 {
+		start = 0
+	}
+	if end < 0 {
+		end = 0
+	}
+	if end >= size {
+		end = size - 1
+	}
+	return start, end, start <= end
+}
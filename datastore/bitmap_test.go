@@ -0,0 +1,201 @@
+package datastore
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	def "github.com/lovelydayss/goredis/interface"
+)
+
+// TestBitmapStringInterop 位图与字符串互通，字符串指令可读写位图
+func TestBitmapStringInterop(t *testing.T) {
+	tests := []replyCase{
+		{"get bitmap", []string{"setbit bm 1 1"}, "get bm", "$1\r\n@\r\n"},
+		{"getset bitmap", []string{"setbit bm 1 1"}, "getset bm x", "$1\r\n@\r\n"},
+		{"set get bitmap", []string{"setbit bm 1 1"}, "set bm y get", "$1\r\n@\r\n"},
+		{"set nx get bitmap", []string{"setbit bm 1 1"}, "set bm z nx get", "$1\r\n@\r\n"},
+		{"set nx get keeps bitmap", []string{"setbit bm 1 1", "set bm z nx get"}, "getbit bm 1", ":1\r\n"},
+		{"getset then getbit", []string{"setbit bm 1 1", "getset bm a"}, "getbit bm 1", ":1\r\n"},
+		{"strlen bitmap", []string{"setbit bm 17 1"}, "strlen bm", ":3\r\n"},
+		{"setrange then getbit", []string{"setbit bm 0 1", "setrange bm 1 @"}, "getbit bm 9", ":1\r\n"},
+		{"append to bitmap", []string{"setbit bm 1 1"}, "append bm a", ":2\r\n"},
+		{"setbit on string", []string{"set s a"}, "setbit s 6 1", ":0\r\n"},
+		{"bitcount string", []string{"set s foobar"}, "bitcount s", ":26\r\n"},
+		{"setbit wrong type", []string{"rpush l a"}, "setbit l 0 1", "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"},
+	}
+
+	runReplyCases(t, tests)
+}
+
+// TestBitmapConformance 位图指令与 redis 行为的一致性
+func TestBitmapConformance(t *testing.T) {
+	// 第 0、7、9、17 位为 1，即字节 0x81 0x40 0x40
+	bits := []string{"setbit b 0 1", "setbit b 7 1", "setbit b 9 1", "setbit b 17 1"}
+	const ones = "bitfield ones set u24 0 16777215" // 3 个字节全部为 1
+
+	tests := []replyCase{
+		// SETBIT / GETBIT，偏移量 0 对应首个字节的最高位
+		{"setbit returns old", []string{"setbit b 7 1"}, "setbit b 7 0", ":1\r\n"},
+		{"setbit msb first", []string{"setbit b 1 1"}, "get b", "$1\r\n@\r\n"},
+		{"setbit grows with zeros", []string{"setbit b 17 1"}, "get b", "$3\r\n\x00\x00@\r\n"},
+		{"setbit invalid value", nil, "setbit b 0 2", "-ERR bit is not an integer or out of range\r\n"},
+		{"setbit negative offset", nil, "setbit b -1 1", "-ERR bit offset is not an integer or out of range\r\n"},
+		{"setbit wrong type", []string{"rpush l a"}, "setbit l 0 1", "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"},
+		{"getbit", []string{"setbit b 9 1"}, "getbit b 9", ":1\r\n"},
+		{"getbit past end", []string{"setbit b 0 1"}, "getbit b 100", ":0\r\n"},
+		{"getbit missing key", nil, "getbit b 0", ":0\r\n"},
+
+		// BITCOUNT 区间默认以字节为单位，支持负数下标
+		{"bitcount", bits, "bitcount b", ":4\r\n"},
+		{"bitcount byte range", bits, "bitcount b 1 1", ":1\r\n"},
+		{"bitcount negative bytes", bits, "bitcount b -2 -1", ":2\r\n"},
+		{"bitcount bit range", bits, "bitcount b 5 10 bit", ":2\r\n"},
+		{"bitcount start after end", bits, "bitcount b 3 1", ":0\r\n"},
+		{"bitcount missing end", bits, "bitcount b 0", "-Err syntax error\r\n"},
+		{"bitcount missing key", nil, "bitcount b", ":0\r\n"},
+		{"bitcount string", []string{"set s foobar"}, "bitcount s 1 1", ":6\r\n"},
+		{"bitcount string bit range", []string{"set s foobar"}, "bitcount s 5 30 bit", ":17\r\n"},
+
+		// BITPOS 未指定 end 时视为右侧以 0 无限填充，指定 end 时仅在区间内查找
+		{"bitpos first zero", []string{"setbit b 0 1", "setbit b 1 1", "setbit b 9 1"}, "bitpos b 0", ":2\r\n"},
+		{"bitpos zero past end of string", []string{ones}, "bitpos ones 0", ":24\r\n"},
+		{"bitpos zero past start only", []string{ones}, "bitpos ones 0 1", ":24\r\n"},
+		{"bitpos zero with end", []string{ones}, "bitpos ones 0 0 -1", ":-1\r\n"},
+		{"bitpos zero with bit end", []string{ones}, "bitpos ones 0 0 23 bit", ":-1\r\n"},
+		{"bitpos one in bit range", []string{"setbit b 10 1"}, "bitpos b 1 9 15 bit", ":10\r\n"},
+		{"bitpos one not found", []string{"setbit b 10 0"}, "bitpos b 1", ":-1\r\n"},
+		{"bitpos missing key zero", nil, "bitpos none 0", ":0\r\n"},
+		{"bitpos missing key one", nil, "bitpos none 1", ":-1\r\n"},
+		{"bitpos start after end", []string{"setbit b 10 1"}, "bitpos b 1 2 1", ":-1\r\n"},
+
+		// BITOP 长度不同时较短的源按 0 填充，结果长度为最长源的长度
+		{"bitop or pads shorter", []string{"set a ab", "set b a", "bitop or dst a b"}, "get dst", "$2\r\nab\r\n"},
+		{"bitop and pads shorter", []string{"set a ab", "set b a", "bitop and dst a b"}, "get dst", "$2\r\na\x00\r\n"},
+		{"bitop xor with missing", []string{"set a ab", "bitop xor dst a none"}, "get dst", "$2\r\nab\r\n"},
+		{"bitop returns length", []string{"set a ab", "set b a"}, "bitop and dst a b", ":2\r\n"},
+		{"bitop not", []string{ones, "bitop not dst ones"}, "get dst", "$3\r\n\x00\x00\x00\r\n"},
+		{"bitop all missing deletes dst", []string{"set dst x", "bitop or dst none"}, "exists dst", ":0\r\n"},
+		{"bitop not with two keys", nil, "bitop not dst a b", "-ERR BITOP NOT must be called with a single source key.\r\n"},
+
+		// BITFIELD 类型边界
+		{"bitfield u64 rejected", nil, "bitfield b get u64 0", "-ERR Invalid bitfield type. Use something like i16 u8. Note that u64 is not supported but i64 is.\r\n"},
+		{"bitfield i64 set and get", []string{"bitfield b set i64 0 -9223372036854775808"}, "bitfield b get i64 0", "*1\r\n:-9223372036854775808\r\n"},
+		{"bitfield u63 max", []string{"bitfield b set u63 0 9223372036854775807"}, "bitfield b get u63 0", "*1\r\n:9223372036854775807\r\n"},
+		{"bitfield negative set for u8 wraps", []string{"bitfield b set u8 0 -1"}, "bitfield b get u8 0", "*1\r\n:255\r\n"},
+		{"bitfield negative set for u8 fails", nil, "bitfield b overflow fail set u8 0 -1", "*1\r\n$-1\r\n"},
+		{"bitfield set returns old", []string{"bitfield b set u8 #1 200"}, "bitfield b set u8 8 1 get u8 8", "*2\r\n:200\r\n:1\r\n"},
+		{"bitfield incrby sat", []string{"bitfield b set i8 0 120"}, "bitfield b overflow sat incrby i8 0 100", "*1\r\n:127\r\n"},
+		{"bitfield fail keeps value", []string{"bitfield b set i8 0 120", "bitfield b overflow fail incrby i8 0 100"}, "bitfield b get i8 0", "*1\r\n:120\r\n"},
+	}
+
+	runReplyCases(t, tests)
+}
+
+// TestFitBitfield 溢出处理方式与类型边界的组合
+func TestFitBitfield(t *testing.T) {
+	const (
+		maxU63 = int64(math.MaxInt64)
+		fail   = "fail"
+	)
+
+	tests := []struct {
+		name     string
+		signed   bool
+		width    int
+		overflow int
+		value    int64
+		incr     int64
+		want     interface{} // 写入后按类型解释的值，溢出失败时为 fail
+	}{
+		{"i8 no overflow", true, 8, overflowFail, 100, 27, int64(127)},
+		{"i8 wrap over max", true, 8, overflowWrap, 127, 1, int64(-128)},
+		{"i8 sat over max", true, 8, overflowSat, 127, 1, int64(127)},
+		{"i8 fail over max", true, 8, overflowFail, 127, 1, fail},
+		{"i8 wrap under min", true, 8, overflowWrap, -128, -1, int64(127)},
+		{"i8 sat under min", true, 8, overflowSat, -128, -1, int64(-128)},
+		{"i8 fail under min", true, 8, overflowFail, -128, -1, fail},
+		{"i8 set out of range", true, 8, overflowSat, 300, 0, int64(127)},
+		{"i1 wrap", true, 1, overflowWrap, 0, 1, int64(-1)},
+		{"i64 wrap over max", true, 64, overflowWrap, math.MaxInt64, 1, int64(math.MinInt64)},
+		{"i64 sat over max", true, 64, overflowSat, math.MaxInt64, 1, int64(math.MaxInt64)},
+		{"i64 fail over max", true, 64, overflowFail, math.MaxInt64, 1, fail},
+		{"i64 wrap under min", true, 64, overflowWrap, math.MinInt64, -1, int64(math.MaxInt64)},
+		{"i64 sat under min", true, 64, overflowSat, math.MinInt64, -1, int64(math.MinInt64)},
+		{"i64 fail under min", true, 64, overflowFail, math.MinInt64, -1, fail},
+		{"i64 min plus max", true, 64, overflowFail, math.MinInt64, math.MaxInt64, int64(-1)},
+		{"i64 max plus min", true, 64, overflowFail, math.MaxInt64, math.MinInt64, int64(-1)},
+		{"u8 no overflow", false, 8, overflowFail, 200, 55, int64(255)},
+		{"u8 wrap over max", false, 8, overflowWrap, 255, 1, int64(0)},
+		{"u8 sat over max", false, 8, overflowSat, 10, 300, int64(255)},
+		{"u8 fail over max", false, 8, overflowFail, 255, 1, fail},
+		{"u8 wrap under zero", false, 8, overflowWrap, 0, -1, int64(255)},
+		{"u8 sat under zero", false, 8, overflowSat, 0, -1, int64(0)},
+		{"u8 fail under zero", false, 8, overflowFail, 0, -1, fail},
+		{"u8 negative set wraps", false, 8, overflowWrap, -1, 0, int64(255)},
+		{"u8 negative set saturates to max", false, 8, overflowSat, -1, 0, int64(255)},
+		{"u8 negative set fails", false, 8, overflowFail, -1, 0, fail},
+		{"u63 max", false, 63, overflowFail, maxU63, 0, maxU63},
+		{"u63 wrap over max", false, 63, overflowWrap, maxU63, 1, int64(0)},
+		{"u63 sat over max", false, 63, overflowSat, maxU63, 1, maxU63},
+		{"u63 sat under zero", false, 63, overflowSat, 1, math.MinInt64, int64(0)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			op := &bitfieldOp{signed: tt.signed, width: tt.width, overflow: tt.overflow}
+			raw, ok := fitBitfield(tt.value, tt.incr, op)
+
+			var got interface{} = fail
+			if ok {
+				got = bitfieldValue(raw, tt.signed, tt.width)
+			}
+			if got != tt.want {
+				t.Errorf("fitBitfield(%d, %d) => %v, want %v", tt.value, tt.incr, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestBitmapPersistence 位图指令持久化后重放的结果一致
+func TestBitmapPersistence(t *testing.T) {
+	s := newTestStore(t)
+	for _, line := range []string{"setbit b 0 1", "setbit b 100 1", "setbit b 0 0", "setbit b 9 1"} {
+		s.exec(line)
+	}
+
+	assertReplayed(t, s, "bitcount b", "getbit b 0", "getbit b 9", "getbit b 100", "bitcount b 0 -1 bit")
+}
+
+// TestBitmapRewrite 位图以字符串存储，重写为单条 set 指令，重放后逐位一致
+func TestBitmapRewrite(t *testing.T) {
+	persister := &recordPersister{}
+	store := NewKVStore(persister)
+	executor := NewDBExecutor(store)
+	t.Cleanup(executor.Close)
+
+	s := &testStore{executor: executor, persister: persister}
+	for _, offset := range []string{"0", "7", "9", "100", "1023"} {
+		s.exec("setbit bm " + offset + " 1")
+	}
+	want := s.exec("get bm")
+	executor.Close()
+
+	var cmds [][][]byte
+	store.ForEach(func(key string, adapter def.CmdAdapter, expireAt *time.Time) {
+		cmds = append(cmds, adapter.ToCmd())
+	})
+	if len(cmds) != 1 || string(cmds[0][0]) != string(def.CmdTypeSet) {
+		t.Fatalf("rewrite => %q, want a single set", cmds)
+	}
+
+	replayed := newTestStore(t)
+	replayed.do(def.SetLoadingPattern(context.Background()), cmds[0])
+	if got := replayed.exec("get bm"); got != want {
+		t.Errorf("replayed get bm => %q, want %q", got, want)
+	}
+	if got := replayed.exec("bitcount bm"); got != ":5\r\n" {
+		t.Errorf("replayed bitcount bm => %q, want %q", got, ":5\r\n")
+	}
+}
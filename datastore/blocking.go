@@ -0,0 +1,194 @@
+package datastore
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"time"
+
+	def "github.com/lovelydayss/goredis/interface"
+)
+
+// 阻塞指令的挂起与唤醒
+// 阻塞指令暂无数据可处理时返回 blockedReply，执行器据此将指令挂起在其等待的 key 上，
+// 执行器单协程不会因此阻塞。写指令向 key 推入数据时标记 key 就绪，执行器在每笔指令处理后
+// 按挂起顺序重新执行等待就绪 key 的指令，直至指令得到结果、超时或连接断开
+
+// blockedReply 阻塞指令暂无数据可处理时的返回值
+type blockedReply struct {
+	keys    []string
+	timeout time.Duration // 0 表示永久阻塞
+}
+
+// newBlockedReply 初始化
+func newBlockedReply(keys []string, timeout time.Duration) *blockedReply {
+	return &blockedReply{keys: keys, timeout: timeout}
+}
+
+// ToBytes 无法挂起时（如 aof 加载阶段）与超时一致，返回 nil 数组
+func (b *blockedReply) ToBytes() []byte {
+	return def.NewNillMultiBulkReply().ToBytes()
+}
+
+// parseTimeout 解析秒级超时时间，支持小数
+// float64 无法精确表示 math.MaxInt64，转换为 time.Duration 前以 >= 比较，避免溢出为负数后立即超时
+func parseTimeout(raw []byte) (time.Duration, error) {
+	v, err := strconv.ParseFloat(string(raw), 64)
+	if err != nil || math.IsNaN(v) || math.IsInf(v, 0) || v*float64(time.Second) >= math.MaxInt64 {
+		return 0, def.NewErrReply("ERR timeout is not a float or out of range")
+	}
+	if v < 0 {
+		return 0, def.NewErrReply("ERR timeout is negative")
+	}
+	return time.Duration(v * float64(time.Second)), nil
+}
+
+// blockedClient 被挂起的指令
+type blockedClient struct {
+	cmd      *def.Command
+	keys     []string
+	released bool          // 是否已被唤醒，仅在执行器协程中读写
+	stops    []func() bool // 撤销超时及连接断开的监听
+}
+
+// blockedClients 执行器挂起的指令，按 key 组织为先进先出队列
+type blockedClients struct {
+	queues  map[string][]*blockedClient
+	unblock chan *blockedClient // 超时或连接断开的指令
+}
+
+// newBlockedClients 初始化
+func newBlockedClients() *blockedClients {
+	return &blockedClients{
+		queues:  make(map[string][]*blockedClient),
+		unblock: make(chan *blockedClient),
+	}
+}
+
+// block 挂起指令，超时或连接断开时经 unblock 通知执行器
+func (e *DBExecutor) block(cmd *def.Command, reply *blockedReply) {
+	// aof 加载阶段及连接已断开时不挂起
+	if def.IsLoadingPattern(cmd.Ctx) || cmd.Ctx.Err() != nil {
+		cmd.Receiver <- reply
+		return
+	}
+
+	c := &blockedClient{cmd: cmd}
+	seen := make(map[string]struct{}, len(reply.keys))
+	for _, key := range reply.keys {
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		c.keys = append(c.keys, key)
+		e.blocked.queues[key] = append(e.blocked.queues[key], c)
+	}
+
+	notify := func() {
+		select {
+		case e.blocked.unblock <- c:
+		case <-e.ctx.Done():
+		}
+	}
+	c.stops = append(c.stops, context.AfterFunc(cmd.Ctx, notify))
+	if reply.timeout > 0 {
+		c.stops = append(c.stops, time.AfterFunc(reply.timeout, notify).Stop)
+	}
+}
+
+// release 将指令从所有等待队列中移除
+func (e *DBExecutor) release(c *blockedClient) {
+	c.released = true
+	for _, stop := range c.stops {
+		stop()
+	}
+
+	for _, key := range c.keys {
+		queue := e.blocked.queues[key]
+		for i := range queue {
+			if queue[i] == c {
+				queue = append(queue[:i], queue[i+1:]...)
+				break
+			}
+		}
+		if len(queue) == 0 {
+			delete(e.blocked.queues, key)
+		} else {
+			e.blocked.queues[key] = queue
+		}
+	}
+}
+
+// timeout 超时或连接断开，返回 nil 数组
+func (e *DBExecutor) timeout(c *blockedClient) {
+	if c.released {
+		return
+	}
+	e.release(c)
+	c.cmd.Receiver <- def.NewNillMultiBulkReply()
+}
+
+// serveReadyKeys 按挂起顺序重新执行等待就绪 key 的指令
+// 被唤醒的指令可能再次推入数据（如 BLMOVE），因此循环处理直至没有新的就绪 key
+func (e *DBExecutor) serveReadyKeys() {
+	for {
+		keys := e.dataStore.ReadyKeys()
+		if len(keys) == 0 {
+			return
+		}
+
+		for _, key := range keys {
+			// 队列在唤醒过程中会被修改，先复制一份
+			queue := append([]*blockedClient{}, e.blocked.queues[key]...)
+			for _, c := range queue {
+				if c.released {
+					continue
+				}
+
+				// 连接已断开但 unblock 通知尚未处理，直接释放，避免弹出的数据无人接收而丢失
+				if c.cmd.Ctx.Err() != nil {
+					e.timeout(c)
+					continue
+				}
+
+				reply := e.cmdHandlers[c.cmd.Cmd](c.cmd)
+				if _, ok := reply.(*blockedReply); ok {
+					continue
+				}
+
+				e.release(c)
+				c.cmd.Receiver <- reply
+				e.dataStore.Touch()
+			}
+		}
+	}
+}
+
+// releaseAll 执行器关闭时释放所有挂起的指令
+func (e *DBExecutor) releaseAll() {
+	for _, queue := range e.blocked.queues {
+		for _, c := range queue {
+			if c.released {
+				continue
+			}
+			c.released = true
+			for _, stop := range c.stops {
+				stop()
+			}
+			c.cmd.Receiver <- def.NewErrReply("ERR server is shutting down")
+		}
+	}
+	e.blocked.queues = make(map[string][]*blockedClient)
+}
+
+// signalReady 标记 key 写入了数据，执行器据此唤醒等待该 key 的阻塞指令
+func (k *KVStore) signalReady(key string) {
+	k.ready = append(k.ready, key)
+}
+
+// ReadyKeys 获取并清空本笔指令标记的就绪 key
+func (k *KVStore) ReadyKeys() []string {
+	keys := k.ready
+	k.ready = nil
+	return keys
+}
@@ -0,0 +1,144 @@
+package datastore
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	def "github.com/lovelydayss/goredis/interface"
+)
+
+// async 异步执行阻塞指令，执行器接收指令后返回
+// 执行器单协程按序处理，此后投递的指令执行时该指令已被挂起
+func (s *testStore) async(ctx context.Context, line string) <-chan string {
+	var cmdLine [][]byte
+	for _, field := range strings.Fields(line) {
+		cmdLine = append(cmdLine, []byte(field))
+	}
+	cmd := &def.Command{
+		Ctx:      ctx,
+		Cmd:      def.CmdType(strings.ToLower(string(cmdLine[0]))),
+		Args:     cmdLine[1:],
+		Receiver: make(chan def.Reply, 1),
+	}
+	s.executor.Entrance() <- cmd
+
+	ch := make(chan string, 1)
+	go func() { ch <- string((<-cmd.Receiver).ToBytes()) }()
+	return ch
+}
+
+func expectReply(t *testing.T, ch <-chan string, want string) {
+	t.Helper()
+	select {
+	case got := <-ch:
+		if got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("blocked client not released, want %q", want)
+	}
+}
+
+// TestParseTimeout 超时时间的解析边界
+func TestParseTimeout(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want time.Duration
+		err  string
+	}{
+		{"0", 0, ""},
+		{"0.05", 50 * time.Millisecond, ""},
+		{"1", time.Second, ""},
+		{"9223372036", 9223372036 * time.Second, ""},
+		{"-1", 0, "ERR timeout is negative"},
+		{"x", 0, "ERR timeout is not a float or out of range"},
+		{"nan", 0, "ERR timeout is not a float or out of range"},
+		{"inf", 0, "ERR timeout is not a float or out of range"},
+		{"9223372036.854775807", 0, "ERR timeout is not a float or out of range"},
+		{"1e300", 0, "ERR timeout is not a float or out of range"},
+	}
+
+	for _, tt := range tests {
+		got, err := parseTimeout([]byte(tt.raw))
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("parseTimeout(%s) => %v, %v, want error %q", tt.raw, got, err, tt.err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("parseTimeout(%s) => %v, %v, want %v", tt.raw, got, err, tt.want)
+		}
+	}
+}
+
+// TestBlockingPop 阻塞弹出按挂起顺序唤醒，超时及连接断开时返回 nil 数组
+func TestBlockingPop(t *testing.T) {
+	s := newTestStore(t)
+
+	first := s.async(context.Background(), "blpop q 0")
+	second := s.async(context.Background(), "brpop other q 0")
+	s.exec("rpush q a b c")
+	expectReply(t, first, "*2\r\n$1\r\nq\r\n$1\r\na\r\n")
+	expectReply(t, second, "*2\r\n$1\r\nq\r\n$1\r\nc\r\n")
+
+	expectReply(t, s.async(context.Background(), "blpop empty 0.05"), "*-1\r\n")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	dropped := s.async(ctx, "blpop empty 0")
+	cancel()
+	expectReply(t, dropped, "*-1\r\n")
+
+	// 连接断开后写入的数据不会被已断开的指令弹出
+	ctx, cancel = context.WithCancel(context.Background())
+	dropped = s.async(ctx, "blpop gone 0")
+	cancel()
+	s.exec("rpush gone a")
+	expectReply(t, dropped, "*-1\r\n")
+	if got := s.exec("lpop gone"); got != "$1\r\na\r\n" {
+		t.Errorf("lpop gone => %q, want %q", got, "$1\r\na\r\n")
+	}
+
+	// 被唤醒的 BLMOVE 推入的数据继续唤醒等待目标 key 的指令
+	moved := s.async(context.Background(), "blmove src dst left right 0")
+	popped := s.async(context.Background(), "blpop dst 0")
+	s.exec("lpush src x")
+	expectReply(t, moved, "$1\r\nx\r\n")
+	expectReply(t, popped, "*2\r\n$3\r\ndst\r\n$1\r\nx\r\n")
+
+	replayed := s.replay(t)
+	if got, want := replayed.exec("lrange q 0 -1"), s.exec("lrange q 0 -1"); got != want {
+		t.Errorf("replayed lrange => %q, want %q", got, want)
+	}
+}
+
+// TestBlockingZPop 阻塞弹出有序集合成员，ZADD 写入时唤醒，连接断开时撤销
+func TestBlockingZPop(t *testing.T) {
+	s := newTestStore(t)
+
+	first := s.async(context.Background(), "bzpopmin jobs 0")
+	second := s.async(context.Background(), "bzpopmax other jobs 0")
+	s.exec("zadd jobs 3 c 1 a 2 b")
+	expectReply(t, first, "*3\r\n$4\r\njobs\r\n$1\r\na\r\n$1\r\n1\r\n")
+	expectReply(t, second, "*3\r\n$4\r\njobs\r\n$1\r\nc\r\n$1\r\n3\r\n")
+
+	multi := s.async(context.Background(), "bzmpop 0 2 empty jobs min count 5")
+	expectReply(t, multi, "*2\r\n$4\r\njobs\r\n*1\r\n*2\r\n$1\r\nb\r\n$1\r\n2\r\n")
+
+	expectReply(t, s.async(context.Background(), "bzpopmin empty 0.05"), "*-1\r\n")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	dropped := s.async(ctx, "bzpopmax empty 0")
+	cancel()
+	expectReply(t, dropped, "*-1\r\n")
+
+	if got := s.exec("exists jobs"); got != ":0\r\n" {
+		t.Errorf("exists jobs => %q, want %q", got, ":0\r\n")
+	}
+	replayed := s.replay(t)
+	if got := replayed.exec("exists jobs"); got != ":0\r\n" {
+		t.Errorf("replayed exists jobs => %q, want %q", got, ":0\r\n")
+	}
+}
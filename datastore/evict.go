@@ -0,0 +1,358 @@
+package datastore
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+
+	"github.com/lovelydayss/goredis/config"
+	def "github.com/lovelydayss/goredis/interface"
+	"github.com/lovelydayss/goredis/lib"
+)
+
+// 内存淘汰策略
+const (
+	policyNoEviction     = "noeviction"
+	policyAllKeysLRU     = "allkeys-lru"
+	policyVolatileLRU    = "volatile-lru"
+	policyAllKeysLFU     = "allkeys-lfu"
+	policyVolatileLFU    = "volatile-lfu"
+	policyAllKeysRandom  = "allkeys-random"
+	policyVolatileRandom = "volatile-random"
+	policyVolatileTTL    = "volatile-ttl"
+)
+
+const (
+	evictionPoolSize = 16 // 淘汰候选池大小
+	lfuInitVal       = 5  // 新 key 的 lfu 计数器初值，避免刚写入即被淘汰
+)
+
+// evictionConf 内存淘汰参数
+type evictionConf struct {
+	maxMemory    int64
+	policy       string
+	samples      int
+	lfuLogFactor int
+	lfuDecayTime int64 // 单位分钟
+}
+
+// newEvictionConf 读取配置，未配置的参数取 redis 默认值
+func newEvictionConf() evictionConf {
+	conf := config.Config.Memory
+	c := evictionConf{
+		maxMemory:    parseMemory(conf.MaxMemory),
+		policy:       policyNoEviction,
+		samples:      5,
+		lfuLogFactor: 10,
+		lfuDecayTime: 1,
+	}
+
+	switch policy := strings.ToLower(conf.MaxMemoryPolicy); policy {
+	case policyAllKeysLRU, policyVolatileLRU, policyAllKeysLFU, policyVolatileLFU,
+		policyAllKeysRandom, policyVolatileRandom, policyVolatileTTL:
+		c.policy = policy
+	}
+	if conf.MaxMemorySamples > 0 {
+		c.samples = conf.MaxMemorySamples
+	}
+	if conf.LFULogFactor > 0 {
+		c.lfuLogFactor = conf.LFULogFactor
+	}
+	if conf.LFUDecayTime > 0 {
+		c.lfuDecayTime = int64(conf.LFUDecayTime)
+	}
+	return c
+}
+
+// parseMemory 解析内存大小配置，如 100mb、1gb，解析失败视为不限制
+func parseMemory(raw string) int64 {
+	raw = strings.ToLower(strings.TrimSpace(raw))
+	units := []struct {
+		suffix string
+		base   int64
+	}{
+		{"gb", 1 << 30}, {"mb", 1 << 20}, {"kb", 1 << 10},
+		{"g", 1000 * 1000 * 1000}, {"m", 1000 * 1000}, {"k", 1000}, {"b", 1},
+	}
+
+	base := int64(1)
+	for _, unit := range units {
+		if strings.HasSuffix(raw, unit.suffix) {
+			raw, base = strings.TrimSuffix(raw, unit.suffix), unit.base
+			break
+		}
+	}
+
+	v, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || v <= 0 {
+		return 0
+	}
+	return v * base
+}
+
+// evictionStats 内存淘汰统计信息
+type evictionStats struct {
+	evictedKeys int64 // 累计淘汰的 key 数量
+}
+
+// accessMeta key 的访问信息，用于 lru/lfu 淘汰
+type accessMeta struct {
+	accessTime  int64 // 最近访问时间，unix 毫秒级时间戳
+	lfuCounter  uint8 // 对数访问计数器
+	lfuDecrTime int64 // 计数器最近衰减时间，unix 分钟级时间戳
+	memory      int64 // 最近一次访问后 value 的内存占用
+}
+
+// Touch 更新本笔指令访问过的 key 的访问信息及内存占用，由执行器在每笔指令处理后调用
+// 对 value 的修改都发生在 lookup 之后，因此据此即可维护内存占用的累计值
+func (k *KVStore) Touch() {
+	for _, key := range k.touched {
+		k.touch(key)
+	}
+	k.touched = k.touched[:0]
+}
+
+// touch 更新单个 key 的访问信息
+func (k *KVStore) touch(key string) {
+	v, ok := k.data.Get(key)
+	if !ok {
+		return
+	}
+
+	now := lib.TimeNow()
+	meta, ok := k.access[key]
+	if !ok {
+		meta = accessMeta{lfuCounter: lfuInitVal, lfuDecrTime: now.Unix() / 60}
+		k.memStats.overhead += keyMemory(key)
+	}
+
+	meta.accessTime = now.UnixMilli()
+	meta.lfuCounter = k.lfuLogIncr(k.lfuDecr(&meta))
+	meta.lfuDecrTime = now.Unix() / 60
+
+	size := valueMemory(v)
+	k.memStats.dataset += size - meta.memory
+	meta.memory = size
+	k.access[key] = meta
+
+	if used := k.usedMemory(); used > k.memStats.peak {
+		k.memStats.peak = used
+	}
+}
+
+// resize 更新 key 的内存占用，不记录访问，用于 key 在指令之外被修改的场景
+func (k *KVStore) resize(key string) {
+	v, ok := k.data.Get(key)
+	meta, tracked := k.access[key]
+	if !ok || !tracked {
+		return
+	}
+
+	size := valueMemory(v)
+	k.memStats.dataset += size - meta.memory
+	meta.memory = size
+	k.access[key] = meta
+}
+
+// untrack 删除 key 的访问信息并扣减内存占用
+func (k *KVStore) untrack(key string) {
+	meta, ok := k.access[key]
+	if !ok {
+		return
+	}
+	k.memStats.dataset -= meta.memory
+	k.memStats.overhead -= keyMemory(key)
+	delete(k.access, key)
+}
+
+// lfuDecr 按距上次衰减经过的周期数衰减计数器
+func (k *KVStore) lfuDecr(meta *accessMeta) uint8 {
+	periods := (lib.TimeNow().Unix()/60 - meta.lfuDecrTime) / k.eviction.lfuDecayTime
+	if periods <= 0 {
+		return meta.lfuCounter
+	}
+	if periods >= int64(meta.lfuCounter) {
+		return 0
+	}
+	return meta.lfuCounter - uint8(periods)
+}
+
+// lfuLogIncr 对数递增计数器，计数越大递增概率越低
+func (k *KVStore) lfuLogIncr(counter uint8) uint8 {
+	if counter == math.MaxUint8 {
+		return counter
+	}
+
+	baseVal := float64(counter) - lfuInitVal
+	if baseVal < 0 {
+		baseVal = 0
+	}
+	if rand.Float64() < 1/(baseVal*float64(k.eviction.lfuLogFactor)+1) {
+		counter++
+	}
+	return counter
+}
+
+// FreeMemoryIfNeeded 内存超出上限时按淘汰策略释放内存
+// 返回 false 表示无法释放足够内存，写指令需要被拒绝
+func (k *KVStore) FreeMemoryIfNeeded() bool {
+	if k.eviction.maxMemory <= 0 {
+		return true
+	}
+
+	used := k.usedMemory()
+	if used <= k.eviction.maxMemory {
+		return true
+	}
+
+	if k.eviction.policy == policyNoEviction {
+		return false
+	}
+
+	var (
+		toFree = used - k.eviction.maxMemory
+		freed  int64
+	)
+	for freed < toFree {
+		key, ok := k.evictionCandidate()
+		if !ok {
+			break
+		}
+		freed += k.evict(key)
+	}
+	return freed >= toFree
+}
+
+// evict 淘汰 key，返回释放内存的估算值
+// 淘汰以 del 指令持久化，保证重放结果一致
+func (k *KVStore) evict(key string) int64 {
+	before := k.usedMemory()
+	if !k.remove(key) {
+		return 0
+	}
+	k.persister.PersistCmd(context.Background(), [][]byte{[]byte(def.CmdTypeDel), []byte(key)})
+
+	k.evictionStats.evictedKeys++
+	return before - k.usedMemory()
+}
+
+// evictionCandidate 按淘汰策略选出待淘汰的 key
+func (k *KVStore) evictionCandidate() (string, bool) {
+	switch k.eviction.policy {
+	case policyAllKeysRandom:
+		return k.data.RandomKey()
+	case policyVolatileRandom:
+		for key := range k.expiredAt {
+			return key, true
+		}
+		return "", false
+	}
+
+	// 候选池中保留抽样得到的最佳候选，分值越高越优先淘汰
+	for {
+		k.populateEvictionPool()
+		if len(k.evictionPool) == 0 {
+			return "", false
+		}
+
+		for len(k.evictionPool) > 0 {
+			best := k.evictionPool[len(k.evictionPool)-1]
+			k.evictionPool = k.evictionPool[:len(k.evictionPool)-1]
+			if _, ok := k.data.Get(best.key); ok {
+				return best.key, true
+			}
+		}
+	}
+}
+
+// evictionPoolEntry 淘汰候选
+type evictionPoolEntry struct {
+	key   string
+	score uint64 // 淘汰分值，越高越优先淘汰
+}
+
+// populateEvictionPool 抽样 key 并按淘汰分值插入候选池，候选池按分值升序排列
+func (k *KVStore) populateEvictionPool() {
+	var (
+		sampled  int
+		volatile = strings.HasPrefix(k.eviction.policy, "volatile")
+	)
+
+	sample := func(key string) bool {
+		if sampled >= k.eviction.samples {
+			return false
+		}
+		sampled++
+		k.insertEvictionPool(key, k.evictionScore(key))
+		return true
+	}
+
+	if volatile {
+		for key := range k.expiredAt {
+			if !sample(key) {
+				break
+			}
+		}
+		return
+	}
+
+	for i := 0; i < k.eviction.samples && i < k.data.Len(); i++ {
+		key, _ := k.data.RandomKey()
+		sample(key)
+	}
+}
+
+// evictionScore 计算淘汰分值
+func (k *KVStore) evictionScore(key string) uint64 {
+	switch k.eviction.policy {
+	case policyVolatileTTL:
+		// 越早过期越优先淘汰
+		return math.MaxUint64 - uint64(k.expiredAt[key].UnixMilli())
+	case policyAllKeysLFU, policyVolatileLFU:
+		// 访问频次越低越优先淘汰
+		meta, ok := k.access[key]
+		if !ok {
+			return math.MaxUint8
+		}
+		return math.MaxUint8 - uint64(k.lfuDecr(&meta))
+	default:
+		// 空闲时间越长越优先淘汰
+		meta := k.access[key]
+		return uint64(lib.TimeNow().UnixMilli() - meta.accessTime)
+	}
+}
+
+// insertEvictionPool 按分值插入候选池，候选池已满时淘汰分值最低的候选
+func (k *KVStore) insertEvictionPool(key string, score uint64) {
+	pool := k.evictionPool
+	for i := range pool {
+		if pool[i].key == key {
+			pool = append(pool[:i], pool[i+1:]...)
+			break
+		}
+	}
+
+	i := 0
+	for i < len(pool) && pool[i].score < score {
+		i++
+	}
+
+	if len(pool) >= evictionPoolSize {
+		if i == 0 {
+			return
+		}
+		// 丢弃分值最低的候选
+		copy(pool, pool[1:i])
+		i--
+		pool[i] = evictionPoolEntry{key: key, score: score}
+		k.evictionPool = pool
+		return
+	}
+
+	pool = append(pool, evictionPoolEntry{})
+	copy(pool[i+1:], pool[i:])
+	pool[i] = evictionPoolEntry{key: key, score: score}
+	k.evictionPool = pool
+}
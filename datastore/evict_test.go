@@ -0,0 +1,132 @@
+package datastore
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newEvictStore 按指定策略及内存上限初始化测试实例
+// 候选抽样数量不小于 key 数量时 volatile 策略遍历全部候选，淘汰结果确定
+func newEvictStore(t *testing.T, policy string, maxMemory int64) *testStore {
+	persister := &recordPersister{}
+	store := NewKVStore(persister).(*KVStore)
+	store.eviction.policy = policy
+	store.eviction.maxMemory = maxMemory
+	store.eviction.samples = 16
+	executor := NewDBExecutor(store)
+	t.Cleanup(executor.Close)
+	return &testStore{executor: executor, persister: persister}
+}
+
+// keyUsage 单个 "kN v" 且带过期时间的字符串 key 的内存占用
+func keyUsage(t *testing.T) int64 {
+	s := newEvictStore(t, policyNoEviction, math.MaxInt64)
+	s.exec("set k0 v ex 1000")
+	for _, line := range strings.Split(s.exec("info memory"), "\r\n") {
+		if v, ok := strings.CutPrefix(line, "used_memory:"); ok {
+			usage, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				t.Fatalf("used_memory: %v", err)
+			}
+			return usage
+		}
+	}
+	t.Fatal("info memory without used_memory")
+	return 0
+}
+
+// fill 依次写入 k0 至 k<n-1>，间隔 2ms 保证访问时间各不相同
+func (s *testStore) fill(n int, ttl func(i int) int) {
+	for i := 0; i < n; i++ {
+		s.exec("set k" + strconv.Itoa(i) + " v ex " + strconv.Itoa(ttl(i)))
+		time.Sleep(2 * time.Millisecond)
+	}
+}
+
+func (s *testStore) expectExists(t *testing.T, keys string, want int) {
+	t.Helper()
+	if got := s.exec("exists " + keys); got != ":"+strconv.Itoa(want)+"\r\n" {
+		t.Errorf("exists %s => %q, want %d", keys, got, want)
+	}
+}
+
+// TestEviction 内存超出上限时按策略淘汰，恰好释放足够的内存
+func TestEviction(t *testing.T) {
+	usage := keyUsage(t)
+	sameTTL := func(int) int { return 1000 }
+
+	t.Run("noeviction rejects writes", func(t *testing.T) {
+		s := newEvictStore(t, policyNoEviction, 5*usage-1)
+		s.fill(5, sameTTL)
+		if got := s.exec("set new v"); !strings.HasPrefix(got, "-OOM ") {
+			t.Errorf("set over maxmemory => %q, want OOM error", got)
+		}
+		if got := s.exec("get k0"); got != "$1\r\nv\r\n" {
+			t.Errorf("get over maxmemory => %q", got)
+		}
+		s.exec("del k0")
+		if got := s.exec("set new v"); strings.HasPrefix(got, "-OOM ") {
+			t.Errorf("set after del => %q, want accepted", got)
+		}
+	})
+
+	t.Run("volatile-lru evicts the idlest key", func(t *testing.T) {
+		s := newEvictStore(t, policyVolatileLRU, 5*usage-1)
+		s.fill(5, sameTTL)
+		s.exec("get k0")
+		s.exec("set new v")
+		s.expectExists(t, "k1", 0)
+		s.expectExists(t, "k0 k2 k3 k4 new", 5)
+		assertReplayed(t, s, "exists k0 k1 k2 k3 k4 new")
+	})
+
+	t.Run("volatile-lfu keeps the hot key", func(t *testing.T) {
+		s := newEvictStore(t, policyVolatileLFU, 5*usage-1)
+		s.fill(5, sameTTL)
+		for i := 0; i < 300; i++ {
+			s.exec("get k0")
+		}
+		s.exec("set new v")
+		s.expectExists(t, "k0 new", 2)
+		s.expectExists(t, "k1 k2 k3 k4", 3)
+	})
+
+	t.Run("volatile-ttl evicts the nearest deadline", func(t *testing.T) {
+		s := newEvictStore(t, policyVolatileTTL, 5*usage-1)
+		s.fill(5, func(i int) int { return 1000 + (i+3)%5 })
+		s.exec("set new v")
+		s.expectExists(t, "k2", 0)
+		s.expectExists(t, "k0 k1 k3 k4 new", 5)
+	})
+
+	t.Run("volatile policy without ttl keys", func(t *testing.T) {
+		s := newEvictStore(t, policyVolatileLRU, usage)
+		s.exec("set a v")
+		s.exec("set b v")
+		if got := s.exec("set c v"); !strings.HasPrefix(got, "-OOM ") {
+			t.Errorf("set without volatile keys => %q, want OOM error", got)
+		}
+	})
+
+	t.Run("allkeys-random stays bounded", func(t *testing.T) {
+		s := newEvictStore(t, policyAllKeysRandom, 10*usage)
+		for i := 0; i < 100; i++ {
+			s.exec("set k" + strconv.Itoa(i) + " v")
+		}
+		var evicted, keys int
+		for _, line := range strings.Split(s.exec("info stats keyspace"), "\r\n") {
+			if v, ok := strings.CutPrefix(line, "evicted_keys:"); ok {
+				evicted, _ = strconv.Atoi(v)
+			}
+			if v, ok := strings.CutPrefix(line, "db0:keys="); ok {
+				keys, _ = strconv.Atoi(strings.Split(v, ",")[0])
+			}
+		}
+		if evicted == 0 || evicted+keys != 100 {
+			t.Errorf("evicted %d keys, %d left, want some evicted and none lost", evicted, keys)
+		}
+	})
+}
@@ -15,11 +15,13 @@ type DBExecutor struct {
 	ctx    context.Context
 	cancel context.CancelFunc
 	ch     chan *def.Command
+	done   chan struct{} // 执行协程退出信号
 
 	cmdHandlers map[def.CmdType]func(*def.Command) def.Reply // 指令名称到处理函数映射
 	dataStore   def.DataStore                                // 数据引擎层结构
+	blocked     *blockedClients                              // 挂起的阻塞指令
 
-	gcTicker *time.Ticker // 垃圾回收定时器
+	gcTicker *time.Ticker // 主动过期回收定时器
 }
 
 // NewDBExecutor 初始化
@@ -28,41 +30,166 @@ func NewDBExecutor(dataStore def.DataStore) def.Executor {
 	e := DBExecutor{
 		dataStore: dataStore,
 		ch:        make(chan *def.Command),
+		done:      make(chan struct{}),
 		ctx:       ctx,
 		cancel:    cancel,
-		gcTicker:  time.NewTicker(time.Minute),
+		blocked:   newBlockedClients(),
+		gcTicker:  time.NewTicker(time.Second / time.Duration(activeExpireHz())),
 	}
 	e.cmdHandlers = map[def.CmdType]func(*def.Command) def.Reply{
+		def.CmdTypeInfo:   e.dataStore.Info,
+		def.CmdTypeMemory: e.dataStore.Memory,
+
+		// key
+		def.CmdTypeDel:      e.dataStore.Del,
+		def.CmdTypeExists:   e.dataStore.Exists,
+		def.CmdTypeType:     e.dataStore.Type,
+		def.CmdTypeUnlink:   e.dataStore.Unlink,
+		def.CmdTypeRename:   e.dataStore.Rename,
+		def.CmdTypeRenameNX: e.dataStore.RenameNX,
+		def.CmdTypeScan:     e.dataStore.Scan,
+		def.CmdTypeKeys:     e.dataStore.Keys,
+		def.CmdTypeObject:   e.dataStore.Object,
+
 		def.CmdTypeExpire:   e.dataStore.Expire,
 		def.CmdTypeExpireAt: e.dataStore.ExpireAt,
 
+		def.CmdTypePExpire:   e.dataStore.PExpire,
+		def.CmdTypePExpireAt: e.dataStore.PExpireAt,
+
+		def.CmdTypeTTL:         e.dataStore.TTL,
+		def.CmdTypePTTL:        e.dataStore.PTTL,
+		def.CmdTypeExpireTime:  e.dataStore.ExpireTime,
+		def.CmdTypePExpireTime: e.dataStore.PExpireTime,
+		def.CmdTypePersist:     e.dataStore.Persist,
+
 		// string
-		def.CmdTypeGet:  e.dataStore.Get,
-		def.CmdTypeSet:  e.dataStore.Set,
-		def.CmdTypeMGet: e.dataStore.MGet,
-		def.CmdTypeMSet: e.dataStore.MSet,
+		def.CmdTypeGet:         e.dataStore.Get,
+		def.CmdTypeSet:         e.dataStore.Set,
+		def.CmdTypeMGet:        e.dataStore.MGet,
+		def.CmdTypeMSet:        e.dataStore.MSet,
+		def.CmdTypeMSetNX:      e.dataStore.MSetNX,
+		def.CmdTypeSetNX:       e.dataStore.SetNX,
+		def.CmdTypeSetEX:       e.dataStore.SetEX,
+		def.CmdTypePSetEX:      e.dataStore.PSetEX,
+		def.CmdTypeGetSet:      e.dataStore.GetSet,
+		def.CmdTypeGetDel:      e.dataStore.GetDel,
+		def.CmdTypeGetEX:       e.dataStore.GetEX,
+		def.CmdTypeIncr:        e.dataStore.Incr,
+		def.CmdTypeDecr:        e.dataStore.Decr,
+		def.CmdTypeIncrBy:      e.dataStore.IncrBy,
+		def.CmdTypeDecrBy:      e.dataStore.DecrBy,
+		def.CmdTypeIncrByFloat: e.dataStore.IncrByFloat,
+		def.CmdTypeAppend:      e.dataStore.Append,
+		def.CmdTypeStrLen:      e.dataStore.StrLen,
+		def.CmdTypeGetRange:    e.dataStore.GetRange,
+		def.CmdTypeSetRange:    e.dataStore.SetRange,
 
 		// list
-		def.CmdTypeLPush:  e.dataStore.LPush,
-		def.CmdTypeLPop:   e.dataStore.LPop,
-		def.CmdTypeRPush:  e.dataStore.RPush,
-		def.CmdTypeRPop:   e.dataStore.RPop,
-		def.CmdTypeLRange: e.dataStore.LRange,
+		def.CmdTypeLPush:     e.dataStore.LPush,
+		def.CmdTypeLPop:      e.dataStore.LPop,
+		def.CmdTypeRPush:     e.dataStore.RPush,
+		def.CmdTypeRPop:      e.dataStore.RPop,
+		def.CmdTypeLRange:    e.dataStore.LRange,
+		def.CmdTypeLLen:      e.dataStore.LLen,
+		def.CmdTypeLIndex:    e.dataStore.LIndex,
+		def.CmdTypeLSet:      e.dataStore.LSet,
+		def.CmdTypeLInsert:   e.dataStore.LInsert,
+		def.CmdTypeLRem:      e.dataStore.LRem,
+		def.CmdTypeLTrim:     e.dataStore.LTrim,
+		def.CmdTypeLPos:      e.dataStore.LPos,
+		def.CmdTypeLMove:     e.dataStore.LMove,
+		def.CmdTypeRPopLPush: e.dataStore.RPopLPush,
+		def.CmdTypeBLPop:     e.dataStore.BLPop,
+		def.CmdTypeBRPop:     e.dataStore.BRPop,
+		def.CmdTypeBLMove:    e.dataStore.BLMove,
+		def.CmdTypeLMPop:     e.dataStore.LMPop,
+		def.CmdTypeBLMPop:    e.dataStore.BLMPop,
 
 		// set
-		def.CmdTypeSAdd:      e.dataStore.SAdd,
-		def.CmdTypeSIsMember: e.dataStore.SIsMember,
-		def.CmdTypeSRem:      e.dataStore.SRem,
+		def.CmdTypeSAdd:        e.dataStore.SAdd,
+		def.CmdTypeSIsMember:   e.dataStore.SIsMember,
+		def.CmdTypeSRem:        e.dataStore.SRem,
+		def.CmdTypeSScan:       e.dataStore.SScan,
+		def.CmdTypeSMIsMember:  e.dataStore.SMIsMember,
+		def.CmdTypeSMembers:    e.dataStore.SMembers,
+		def.CmdTypeSCard:       e.dataStore.SCard,
+		def.CmdTypeSMove:       e.dataStore.SMove,
+		def.CmdTypeSPop:        e.dataStore.SPop,
+		def.CmdTypeSRandMember: e.dataStore.SRandMember,
+		def.CmdTypeSInter:      e.dataStore.SInter,
+		def.CmdTypeSUnion:      e.dataStore.SUnion,
+		def.CmdTypeSDiff:       e.dataStore.SDiff,
+		def.CmdTypeSInterStore: e.dataStore.SInterStore,
+		def.CmdTypeSUnionStore: e.dataStore.SUnionStore,
+		def.CmdTypeSDiffStore:  e.dataStore.SDiffStore,
+		def.CmdTypeSInterCard:  e.dataStore.SInterCard,
 
 		// hash
-		def.CmdTypeHSet: e.dataStore.HSet,
-		def.CmdTypeHGet: e.dataStore.HGet,
-		def.CmdTypeHDel: e.dataStore.HDel,
+		def.CmdTypeHSet:         e.dataStore.HSet,
+		def.CmdTypeHGet:         e.dataStore.HGet,
+		def.CmdTypeHDel:         e.dataStore.HDel,
+		def.CmdTypeHScan:        e.dataStore.HScan,
+		def.CmdTypeHSetNX:       e.dataStore.HSetNX,
+		def.CmdTypeHMGet:        e.dataStore.HMGet,
+		def.CmdTypeHExists:      e.dataStore.HExists,
+		def.CmdTypeHLen:         e.dataStore.HLen,
+		def.CmdTypeHStrLen:      e.dataStore.HStrLen,
+		def.CmdTypeHGetAll:      e.dataStore.HGetAll,
+		def.CmdTypeHKeys:        e.dataStore.HKeys,
+		def.CmdTypeHVals:        e.dataStore.HVals,
+		def.CmdTypeHIncrBy:      e.dataStore.HIncrBy,
+		def.CmdTypeHIncrByFloat: e.dataStore.HIncrByFloat,
+		def.CmdTypeHRandField:   e.dataStore.HRandField,
+		def.CmdTypeHExpire:      e.dataStore.HExpire,
+		def.CmdTypeHPExpire:     e.dataStore.HPExpire,
+		def.CmdTypeHExpireAt:    e.dataStore.HExpireAt,
+		def.CmdTypeHPExpireAt:   e.dataStore.HPExpireAt,
+		def.CmdTypeHTTL:         e.dataStore.HTTL,
+		def.CmdTypeHPTTL:        e.dataStore.HPTTL,
+		def.CmdTypeHExpireTime:  e.dataStore.HExpireTime,
+		def.CmdTypeHPExpireTime: e.dataStore.HPExpireTime,
+		def.CmdTypeHPersist:     e.dataStore.HPersist,
 
 		// sorted set
-		def.CmdTypeZAdd:          e.dataStore.ZAdd,
-		def.CmdTypeZRangeByScore: e.dataStore.ZRangeByScore,
-		def.CmdTypeZRem:          e.dataStore.ZRem,
+		def.CmdTypeZAdd:             e.dataStore.ZAdd,
+		def.CmdTypeZRangeByScore:    e.dataStore.ZRangeByScore,
+		def.CmdTypeZRem:             e.dataStore.ZRem,
+		def.CmdTypeZScan:            e.dataStore.ZScan,
+		def.CmdTypeZRange:           e.dataStore.ZRange,
+		def.CmdTypeZCard:            e.dataStore.ZCard,
+		def.CmdTypeZScore:           e.dataStore.ZScore,
+		def.CmdTypeZMScore:          e.dataStore.ZMScore,
+		def.CmdTypeZCount:           e.dataStore.ZCount,
+		def.CmdTypeZRank:            e.dataStore.ZRank,
+		def.CmdTypeZRevRank:         e.dataStore.ZRevRank,
+		def.CmdTypeZRevRangeByScore: e.dataStore.ZRevRangeByScore,
+		def.CmdTypeZRangeByLex:      e.dataStore.ZRangeByLex,
+		def.CmdTypeZLexCount:        e.dataStore.ZLexCount,
+		def.CmdTypeZRemRangeByRank:  e.dataStore.ZRemRangeByRank,
+		def.CmdTypeZRemRangeByScore: e.dataStore.ZRemRangeByScore,
+		def.CmdTypeZRemRangeByLex:   e.dataStore.ZRemRangeByLex,
+		def.CmdTypeZIncrBy:          e.dataStore.ZIncrBy,
+		def.CmdTypeZPopMin:          e.dataStore.ZPopMin,
+		def.CmdTypeZPopMax:          e.dataStore.ZPopMax,
+		def.CmdTypeZMPop:            e.dataStore.ZMPop,
+		def.CmdTypeZUnion:           e.dataStore.ZUnion,
+		def.CmdTypeZInter:           e.dataStore.ZInter,
+		def.CmdTypeZDiff:            e.dataStore.ZDiff,
+		def.CmdTypeZUnionStore:      e.dataStore.ZUnionStore,
+		def.CmdTypeZInterStore:      e.dataStore.ZInterStore,
+		def.CmdTypeZDiffStore:       e.dataStore.ZDiffStore,
+		def.CmdTypeBZPopMin:         e.dataStore.BZPopMin,
+		def.CmdTypeBZPopMax:         e.dataStore.BZPopMax,
+		def.CmdTypeBZMPop:           e.dataStore.BZMPop,
+
+		// bitmap
+		def.CmdTypeBitmapSet:   e.dataStore.SetBit,
+		def.CmdTypeBitmapGet:   e.dataStore.GetBit,
+		def.CmdTypeBitmapCount: e.dataStore.BitCount,
+		def.CmdTypeBitmapOp:    e.dataStore.BitOp,
+		def.CmdTypeBitmapPos:   e.dataStore.BitPos,
+		def.CmdTypeBitmapField: e.dataStore.BitField,
 	}
 
 	pool.Submit(e.run)
@@ -81,21 +208,30 @@ func (e *DBExecutor) ValidCommand(cmd def.CmdType) bool {
 }
 
 // Close 关闭执行器
+// 等待执行协程退出后返回，此后不再有主动过期回收修改数据
 func (e *DBExecutor) Close() {
 	e.cancel()
+	<-e.done
 }
 
 // Executor 执行器执行
 func (e *DBExecutor) run() {
+	defer close(e.done)
+	defer e.gcTicker.Stop()
 	for {
 		select {
 		case <-e.ctx.Done():
+			e.releaseAll()
 			return
 
-		// 每隔 1 分钟批量一次过期的 key
+		// 按配置频率执行一轮主动过期回收，单轮耗时受时间预算约束
 		case <-e.gcTicker.C:
 			e.dataStore.GC()
 
+		// 阻塞指令超时或连接断开
+		case c := <-e.blocked.unblock:
+			e.timeout(c)
+
 		// 指令处理
 		case cmd := <-e.ch:
 
@@ -107,8 +243,30 @@ func (e *DBExecutor) run() {
 			}
 
 			// 懒加载机制实现过期 key 删除
-			e.dataStore.ExpirePreprocess(string(cmd.Args[0]))
-			cmd.Receiver <- cmdFunc(cmd)
+			if len(cmd.Args) > 0 {
+				e.dataStore.ExpirePreprocess(string(cmd.Args[0]))
+			}
+
+			// 内存超出上限时先按策略淘汰，无法释放足够内存则拒绝写入
+			// aof 加载阶段不执行淘汰
+			if cmd.Cmd.DenyOOM() && !def.IsLoadingPattern(cmd.Ctx) && !e.dataStore.FreeMemoryIfNeeded() {
+				cmd.Receiver <- def.NewErrReply("OOM command not allowed when used memory > 'maxmemory'.")
+				continue
+			}
+
+			// 阻塞指令暂无数据可处理时挂起，不占用执行器
+			reply := cmdFunc(cmd)
+			if blocked, ok := reply.(*blockedReply); ok {
+				e.block(cmd, blocked)
+			} else {
+				cmd.Receiver <- reply
+			}
+
+			// 记录 key 访问信息，用于 lru/lfu 淘汰
+			e.dataStore.Touch()
+
+			// 唤醒等待本笔指令写入 key 的阻塞指令
+			e.serveReadyKeys()
 		}
 	}
 }
@@ -2,31 +2,131 @@ package datastore
 
 import (
 	"context"
+	"math"
 	"strconv"
 	"time"
 
+	"github.com/lovelydayss/goredis/config"
 	def "github.com/lovelydayss/goredis/interface"
 	"github.com/lovelydayss/goredis/lib"
 )
 
-// GC 执行过期键值对回收
-// 利用 zset 的范围查询实现
+// activeExpireConf 主动过期回收参数
+type activeExpireConf struct {
+	keysPerLoop int           // 每轮检查的 key 数量
+	timeBudget  time.Duration // 单个周期的时间预算
+}
+
+// expireStats 过期回收统计信息
+type expireStats struct {
+	expiredKeys         int64         // 累计回收的过期 key 数量，包含惰性删除
+	expiredFields       int64         // 累计回收的过期 hash 字段数量，包含惰性删除
+	stalePerc           float64       // 每轮检查中过期 key 占比的滑动平均
+	avgTTL              int64         // 时间轮中最早到期的未过期 key 剩余存活时间的滑动平均，单位毫秒
+	timeCapReachedCount int64         // 因耗尽时间预算而中止的周期数
+	cycleTime           time.Duration // 主动过期回收累计耗时
+}
+
+// activeExpireHz 每秒执行主动过期回收周期的次数
+func activeExpireHz() int {
+	if hz := config.Config.Expire.Hz; hz > 0 {
+		return hz
+	}
+	return 10
+}
+
+// newActiveExpireConf 读取配置，未配置的参数取 redis 默认值
+func newActiveExpireConf() activeExpireConf {
+	conf := config.Config.Expire
+	c := activeExpireConf{
+		keysPerLoop: 20,
+		timeBudget:  25 * time.Millisecond,
+	}
+	if conf.KeysPerLoop > 0 {
+		c.keysPerLoop = conf.KeysPerLoop
+	}
+	if conf.CycleTimeBudgetMs > 0 {
+		c.timeBudget = time.Duration(conf.CycleTimeBudgetMs) * time.Millisecond
+	}
+	return c
+}
+
+// GC 执行一轮主动过期回收
+// 时间轮按过期时间排序，已过期的 key 总是排在最前，每轮从队首起检查至多 sampleSize 个 key 并回收其中已过期的部分，
+// 检查的 key 全部过期时逐轮加大检查规模持续回收，直至遇到未过期的 key 或耗尽时间预算
 func (k *KVStore) GC() {
-	// 找出当前所有已过期的 key，批量回收
-	nowUnix := lib.TimeNow().Unix()
-	for _, expiredKey := range k.expireTimeWheel.Range(0, nowUnix) {
-		k.expireProcess(expiredKey)
+	start := lib.TimeNow()
+	defer func() {
+		k.expireStats.cycleTime += lib.TimeNow().Sub(start)
+	}()
+
+	k.activeExpireFields()
+
+	sampleSize := k.activeExpire.keysPerLoop
+	maxSampleSize := sampleSize << 4
+	for {
+		sampled, expired := k.sampleExpire(sampleSize)
+		if sampled == 0 {
+			return
+		}
+
+		k.expireStats.stalePerc = float64(expired)/float64(sampled)*0.05 + k.expireStats.stalePerc*0.95
+		// 遇到未过期的 key，其后的 key 均未过期
+		if expired < sampled {
+			return
+		}
+
+		if lib.TimeNow().Sub(start) > k.activeExpire.timeBudget {
+			k.expireStats.timeCapReachedCount++
+			return
+		}
+
+		// 过期 key 仍然较多，加大检查规模
+		if sampleSize < maxSampleSize {
+			sampleSize <<= 1
+		}
+	}
+}
+
+// sampleExpire 从时间轮队首起检查至多 cnt 个 key，回收其中已过期的部分
+// 遇到首个未过期的 key 即停止，并以其剩余存活时间更新 avg_ttl
+func (k *KVStore) sampleExpire(cnt int) (sampled, expired int) {
+	nowUnixMilli := lib.TimeNow().UnixMilli()
+	for sampled < cnt {
+		key, expireAt, ok := k.expireTimeWheel.Min()
+		if !ok {
+			return
+		}
+		sampled++
+
+		if ttl := int64(expireAt) - nowUnixMilli; ttl > 0 {
+			if k.expireStats.avgTTL == 0 {
+				k.expireStats.avgTTL = ttl
+			} else {
+				k.expireStats.avgTTL = k.expireStats.avgTTL/50*49 + ttl/50
+			}
+			return
+		}
+
+		k.expireProcess(key)
+		expired++
 	}
+	return
 }
 
-// ExpirePreprocess 预处理过期键
+// ExpirePreprocess 预处理过期键，同时回收 hash 表中已过期的字段
 func (k *KVStore) ExpirePreprocess(key string) {
+	if expireAt, ok := k.fieldExpireWheel.Score(key); ok && int64(expireAt) <= lib.TimeNow().UnixMilli() {
+		k.expireFields(key)
+	}
+
 	expiredAt, ok := k.expiredAt[key]
 	if !ok {
 		return
 	}
 
-	if expiredAt.After(lib.TimeNow()) {
+	// 精确到毫秒，deadline 当刻即视为过期
+	if expiredAt.UnixMilli() > lib.TimeNow().UnixMilli() {
 		return
 	}
 
@@ -35,55 +135,217 @@ func (k *KVStore) ExpirePreprocess(key string) {
 
 // expireProcess 执行过期键值对回收
 func (k *KVStore) expireProcess(key string) {
+	k.expireStats.expiredKeys++
 	delete(k.expiredAt, key)
-	delete(k.data, key)
+	k.data.Delete(key)
 	k.expireTimeWheel.Rem(key)
+	k.untrack(key)
+	k.fieldExpireWheel.Rem(key)
 }
 
 // Expire 设置 key 的过期时间间隔
 func (k *KVStore) Expire(cmd *def.Command) def.Reply {
 	args := cmd.Args
+	if len(args) != 2 {
+		return def.NewSyntaxErrReply()
+	}
+
 	key := string(args[0])
-	ttl, err := strconv.ParseInt(string(args[1]), 10, 64)
+	expireAt, err := parseExpireAt("ex", args[1])
 	if err != nil {
+		return def.NewErrReply(err.Error())
+	}
+
+	return k.expireAt(cmd.Ctx, pexpireAtCmd(key, expireAt), key, expireAt)
+}
+
+// ExpireAt 设置 key 的绝对过期时间，unix 秒级时间戳
+// 兼容旧版本 aof 中以本地时区 "2006-01-02 15:04:05" 格式记录的过期时间
+func (k *KVStore) ExpireAt(cmd *def.Command) def.Reply {
+	args := cmd.Args
+	if len(args) != 2 {
 		return def.NewSyntaxErrReply()
 	}
-	if ttl <= 0 {
-		return def.NewErrReply("ERR invalid expire time")
+
+	key := string(args[0])
+	expireAt, err := parseExpireAt("exat", args[1])
+	if err != nil {
+		legacy, legacyErr := lib.ParseTimeSecondFormat(string(args[1]))
+		if legacyErr != nil {
+			return def.NewErrReply(err.Error())
+		}
+		expireAt = legacy
 	}
 
-	expireAt := lib.TimeNow().Add(time.Duration(ttl) * time.Second)
-	_cmd := [][]byte{[]byte(def.CmdTypeExpireAt), []byte(key), []byte(lib.TimeSecondFormat(expireAt))}
-	return k.expireAt(cmd.Ctx, _cmd, key, expireAt)
+	return k.expireAt(cmd.Ctx, pexpireAtCmd(key, expireAt), key, expireAt)
 }
 
-// ExpireAt 设置 key 的绝对过期时间
-func (k *KVStore) ExpireAt(cmd *def.Command) def.Reply {
+// PExpire 设置 key 的过期时间间隔，单位毫秒
+func (k *KVStore) PExpire(cmd *def.Command) def.Reply {
 	args := cmd.Args
+	if len(args) != 2 {
+		return def.NewSyntaxErrReply()
+	}
+
 	key := string(args[0])
-	expiredAt, err := lib.ParseTimeSecondFormat(string((args[1])))
+	expireAt, err := parseExpireAt("px", args[1])
 	if err != nil {
+		return def.NewErrReply(err.Error())
+	}
+
+	return k.expireAt(cmd.Ctx, pexpireAtCmd(key, expireAt), key, expireAt)
+}
+
+// PExpireAt 设置 key 的绝对过期时间，unix 毫秒级时间戳
+func (k *KVStore) PExpireAt(cmd *def.Command) def.Reply {
+	args := cmd.Args
+	if len(args) != 2 {
 		return def.NewSyntaxErrReply()
 	}
-	if expiredAt.Before(lib.TimeNow()) {
-		return def.NewErrReply("ERR invalid expire time")
+
+	key := string(args[0])
+	expireAt, err := parseExpireAt("pxat", args[1])
+	if err != nil {
+		return def.NewErrReply(err.Error())
+	}
+
+	return k.expireAt(cmd.Ctx, cmd.GetCmd(), key, expireAt)
+}
+
+// parseExpireAt 按 ex/px/exat/pxat 语义解析绝对过期时间
+func parseExpireAt(unit string, raw []byte) (time.Time, error) {
+	v, err := strconv.ParseInt(string(raw), 10, 64)
+	if err != nil {
+		return time.Time{}, def.NewSyntaxErrReply()
+	}
+	// exat 转为毫秒级时间戳时不能溢出，否则会被当作已过期而删除 key
+	if v <= 0 || (unit == "ex" && v > math.MaxInt64/int64(time.Second)) ||
+		(unit == "px" && v > math.MaxInt64/int64(time.Millisecond)) ||
+		(unit == "exat" && v > math.MaxInt64/1000) {
+		return time.Time{}, def.NewErrReply("ERR invalid expire time")
 	}
 
-	return k.expireAt(cmd.Ctx, cmd.GetCmd(), key, expiredAt)
+	switch unit {
+	case "ex":
+		return lib.TimeNow().Add(time.Duration(v) * time.Second), nil
+	case "px":
+		return lib.TimeNow().Add(time.Duration(v) * time.Millisecond), nil
+	case "exat":
+		return time.Unix(v, 0), nil
+	default:
+		return time.UnixMilli(v), nil
+	}
+}
+
+// pexpireAtCmd 生成持久化使用的 pexpireat 指令
+// 过期时间统一记录为 unix 毫秒级时间戳，与时区无关
+func pexpireAtCmd(key string, expireAt time.Time) [][]byte {
+	return [][]byte{[]byte(def.CmdTypePExpireAt), []byte(key), []byte(strconv.FormatInt(expireAt.UnixMilli(), 10))}
 }
 
 // expireAt 实际设置执行
+// 与 redis 一致，key 存在时返回 1，不存在时返回 0 且不持久化
 func (k *KVStore) expireAt(ctx context.Context, cmd [][]byte, key string, expireAt time.Time) def.Reply {
-	k.expire(key, expireAt)
+	// 过期时间已过，与 redis 一致直接删除 key
+	// 重放 aof 时，已过期的记录同样会被正确清理
+	if expireAt.UnixMilli() <= lib.TimeNow().UnixMilli() {
+		if !k.remove(key) {
+			return def.NewIntReply(0)
+		}
+		k.persister.PersistCmd(ctx, [][]byte{[]byte(def.CmdTypeDel), []byte(key)}) // 持久化
+		return def.NewIntReply(1)
+	}
+
+	if !k.expire(key, expireAt) {
+		return def.NewIntReply(0)
+	}
 	k.persister.PersistCmd(ctx, cmd) // 持久化
-	return def.NewOKReply()
+	return def.NewIntReply(1)
 }
 
-// expire 实际设置执行
-func (k *KVStore) expire(key string, expiredAt time.Time) {
-	if _, ok := k.data[key]; !ok {
-		return
+// expire 实际设置执行，返回 key 是否存在
+func (k *KVStore) expire(key string, expiredAt time.Time) bool {
+	if _, ok := k.lookup(key); !ok {
+		return false
 	}
 	k.expiredAt[key] = expiredAt
-	k.expireTimeWheel.Add(expiredAt.Unix(), key)
+	k.expireTimeWheel.Add(float64(expiredAt.UnixMilli()), key)
+	return true
+}
+
+// TTL 查询 key 剩余存活时间，单位秒
+func (k *KVStore) TTL(cmd *def.Command) def.Reply {
+	return k.ttl(cmd, time.Second, false)
+}
+
+// PTTL 查询 key 剩余存活时间，单位毫秒
+func (k *KVStore) PTTL(cmd *def.Command) def.Reply {
+	return k.ttl(cmd, time.Millisecond, false)
+}
+
+// ExpireTime 查询 key 的绝对过期时间，unix 秒级时间戳
+func (k *KVStore) ExpireTime(cmd *def.Command) def.Reply {
+	return k.ttl(cmd, time.Second, true)
+}
+
+// PExpireTime 查询 key 的绝对过期时间，unix 毫秒级时间戳
+func (k *KVStore) PExpireTime(cmd *def.Command) def.Reply {
+	return k.ttl(cmd, time.Millisecond, true)
+}
+
+// ttl 过期时间查询实际执行
+// key 不存在返回 -2，未设置过期时间返回 -1
+func (k *KVStore) ttl(cmd *def.Command, unit time.Duration, absolute bool) def.Reply {
+	args := cmd.Args
+	if len(args) != 1 {
+		return def.NewSyntaxErrReply()
+	}
+
+	key := string(args[0])
+	if _, ok := k.lookup(key); !ok {
+		return def.NewIntReply(-2)
+	}
+
+	expiredAt, ok := k.expiredAt[key]
+	if !ok {
+		return def.NewIntReply(-1)
+	}
+
+	if absolute {
+		return def.NewIntReply(expiredAt.UnixMilli() / unit.Milliseconds())
+	}
+
+	// 与 redis 保持一致，秒级结果四舍五入
+	remain := expiredAt.Sub(lib.TimeNow())
+	return def.NewIntReply(int64((remain + unit/2) / unit))
+}
+
+// Persist 移除 key 的过期时间
+func (k *KVStore) Persist(cmd *def.Command) def.Reply {
+	args := cmd.Args
+	if len(args) != 1 {
+		return def.NewSyntaxErrReply()
+	}
+
+	key := string(args[0])
+	if _, ok := k.lookup(key); !ok {
+		return def.NewIntReply(0)
+	}
+
+	if !k.persist(key) {
+		return def.NewIntReply(0)
+	}
+
+	k.persister.PersistCmd(cmd.Ctx, cmd.GetCmd()) // 持久化，重放时撤销此前的 expireat
+	return def.NewIntReply(1)
+}
+
+// persist 移除过期时间实际执行
+func (k *KVStore) persist(key string) bool {
+	if _, ok := k.expiredAt[key]; !ok {
+		return false
+	}
+	delete(k.expiredAt, key)
+	k.expireTimeWheel.Rem(key)
+	return true
 }
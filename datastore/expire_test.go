@@ -0,0 +1,270 @@
+package datastore
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	def "github.com/lovelydayss/goredis/interface"
+	"github.com/lovelydayss/goredis/lib"
+)
+
+// deadline 2100-01-01 00:00:00.123 UTC 的 unix 毫秒级时间戳
+const deadline = "4102444800123"
+
+// TestTTL 过期时间查询与移除
+func TestTTL(t *testing.T) {
+	runReplyCases(t, []replyCase{
+		{"ttl missing key", nil, "ttl k", ":-2\r\n"},
+		{"ttl without expire", []string{"set k v"}, "ttl k", ":-1\r\n"},
+		{"ttl", []string{"set k v", "expire k 100"}, "ttl k", ":100\r\n"},
+		{"pttl missing key", nil, "pttl k", ":-2\r\n"},
+		{"expiretime", []string{"set k v", "pexpireat k " + deadline}, "expiretime k", ":4102444800\r\n"},
+		{"pexpiretime", []string{"set k v", "pexpireat k " + deadline}, "pexpiretime k", ":" + deadline + "\r\n"},
+		{"expiretime without expire", []string{"set k v"}, "expiretime k", ":-1\r\n"},
+		{"pexpiretime missing key", nil, "pexpiretime k", ":-2\r\n"},
+		{"ttl arity", nil, "ttl a b", "-Err syntax error\r\n"},
+
+		{"persist", []string{"set k v", "expire k 100"}, "persist k", ":1\r\n"},
+		{"persist clears ttl", []string{"set k v", "expire k 100", "persist k"}, "ttl k", ":-1\r\n"},
+		{"persist without expire", []string{"set k v"}, "persist k", ":0\r\n"},
+		{"persist missing key", nil, "persist k", ":0\r\n"},
+		{"set clears ttl", []string{"set k v", "expire k 100", "set k w"}, "ttl k", ":-1\r\n"},
+	})
+}
+
+// TestTTLPersistence 重放后过期时间不变，PERSIST 撤销此前的过期时间
+func TestTTLPersistence(t *testing.T) {
+	s := newTestStore(t)
+	for _, line := range []string{
+		"set a 1",
+		"pexpireat a " + deadline,
+		"set b 2",
+		"expire b 100",
+		"persist b",
+		"set c 3",
+		"expire c 100",
+		"persist c",
+		"pexpireat c " + deadline,
+	} {
+		s.exec(line)
+	}
+
+	recorded := len(s.persister.cmds)
+	s.exec("persist b")
+	if n := len(s.persister.cmds); n != recorded {
+		t.Errorf("persist without expire persisted %d records", n-recorded)
+	}
+
+	assertReplayed(t, s, "pexpiretime a", "ttl b", "pexpiretime c")
+}
+
+// TestMillisecondExpire 毫秒级过期时间设置
+func TestMillisecondExpire(t *testing.T) {
+	runReplyCases(t, []replyCase{
+		{"pexpire", []string{"set k v", "pexpire k 100000"}, "ttl k", ":100\r\n"},
+		{"pexpireat", []string{"set k v", "pexpireat k " + deadline}, "pexpiretime k", ":" + deadline + "\r\n"},
+		{"pexpire reply", []string{"set k v"}, "pexpire k 100000", ":1\r\n"},
+		{"pexpire missing key", nil, "pexpire k 100000", ":0\r\n"},
+		{"expireat missing key", nil, "expireat k 4102444800", ":0\r\n"},
+		{"pexpireat in the past", []string{"set k v"}, "pexpireat k 1", ":1\r\n"},
+		{"pexpireat in the past deletes key", []string{"set k v", "pexpireat k 1"}, "exists k", ":0\r\n"},
+		{"pexpireat in the past missing key", nil, "pexpireat k 1", ":0\r\n"},
+		{"expireat overflow", []string{"set k v"}, "expireat k 9223372036854775807", "-ERR invalid expire time\r\n"},
+		{"expireat overflow keeps key", []string{"set k v", "expireat k 9223372036854775807"}, "ttl k", ":-1\r\n"},
+		{"pexpire zero", []string{"set k v"}, "pexpire k 0", "-ERR invalid expire time\r\n"},
+		{"pexpire not integer", []string{"set k v"}, "pexpire k 1.5", "-Err syntax error\r\n"},
+
+		{"set px", []string{"set k v px 100000"}, "ttl k", ":100\r\n"},
+		{"set pxat", []string{"set k v pxat " + deadline}, "pexpiretime k", ":" + deadline + "\r\n"},
+		{"set exat", []string{"set k v exat 4102444800"}, "pexpiretime k", ":4102444800000\r\n"},
+		{"set px zero", nil, "set k v px 0", "-ERR invalid expire time\r\n"},
+		{"set exat overflow", nil, "set k v exat 9223372036854776", "-ERR invalid expire time\r\n"},
+		{"set exat overflow keeps value", []string{"set k v", "set k w exat 9223372036854776"}, "get k", "$1\r\nv\r\n"},
+		{"set exat max", []string{"set k v exat 9223372036854775"}, "pexpiretime k", ":9223372036854775000\r\n"},
+		{"set keepttl", []string{"set k v pxat " + deadline, "set k w keepttl"}, "pexpiretime k", ":" + deadline + "\r\n"},
+		{"set keepttl without ttl", []string{"set k v", "set k w keepttl"}, "ttl k", ":-1\r\n"},
+		{"set keepttl with px", nil, "set k v keepttl px 100", "-Err syntax error\r\n"},
+		{"set px twice", nil, "set k v px 100 ex 1", "-Err syntax error\r\n"},
+	})
+}
+
+// TestSubSecondExpire 不足一秒的过期时间在 deadline 之后被惰性删除
+func TestSubSecondExpire(t *testing.T) {
+	s := newTestStore(t)
+	s.exec("set token v px 30")
+	s.exec("set other v")
+	s.exec("pexpire other 30")
+	time.Sleep(50 * time.Millisecond)
+
+	for cmd, want := range map[string]string{
+		"get token":  "$-1\r\n",
+		"pttl token": ":-2\r\n",
+		"get other":  "$-1\r\n",
+	} {
+		if got := s.exec(cmd); got != want {
+			t.Errorf("%s => %q, want %q", cmd, got, want)
+		}
+	}
+
+	// 相对过期时间持久化为绝对时间，重放时已过期的 key 不会复活
+	replayed := s.replay(t)
+	if got := replayed.exec("exists token other"); got != ":0\r\n" {
+		t.Errorf("replayed exists => %q, want %q", got, ":0\r\n")
+	}
+}
+
+// TestExpireAOFEncoding 过期时间统一持久化为 unix 毫秒级时间戳，并兼容旧版本的字符串格式
+func TestExpireAOFEncoding(t *testing.T) {
+	s := newTestStore(t)
+	last := func() []string {
+		var fields []string
+		for _, field := range s.persister.cmds[len(s.persister.cmds)-1] {
+			fields = append(fields, string(field))
+		}
+		return fields
+	}
+
+	s.exec("set k v")
+	before := time.Now().Add(100 * time.Second).UnixMilli()
+	s.exec("expire k 100")
+	after := time.Now().Add(100 * time.Second).UnixMilli()
+	record := last()
+	if len(record) != 3 || record[0] != "pexpireat" {
+		t.Fatalf("expire persisted as %q, want pexpireat", record)
+	}
+	if at, err := strconv.ParseInt(record[2], 10, 64); err != nil || at < before || at > after {
+		t.Errorf("expire persisted deadline %s, want within [%d, %d]", record[2], before, after)
+	}
+
+	// key 不存在时不写入 aof
+	recorded := len(s.persister.cmds)
+	for _, line := range []string{"expire missing 100", "pexpireat missing 1", "expireat missing 4102444800"} {
+		if got := s.exec(line); got != ":0\r\n" {
+			t.Errorf("%s => %q, want :0", line, got)
+		}
+	}
+	if n := len(s.persister.cmds); n != recorded {
+		t.Errorf("expire on missing key persisted %d records", n-recorded)
+	}
+
+	s.exec("expireat k 4102444800")
+	if record := last(); record[0] != "pexpireat" || record[2] != "4102444800000" {
+		t.Errorf("expireat persisted as %q, want pexpireat k 4102444800000", record)
+	}
+	if got := s.exec("expireat k 2100-01-01"); got != "-Err syntax error\r\n" {
+		t.Errorf("expireat invalid time => %q", got)
+	}
+
+	// 旧版本 aof 以本地时区字符串记录 expireat，加载后同样改写为毫秒级时间戳
+	legacy := lib.TimeSecondFormat(time.Unix(4102444800, 0))
+	s.exec("set old v")
+	s.do(context.Background(), [][]byte{[]byte("expireat"), []byte("old"), []byte(legacy)})
+	if got := s.exec("pexpiretime old"); got != ":4102444800000\r\n" {
+		t.Errorf("legacy expireat %q => pexpiretime %q", legacy, got)
+	}
+	if record := last(); record[0] != "pexpireat" || record[2] != "4102444800000" {
+		t.Errorf("legacy expireat persisted as %q, want pexpireat old 4102444800000", record)
+	}
+
+	assertReplayed(t, s, "pexpiretime k", "pexpiretime old")
+}
+
+// newExpireStore 写入 expired 个已过期及 alive 个未过期的 key，不经执行器，便于直接驱动回收周期
+func newExpireStore(expired, alive int) *KVStore {
+	k := NewKVStore(&recordPersister{}).(*KVStore)
+	for i := 0; i < expired+alive; i++ {
+		key := "k" + strconv.Itoa(i)
+		k.put(key, "v")
+		if i < expired {
+			k.expire(key, time.Now().Add(-time.Second))
+		} else {
+			k.expire(key, time.Now().Add(time.Hour))
+		}
+	}
+	return k
+}
+
+// TestActiveExpire 主动过期回收按过期时间从早到晚回收，并受时间预算约束
+func TestActiveExpire(t *testing.T) {
+	t.Run("burst drained in one cycle", func(t *testing.T) {
+		k := newExpireStore(1000, 0)
+		k.GC()
+		if n := k.data.Len(); n != 0 {
+			t.Errorf("%d keys left after gc, want 0", n)
+		}
+		if k.expireStats.expiredKeys != 1000 {
+			t.Errorf("expired_keys %d, want 1000", k.expireStats.expiredKeys)
+		}
+	})
+
+	t.Run("stops at first alive key", func(t *testing.T) {
+		k := newExpireStore(10, 1000)
+		k.GC()
+		if n := len(k.expiredAt); n < 1000 {
+			t.Errorf("%d keys with ttl left, alive keys must not be reclaimed", n)
+		}
+		if k.expireStats.expiredKeys > 10 || k.expireStats.avgTTL <= 0 {
+			t.Errorf("expired_keys %d avg_ttl %d", k.expireStats.expiredKeys, k.expireStats.avgTTL)
+		}
+	})
+
+	t.Run("persisted key leaves wheel", func(t *testing.T) {
+		k := newExpireStore(0, 1)
+		k.persist("k0")
+		if key, _, ok := k.expireTimeWheel.Min(); ok {
+			t.Errorf("%s still in expire time wheel after persist", key)
+		}
+	})
+
+	t.Run("time budget", func(t *testing.T) {
+		k := newExpireStore(1000, 0)
+		k.activeExpire.timeBudget = -1
+		k.GC()
+		if n := k.data.Len(); n != 1000-k.activeExpire.keysPerLoop {
+			t.Errorf("%d keys left, want one sample of %d reclaimed", n, k.activeExpire.keysPerLoop)
+		}
+		if k.expireStats.timeCapReachedCount != 1 {
+			t.Errorf("time cap reached %d, want 1", k.expireStats.timeCapReachedCount)
+		}
+	})
+
+	t.Run("stats in info", func(t *testing.T) {
+		k := newExpireStore(100, 100)
+		k.GC()
+		info := string(k.Info(&def.Command{Ctx: context.Background()}).ToBytes())
+		for _, want := range []string{
+			"expired_keys:" + strconv.FormatInt(k.expireStats.expiredKeys, 10),
+			"expired_time_cap_reached_count:0",
+			"expires=" + strconv.Itoa(len(k.expiredAt)),
+		} {
+			if !strings.Contains(info, want) {
+				t.Errorf("info missing %q", want)
+			}
+		}
+	})
+}
+
+// TestForEachSkipsExpired 遍历与 lookup 一致按毫秒比较，过期时间在当前毫秒内的 key 不再被重写
+func TestForEachSkipsExpired(t *testing.T) {
+	k := NewKVStore(&recordPersister{}).(*KVStore)
+	now := lib.TimeNow()
+	for key, expireAt := range map[string]time.Time{
+		"past":    now.Add(-time.Second),
+		"current": time.UnixMilli(now.UnixMilli()).Add(time.Millisecond - time.Nanosecond),
+		"future":  now.Add(time.Hour),
+	} {
+		k.put(key, "v")
+		k.expire(key, expireAt)
+	}
+
+	var keys []string
+	k.ForEach(func(key string, adapter def.CmdAdapter, expireAt *time.Time) {
+		keys = append(keys, key)
+	})
+	if len(keys) != 1 || keys[0] != "future" {
+		t.Errorf("ForEach => %q, want [future]", keys)
+	}
+}
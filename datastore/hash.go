@@ -0,0 +1,429 @@
+package datastore
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	mhash "github.com/lovelydayss/goredis/datastruct/hash"
+	def "github.com/lovelydayss/goredis/interface"
+)
+
+// hash 类型指令
+
+// HSet HSET key field value [field value ...]，返回新增的字段数
+func (k *KVStore) HSet(cmd *def.Command) def.Reply {
+	args := cmd.Args
+	if len(args) < 3 || len(args)&1 != 1 {
+		return def.NewSyntaxErrReply()
+	}
+
+	key := string(args[0])
+	hmap, err := k.getAsHashMap(key)
+	if err != nil {
+		return def.NewErrReply(err.Error())
+	}
+
+	if hmap == nil {
+		hmap = mhash.NewHashMapEntity(key, &k.hashOptions)
+		k.putAsHashMap(key, hmap)
+	}
+
+	var added int64
+	for i := 1; i < len(args); i += 2 {
+		added += hmap.Put(string(args[i]), args[i+1])
+	}
+
+	k.persister.PersistCmd(cmd.Ctx, cmd.GetCmd()) // 持久化
+	return def.NewIntReply(added)
+}
+
+// HSetNX 字段不存在时写入，返回是否写入
+func (k *KVStore) HSetNX(cmd *def.Command) def.Reply {
+	args := cmd.Args
+	if len(args) != 3 {
+		return def.NewSyntaxErrReply()
+	}
+
+	key := string(args[0])
+	hmap, err := k.getAsHashMap(key)
+	if err != nil {
+		return def.NewErrReply(err.Error())
+	}
+
+	if hmap == nil {
+		hmap = mhash.NewHashMapEntity(key, &k.hashOptions)
+		k.putAsHashMap(key, hmap)
+	} else if _, ok := hmap.Get(string(args[1])); ok {
+		return def.NewIntReply(0)
+	}
+
+	hmap.Put(string(args[1]), args[2])
+	k.persister.PersistCmd(cmd.Ctx, cmd.GetCmd()) // 持久化
+	return def.NewIntReply(1)
+}
+
+// HGet 获取字段的值，字段或 key 不存在时返回 nil
+func (k *KVStore) HGet(cmd *def.Command) def.Reply {
+	args := cmd.Args
+	if len(args) != 2 {
+		return def.NewSyntaxErrReply()
+	}
+
+	hmap, err := k.getAsHashMap(string(args[0]))
+	if err != nil {
+		return def.NewErrReply(err.Error())
+	}
+
+	if hmap == nil {
+		return def.NewNillReply()
+	}
+
+	if v, ok := hmap.Get(string(args[1])); ok {
+		return def.NewBulkReply(v)
+	}
+	return def.NewNillReply()
+}
+
+// HMGet 批量获取字段的值，不存在的字段对应 nil
+func (k *KVStore) HMGet(cmd *def.Command) def.Reply {
+	args := cmd.Args
+	if len(args) < 2 {
+		return def.NewSyntaxErrReply()
+	}
+
+	hmap, err := k.getAsHashMap(string(args[0]))
+	if err != nil {
+		return def.NewErrReply(err.Error())
+	}
+
+	values := make([][]byte, len(args)-1)
+	if hmap != nil {
+		for i, field := range args[1:] {
+			values[i], _ = hmap.Get(string(field))
+		}
+	}
+	return def.NewMultiBulkReply(values)
+}
+
+// HDel 删除字段，返回删除的字段数，hash 表为空时删除 key
+func (k *KVStore) HDel(cmd *def.Command) def.Reply {
+	args := cmd.Args
+	if len(args) < 2 {
+		return def.NewSyntaxErrReply()
+	}
+
+	key := string(args[0])
+	hmap, err := k.getAsHashMap(key)
+	if err != nil {
+		return def.NewErrReply(err.Error())
+	}
+
+	if hmap == nil {
+		return def.NewIntReply(0)
+	}
+
+	var remed int64
+	for _, arg := range args[1:] {
+		remed += hmap.Del(string(arg))
+	}
+
+	if remed > 0 {
+		k.removeIfEmptyHash(key, hmap)
+		k.persister.PersistCmd(cmd.Ctx, cmd.GetCmd()) // 持久化
+	}
+	return def.NewIntReply(remed)
+}
+
+// HExists 字段是否存在
+func (k *KVStore) HExists(cmd *def.Command) def.Reply {
+	args := cmd.Args
+	if len(args) != 2 {
+		return def.NewSyntaxErrReply()
+	}
+
+	hmap, err := k.getAsHashMap(string(args[0]))
+	if err != nil {
+		return def.NewErrReply(err.Error())
+	}
+
+	if hmap == nil {
+		return def.NewIntReply(0)
+	}
+	if _, ok := hmap.Get(string(args[1])); ok {
+		return def.NewIntReply(1)
+	}
+	return def.NewIntReply(0)
+}
+
+// HLen 字段数，key 不存在时返回 0
+func (k *KVStore) HLen(cmd *def.Command) def.Reply {
+	if len(cmd.Args) != 1 {
+		return def.NewSyntaxErrReply()
+	}
+
+	hmap, err := k.getAsHashMap(string(cmd.Args[0]))
+	if err != nil {
+		return def.NewErrReply(err.Error())
+	}
+
+	if hmap == nil {
+		return def.NewIntReply(0)
+	}
+	return def.NewIntReply(hmap.Len())
+}
+
+// HStrLen 字段值的长度，字段或 key 不存在时返回 0
+func (k *KVStore) HStrLen(cmd *def.Command) def.Reply {
+	args := cmd.Args
+	if len(args) != 2 {
+		return def.NewSyntaxErrReply()
+	}
+
+	hmap, err := k.getAsHashMap(string(args[0]))
+	if err != nil {
+		return def.NewErrReply(err.Error())
+	}
+
+	if hmap == nil {
+		return def.NewIntReply(0)
+	}
+	v, _ := hmap.Get(string(args[1]))
+	return def.NewIntReply(int64(len(v)))
+}
+
+// HGetAll 获取全部字段及值，按 field value 依次排列
+func (k *KVStore) HGetAll(cmd *def.Command) def.Reply {
+	return k.hashItems(cmd, true, true)
+}
+
+// HKeys 获取全部字段
+func (k *KVStore) HKeys(cmd *def.Command) def.Reply {
+	return k.hashItems(cmd, true, false)
+}
+
+// HVals 获取全部字段的值
+func (k *KVStore) HVals(cmd *def.Command) def.Reply {
+	return k.hashItems(cmd, false, true)
+}
+
+// hashItems 遍历 hash 表，按需返回字段及值，key 不存在时返回空数组
+func (k *KVStore) hashItems(cmd *def.Command, withFields, withValues bool) def.Reply {
+	if len(cmd.Args) != 1 {
+		return def.NewSyntaxErrReply()
+	}
+
+	hmap, err := k.getAsHashMap(string(cmd.Args[0]))
+	if err != nil {
+		return def.NewErrReply(err.Error())
+	}
+
+	if hmap == nil {
+		return def.NewEmptyMultiBulkReply()
+	}
+
+	items := make([][]byte, 0, 2*hmap.Len())
+	hmap.ForEach(func(field string, value []byte) bool {
+		if withFields {
+			items = append(items, []byte(field))
+		}
+		if withValues {
+			items = append(items, value)
+		}
+		return true
+	})
+	return def.NewMultiBulkReply(items)
+}
+
+// HIncrBy 字段整数自增 increment，字段不存在时视为 0
+func (k *KVStore) HIncrBy(cmd *def.Command) def.Reply {
+	args := cmd.Args
+	if len(args) != 3 {
+		return def.NewSyntaxErrReply()
+	}
+
+	delta, err := strconv.ParseInt(string(args[2]), 10, 64)
+	if err != nil {
+		return def.NewErrReply("ERR value is not an integer or out of range")
+	}
+
+	key, field := string(args[0]), string(args[1])
+	hmap, err := k.getAsHashMap(key)
+	if err != nil {
+		return def.NewErrReply(err.Error())
+	}
+
+	var cur int64
+	if hmap != nil {
+		if v, ok := hmap.Get(field); ok {
+			if cur, err = strconv.ParseInt(string(v), 10, 64); err != nil {
+				return def.NewErrReply("ERR hash value is not an integer")
+			}
+		}
+	}
+
+	if (delta > 0 && cur > math.MaxInt64-delta) || (delta < 0 && cur < math.MinInt64-delta) {
+		return def.NewErrReply("ERR increment or decrement would overflow")
+	}
+
+	cur += delta
+	k.putHashField(key, hmap, field, []byte(strconv.FormatInt(cur, 10)))
+
+	k.persister.PersistCmd(cmd.Ctx, cmd.GetCmd()) // 持久化
+	return def.NewIntReply(cur)
+}
+
+// HIncrByFloat 字段浮点数自增 increment
+// 与 INCRBYFLOAT 一致，持久化时改写为 hset key field value，保证重放结果一致
+func (k *KVStore) HIncrByFloat(cmd *def.Command) def.Reply {
+	args := cmd.Args
+	if len(args) != 3 {
+		return def.NewSyntaxErrReply()
+	}
+
+	delta, err := parseFloat(args[2])
+	if err != nil {
+		return def.NewErrReply("ERR value is not a valid float")
+	}
+
+	key, field := string(args[0]), string(args[1])
+	hmap, err := k.getAsHashMap(key)
+	if err != nil {
+		return def.NewErrReply(err.Error())
+	}
+
+	var cur float64
+	if hmap != nil {
+		if v, ok := hmap.Get(field); ok {
+			if cur, err = parseFloat(v); err != nil {
+				return def.NewErrReply("ERR hash value is not a float")
+			}
+		}
+	}
+
+	cur += delta
+	if math.IsNaN(cur) || math.IsInf(cur, 0) {
+		return def.NewErrReply("ERR increment would produce NaN or Infinity")
+	}
+
+	value := []byte(strconv.FormatFloat(cur, 'f', -1, 64))
+	expireAt, withTTL := k.putHashField(key, hmap, field, value)
+
+	k.persister.PersistCmd(cmd.Ctx, [][]byte{[]byte(def.CmdTypeHSet), args[0], args[1], value}) // 持久化
+	if withTTL {
+		// hset 会清除字段的过期时间，重放时需要重新设置
+		k.persister.PersistCmd(cmd.Ctx, fieldsCmd(def.CmdTypeHPExpireAt, key, [][]byte{args[1]},
+			[]byte(strconv.FormatInt(expireAt, 10))))
+	}
+	return def.NewBulkReply(value)
+}
+
+// HRandField HRANDFIELD key [count [WITHVALUES]]
+// 未指定 count 时返回单个字段，key 不存在返回 nil；
+// count 为正数时返回至多 count 个不重复字段，为负数时返回 |count| 个字段且允许重复
+func (k *KVStore) HRandField(cmd *def.Command) def.Reply {
+	args := cmd.Args
+	if len(args) < 1 || len(args) > 3 {
+		return def.NewSyntaxErrReply()
+	}
+
+	hmap, err := k.getAsHashMap(string(args[0]))
+	if err != nil {
+		return def.NewErrReply(err.Error())
+	}
+
+	if len(args) == 1 {
+		if hmap == nil {
+			return def.NewNillReply()
+		}
+		field, _ := hmap.RandomField()
+		return def.NewBulkReply([]byte(field))
+	}
+
+	cnt, err := strconv.ParseInt(string(args[1]), 10, 64)
+	if err != nil {
+		return def.NewErrReply("ERR value is not an integer or out of range")
+	}
+
+	var withValues bool
+	if len(args) == 3 {
+		if strings.ToLower(string(args[2])) != "withvalues" {
+			return def.NewSyntaxErrReply()
+		}
+		withValues = true
+	}
+
+	if hmap == nil || cnt == 0 {
+		return def.NewEmptyMultiBulkReply()
+	}
+
+	var items [][]byte
+	appendItem := func(field string, value []byte) {
+		items = append(items, []byte(field))
+		if withValues {
+			items = append(items, value)
+		}
+	}
+
+	switch size := hmap.Len(); {
+	// 允许重复，逐个随机选取
+	case cnt < 0:
+		items, err = sampleRepeated(cnt, func() [][]byte {
+			field, value := hmap.RandomField()
+			if withValues {
+				return [][]byte{[]byte(field), value}
+			}
+			return [][]byte{[]byte(field)}
+		})
+		if err != nil {
+			return def.NewErrReply(err.Error())
+		}
+
+	// 不重复且 count 不小于字段数，返回全部字段
+	case cnt >= size:
+		hmap.ForEach(func(field string, value []byte) bool {
+			appendItem(field, value)
+			return true
+		})
+
+	// 不重复且 count 小于字段数，随机选取
+	default:
+		fields := sampleDistinct(size, cnt, func(fn func(field string)) {
+			hmap.ForEach(func(field string, _ []byte) bool {
+				fn(field)
+				return true
+			})
+		}, func() string {
+			field, _ := hmap.RandomField()
+			return field
+		})
+		for _, field := range fields {
+			value, _ := hmap.Get(field)
+			appendItem(field, value)
+		}
+	}
+
+	return def.NewMultiBulkReply(items)
+}
+
+// putHashField 写入字段并保留字段的过期时间，key 不存在时新建 hash 表
+func (k *KVStore) putHashField(key string, hmap mhash.HashMap, field string, value []byte) (int64, bool) {
+	if hmap == nil {
+		hmap = mhash.NewHashMapEntity(key, &k.hashOptions)
+		k.putAsHashMap(key, hmap)
+	}
+
+	expireAt, withTTL := hmap.ExpireAt(field)
+	hmap.Put(field, value)
+	if withTTL {
+		hmap.Expire(field, expireAt)
+	}
+	return expireAt, withTTL
+}
+
+// removeIfEmptyHash hash 表为空时删除 key，与 redis 一致不保留空 hash 表
+func (k *KVStore) removeIfEmptyHash(key string, hmap mhash.HashMap) {
+	if hmap.Len() == 0 {
+		k.remove(key)
+	}
+}
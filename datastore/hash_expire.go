@@ -0,0 +1,339 @@
+package datastore
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	mhash "github.com/lovelydayss/goredis/datastruct/hash"
+	def "github.com/lovelydayss/goredis/interface"
+	"github.com/lovelydayss/goredis/lib"
+)
+
+// hash 表字段过期
+// 字段过期时间记录在 hash 表实体的时间索引中，KVStore.fieldExpireWheel 以 hash 表最早的
+// 字段过期时间为分值记录设置了字段过期时间的 key，访问 key 时惰性回收，GC 时主动回收
+
+// 字段过期时间设置结果
+const (
+	fieldNotExist   = -2 // 字段不存在
+	fieldNoTTL      = -1 // 字段未设置过期时间
+	fieldCondNotMet = 0  // 不满足 NX|XX|GT|LT 条件
+	fieldExpireSet  = 1  // 设置成功
+	fieldExpiredNow = 2  // 过期时间已过，字段被删除
+	fieldTTLRemoved = 1  // 过期时间已移除
+)
+
+// maxFieldsPerLoop 单轮主动回收处理的 hash 表数量上限
+const maxFieldsPerLoop = 64
+
+// HExpire HEXPIRE key seconds [NX|XX|GT|LT] FIELDS numfields field [field ...]
+func (k *KVStore) HExpire(cmd *def.Command) def.Reply {
+	return k.hexpire(cmd, "ex")
+}
+
+// HPExpire HPEXPIRE key milliseconds [NX|XX|GT|LT] FIELDS numfields field [field ...]
+func (k *KVStore) HPExpire(cmd *def.Command) def.Reply {
+	return k.hexpire(cmd, "px")
+}
+
+// HExpireAt HEXPIREAT key unix-time-seconds [NX|XX|GT|LT] FIELDS numfields field [field ...]
+func (k *KVStore) HExpireAt(cmd *def.Command) def.Reply {
+	return k.hexpire(cmd, "exat")
+}
+
+// HPExpireAt HPEXPIREAT key unix-time-milliseconds [NX|XX|GT|LT] FIELDS numfields field [field ...]
+func (k *KVStore) HPExpireAt(cmd *def.Command) def.Reply {
+	return k.hexpire(cmd, "pxat")
+}
+
+// hexpire 字段过期时间设置实际执行，逐个字段返回设置结果
+// 设置成功的字段统一持久化为 hpexpireat，过期时间已过的字段持久化为 hdel
+func (k *KVStore) hexpire(cmd *def.Command, unit string) def.Reply {
+	args := cmd.Args
+	if len(args) < 2 {
+		return def.NewSyntaxErrReply()
+	}
+
+	key := string(args[0])
+	expireAt, err := parseFieldExpireAt(unit, args[1])
+	if err != nil {
+		return def.NewErrReply(err.Error())
+	}
+
+	rest, cond := args[2:], ""
+	if len(rest) > 0 {
+		switch c := strings.ToLower(string(rest[0])); c {
+		case "nx", "xx", "gt", "lt":
+			rest, cond = rest[1:], c
+		}
+	}
+
+	fields, err := parseFields(rest)
+	if err != nil {
+		return def.NewErrReply(err.Error())
+	}
+
+	hmap, err := k.getAsHashMap(key)
+	if err != nil {
+		return def.NewErrReply(err.Error())
+	}
+
+	results := make([]int64, len(fields))
+	if hmap == nil {
+		for i := range results {
+			results[i] = fieldNotExist
+		}
+		return intArrayReply(results)
+	}
+
+	now := lib.TimeNow().UnixMilli()
+	var expired, set [][]byte
+	for i, field := range fields {
+		if _, ok := hmap.Get(field); !ok {
+			results[i] = fieldNotExist
+			continue
+		}
+
+		// 未设置过期时间视为永不过期
+		cur, withTTL := hmap.ExpireAt(field)
+		if (cond == "nx" && withTTL) || (cond == "xx" && !withTTL) ||
+			(cond == "gt" && (!withTTL || expireAt <= cur)) || (cond == "lt" && withTTL && expireAt >= cur) {
+			results[i] = fieldCondNotMet
+			continue
+		}
+
+		if expireAt <= now {
+			hmap.Del(field)
+			expired = append(expired, []byte(field))
+			results[i] = fieldExpiredNow
+			continue
+		}
+
+		hmap.Expire(field, expireAt)
+		set = append(set, []byte(field))
+		results[i] = fieldExpireSet
+	}
+
+	if len(set) > 0 {
+		k.trackFieldExpire(key, hmap)
+		persistCmd := fieldsCmd(def.CmdTypeHPExpireAt, key, set, []byte(strconv.FormatInt(expireAt, 10)))
+		k.persister.PersistCmd(cmd.Ctx, persistCmd) // 持久化
+	}
+	if len(expired) > 0 {
+		k.removeIfEmptyHash(key, hmap)
+		k.persister.PersistCmd(cmd.Ctx, append([][]byte{[]byte(def.CmdTypeHDel), args[0]}, expired...)) // 持久化
+	}
+	return intArrayReply(results)
+}
+
+// HTTL HTTL key FIELDS numfields field [field ...]，逐个字段返回剩余存活时间，单位秒
+func (k *KVStore) HTTL(cmd *def.Command) def.Reply {
+	return k.httl(cmd, time.Second, false)
+}
+
+// HPTTL 逐个字段返回剩余存活时间，单位毫秒
+func (k *KVStore) HPTTL(cmd *def.Command) def.Reply {
+	return k.httl(cmd, time.Millisecond, false)
+}
+
+// HExpireTime 逐个字段返回绝对过期时间，unix 秒级时间戳
+func (k *KVStore) HExpireTime(cmd *def.Command) def.Reply {
+	return k.httl(cmd, time.Second, true)
+}
+
+// HPExpireTime 逐个字段返回绝对过期时间，unix 毫秒级时间戳
+func (k *KVStore) HPExpireTime(cmd *def.Command) def.Reply {
+	return k.httl(cmd, time.Millisecond, true)
+}
+
+// httl 字段过期时间查询实际执行，字段不存在返回 -2，未设置过期时间返回 -1
+func (k *KVStore) httl(cmd *def.Command, unit time.Duration, absolute bool) def.Reply {
+	hmap, fields, reply := k.hashFields(cmd)
+	if reply != nil {
+		return reply
+	}
+
+	now := lib.TimeNow().UnixMilli()
+	return k.eachField(hmap, fields, func(field string) int64 {
+		expireAt, ok := hmap.ExpireAt(field)
+		if !ok {
+			return fieldNoTTL
+		}
+		if absolute {
+			return expireAt / unit.Milliseconds()
+		}
+
+		// 与 TTL 一致，秒级结果四舍五入
+		remain := time.Duration(expireAt-now) * time.Millisecond
+		return int64((remain + unit/2) / unit)
+	})
+}
+
+// HPersist HPERSIST key FIELDS numfields field [field ...]，逐个字段移除过期时间
+func (k *KVStore) HPersist(cmd *def.Command) def.Reply {
+	hmap, fields, reply := k.hashFields(cmd)
+	if reply != nil {
+		return reply
+	}
+
+	var persisted [][]byte
+	reply = k.eachField(hmap, fields, func(field string) int64 {
+		if !hmap.Persist(field) {
+			return fieldNoTTL
+		}
+		persisted = append(persisted, []byte(field))
+		return fieldTTLRemoved
+	})
+
+	if len(persisted) > 0 {
+		k.persister.PersistCmd(cmd.Ctx, fieldsCmd(def.CmdTypeHPersist, string(cmd.Args[0]), persisted)) // 持久化
+	}
+	return reply
+}
+
+// hashFields 解析 key FIELDS numfields field [field ...]，出错时 reply 不为 nil
+func (k *KVStore) hashFields(cmd *def.Command) (mhash.HashMap, []string, def.Reply) {
+	args := cmd.Args
+	if len(args) < 1 {
+		return nil, nil, def.NewSyntaxErrReply()
+	}
+
+	fields, err := parseFields(args[1:])
+	if err != nil {
+		return nil, nil, def.NewErrReply(err.Error())
+	}
+
+	hmap, err := k.getAsHashMap(string(args[0]))
+	if err != nil {
+		return nil, nil, def.NewErrReply(err.Error())
+	}
+	return hmap, fields, nil
+}
+
+// eachField 逐个字段执行 fn，字段或 key 不存在时结果为 -2
+func (k *KVStore) eachField(hmap mhash.HashMap, fields []string, fn func(field string) int64) def.Reply {
+	results := make([]int64, len(fields))
+	for i, field := range fields {
+		if hmap == nil {
+			results[i] = fieldNotExist
+			continue
+		}
+		if _, ok := hmap.Get(field); !ok {
+			results[i] = fieldNotExist
+			continue
+		}
+		results[i] = fn(field)
+	}
+	return intArrayReply(results)
+}
+
+// parseFieldExpireAt 按 ex/px/exat/pxat 语义解析字段的绝对过期时间，单位毫秒
+// 与 key 不同，字段过期时间允许为 0，表示立即删除字段
+func parseFieldExpireAt(unit string, raw []byte) (int64, error) {
+	v, err := strconv.ParseInt(string(raw), 10, 64)
+	if err != nil {
+		return 0, def.NewErrReply("ERR value is not an integer or out of range")
+	}
+	if v < 0 {
+		return 0, def.NewErrReply("ERR invalid expire time, must be >= 0")
+	}
+
+	if unit == "ex" || unit == "exat" {
+		if v > math.MaxInt64/1000 {
+			return 0, def.NewErrReply("ERR invalid expire time")
+		}
+		v *= 1000
+	}
+	if unit == "ex" || unit == "px" {
+		now := lib.TimeNow().UnixMilli()
+		if v > math.MaxInt64-now {
+			return 0, def.NewErrReply("ERR invalid expire time")
+		}
+		v += now
+	}
+	return v, nil
+}
+
+// parseFields 解析 FIELDS numfields field [field ...]
+func parseFields(args [][]byte) ([]string, error) {
+	if len(args) < 2 || strings.ToLower(string(args[0])) != "fields" {
+		return nil, def.NewErrReply("ERR Mandatory argument FIELDS is missing or not at the right position")
+	}
+
+	numFields, err := strconv.ParseInt(string(args[1]), 10, 64)
+	if err != nil || numFields <= 0 {
+		return nil, def.NewErrReply("ERR Parameter `numFields` should be greater than 0")
+	}
+	if numFields != int64(len(args)-2) {
+		return nil, def.NewErrReply("ERR The `numfields` parameter must match the number of arguments")
+	}
+
+	fields := make([]string, 0, numFields)
+	for _, arg := range args[2:] {
+		fields = append(fields, string(arg))
+	}
+	return fields, nil
+}
+
+// fieldsCmd 生成持久化使用的 cmdType key [extra ...] FIELDS numfields field [field ...] 指令
+func fieldsCmd(cmdType def.CmdType, key string, fields [][]byte, extra ...[]byte) [][]byte {
+	cmdLine := make([][]byte, 0, 4+len(extra)+len(fields))
+	cmdLine = append(cmdLine, []byte(cmdType), []byte(key))
+	cmdLine = append(cmdLine, extra...)
+	cmdLine = append(cmdLine, []byte("FIELDS"), []byte(strconv.Itoa(len(fields))))
+	return append(cmdLine, fields...)
+}
+
+// intArrayReply 整数数组
+func intArrayReply(values []int64) def.Reply {
+	replies := make([]def.Reply, 0, len(values))
+	for _, v := range values {
+		replies = append(replies, def.NewIntReply(v))
+	}
+	return def.NewArrayReply(replies)
+}
+
+// trackFieldExpire 按 hash 表最早的字段过期时间更新 fieldExpireWheel
+func (k *KVStore) trackFieldExpire(key string, hmap mhash.HashMap) {
+	if expireAt, ok := hmap.NextExpire(); ok {
+		k.fieldExpireWheel.Add(float64(expireAt), key)
+		return
+	}
+	k.fieldExpireWheel.Rem(key)
+}
+
+// expireFields 回收 hash 表中已过期的字段，字段全部过期时删除 key
+func (k *KVStore) expireFields(key string) {
+	// 先移出索引，避免删除 key 时经 lookup 重复进入
+	k.fieldExpireWheel.Rem(key)
+
+	v, _ := k.data.Get(key)
+	hmap, ok := v.(mhash.HashMap)
+	if !ok {
+		return
+	}
+
+	k.expireStats.expiredFields += hmap.ExpireFields(lib.TimeNow().UnixMilli())
+	if hmap.Len() == 0 {
+		k.remove(key)
+		return
+	}
+
+	k.trackFieldExpire(key, hmap)
+	k.resize(key)
+}
+
+// activeExpireFields 主动回收已到期的 hash 表字段
+func (k *KVStore) activeExpireFields() {
+	// 逐个取出最早到期的 hash 表，单轮开销不随到期的 hash 表数量增长
+	now := float64(lib.TimeNow().UnixMilli())
+	for i := 0; i < maxFieldsPerLoop; i++ {
+		key, expireAt, ok := k.fieldExpireWheel.Min()
+		if !ok || expireAt > now {
+			return
+		}
+		k.expireFields(key)
+	}
+}
@@ -0,0 +1,125 @@
+package datastore
+
+import "testing"
+
+// TestHashConformance hash 指令与 redis 行为的一致性
+func TestHashConformance(t *testing.T) {
+	const (
+		nilBulk  = "$-1\r\n"
+		empty    = "*0\r\n"
+		wrongTyp = "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"
+		abc      = "hset h a 1 b 2 c 3"
+	)
+
+	tests := []replyCase{
+		// HSET / HSETNX
+		{"hset returns added", []string{abc}, "hset h a 9 d 4", ":1\r\n"},
+		{"hset odd arguments", nil, "hset h a", "-Err syntax error\r\n"},
+		{"hsetnx existing", []string{abc}, "hsetnx h a 9", ":0\r\n"},
+		{"hsetnx keeps value", []string{abc, "hsetnx h a 9"}, "hget h a", "$1\r\n1\r\n"},
+		{"hsetnx new field", []string{abc}, "hsetnx h d 4", ":1\r\n"},
+
+		// 查询
+		{"hmget", []string{abc}, "hmget h a x c", "*3\r\n$1\r\n1\r\n$-1\r\n$1\r\n3\r\n"},
+		{"hmget missing key", nil, "hmget h a", "*1\r\n$-1\r\n"},
+		{"hexists", []string{abc}, "hexists h a", ":1\r\n"},
+		{"hexists missing field", []string{abc}, "hexists h x", ":0\r\n"},
+		{"hlen", []string{abc}, "hlen h", ":3\r\n"},
+		{"hlen missing key", nil, "hlen h", ":0\r\n"},
+		{"hstrlen", []string{"hset h a hello"}, "hstrlen h a", ":5\r\n"},
+		{"hstrlen missing field", []string{abc}, "hstrlen h x", ":0\r\n"},
+		{"hgetall single field", []string{"hset h a 1"}, "hgetall h", "*2\r\n$1\r\na\r\n$1\r\n1\r\n"},
+		{"hgetall missing key", nil, "hgetall h", empty},
+		{"hkeys missing key", nil, "hkeys h", empty},
+		{"hvals single field", []string{"hset h a 1"}, "hvals h", "*1\r\n$1\r\n1\r\n"},
+		{"hgetall wrong type", []string{"set h a"}, "hgetall h", wrongTyp},
+
+		// HINCRBY / HINCRBYFLOAT
+		{"hincrby", []string{abc}, "hincrby h a 10", ":11\r\n"},
+		{"hincrby new field", nil, "hincrby h a -2", ":-2\r\n"},
+		{"hincrby not integer increment", []string{abc}, "hincrby h a x", "-ERR value is not an integer or out of range\r\n"},
+		{"hincrby not integer value", []string{"hset h a x"}, "hincrby h a 1", "-ERR hash value is not an integer\r\n"},
+		{"hincrby overflow", []string{"hset h a 9223372036854775807"}, "hincrby h a 1", "-ERR increment or decrement would overflow\r\n"},
+		{"hincrbyfloat", []string{"hset h a 1.5"}, "hincrbyfloat h a 0.1", "$3\r\n1.6\r\n"},
+		{"hincrbyfloat not float value", []string{"hset h a x"}, "hincrbyfloat h a 1", "-ERR hash value is not a float\r\n"},
+		{"hincrbyfloat not float increment", []string{abc}, "hincrbyfloat h a x", "-ERR value is not a valid float\r\n"},
+
+		// HRANDFIELD
+		{"hrandfield missing key", nil, "hrandfield h", nilBulk},
+		{"hrandfield count missing key", nil, "hrandfield h 3", empty},
+		{"hrandfield count zero", []string{abc}, "hrandfield h 0", empty},
+		{"hrandfield single field", []string{"hset h a 1"}, "hrandfield h 5 withvalues", "*2\r\n$1\r\na\r\n$1\r\n1\r\n"},
+		{"hrandfield negative count repeats", []string{"hset h a 1"}, "hrandfield h -2", "*2\r\n$1\r\na\r\n$1\r\na\r\n"},
+		{"hrandfield negative count withvalues", []string{"hset h a 1"}, "hrandfield h -2 withvalues", "*4\r\n$1\r\na\r\n$1\r\n1\r\n$1\r\na\r\n$1\r\n1\r\n"},
+		{"hrandfield negative count out of range", []string{"hset h a 1"}, "hrandfield h -9223372036854775807", "-ERR value is out of range\r\n"},
+		{"hrandfield invalid option", []string{abc}, "hrandfield h 2 withvalue", "-Err syntax error\r\n"},
+
+		// 删除
+		{"hdel last field deletes key", []string{"hset h a 1", "hdel h a x"}, "exists h", ":0\r\n"},
+	}
+
+	runReplyCases(t, tests)
+}
+
+// TestHashPersistence hash 指令持久化后重放的结果一致
+func TestHashPersistence(t *testing.T) {
+	s := newTestStore(t)
+	for _, line := range []string{
+		"hset h a 1 b 2 c 3",
+		"hsetnx h d 4",
+		"hincrby h a 10",
+		"hincrbyfloat h f 0.1",
+		"hincrbyfloat h f 0.2",
+		"hdel h b",
+		"hset d x 1",
+		"hdel d x",
+	} {
+		s.exec(line)
+	}
+
+	assertReplayed(t, s, "hmget h a b c d f", "hlen h", "exists d")
+}
+
+// TestHashFieldExpire hash 表字段过期指令的返回值与生效情况
+func TestHashFieldExpire(t *testing.T) {
+	const abc = "hset h a 1 b 2 c 3"
+
+	tests := []replyCase{
+		{"hexpire", []string{abc}, "hexpire h 100 FIELDS 2 a x", "*2\r\n:1\r\n:-2\r\n"},
+		{"hexpire missing key", nil, "hexpire h 100 FIELDS 1 a", "*1\r\n:-2\r\n"},
+		{"httl", []string{abc, "hexpire h 100 FIELDS 1 a"}, "httl h FIELDS 3 a b x", "*3\r\n:100\r\n:-1\r\n:-2\r\n"},
+		{"hexpire nx", []string{abc, "hexpire h 100 FIELDS 1 a"}, "hexpire h 50 NX FIELDS 2 a b", "*2\r\n:0\r\n:1\r\n"},
+		{"hexpire xx", []string{abc, "hexpire h 100 FIELDS 1 a"}, "hexpire h 200 XX FIELDS 2 a b", "*2\r\n:1\r\n:0\r\n"},
+		{"hexpire gt", []string{abc, "hexpire h 100 FIELDS 1 a"}, "hexpire h 10 GT FIELDS 2 a b", "*2\r\n:0\r\n:0\r\n"},
+		{"hexpire lt", []string{abc, "hexpire h 100 FIELDS 1 a"}, "hexpire h 10 LT FIELDS 2 a b", "*2\r\n:1\r\n:1\r\n"},
+		{"hpersist", []string{abc, "hexpire h 100 FIELDS 1 a"}, "hpersist h FIELDS 3 a b x", "*3\r\n:1\r\n:-1\r\n:-2\r\n"},
+		{"hexpire zero deletes field", []string{abc}, "hexpire h 0 FIELDS 1 a", "*1\r\n:2\r\n"},
+		{"hexpire zero hides field", []string{abc, "hexpire h 0 FIELDS 1 a"}, "hexists h a", ":0\r\n"},
+		{"hexpire all fields deletes key", []string{"hset h a 1", "hexpire h 0 FIELDS 1 a"}, "exists h", ":0\r\n"},
+		{"hset clears field ttl", []string{abc, "hexpire h 100 FIELDS 1 a", "hset h a 9"}, "httl h FIELDS 1 a", "*1\r\n:-1\r\n"},
+		{"hincrby keeps field ttl", []string{abc, "hexpire h 100 FIELDS 1 a", "hincrby h a 1"}, "httl h FIELDS 1 a", "*1\r\n:100\r\n"},
+		{"numfields mismatch", []string{abc}, "hexpire h 10 FIELDS 3 a", "-ERR The `numfields` parameter must match the number of arguments\r\n"},
+		{"numfields zero", []string{abc}, "hexpire h 10 FIELDS 0", "-ERR Parameter `numFields` should be greater than 0\r\n"},
+		{"fields missing", []string{abc}, "hexpire h 10 a", "-ERR Mandatory argument FIELDS is missing or not at the right position\r\n"},
+		{"negative expire", []string{abc}, "hexpire h -1 FIELDS 1 a", "-ERR invalid expire time, must be >= 0\r\n"},
+	}
+
+	runReplyCases(t, tests)
+}
+
+// TestHashFieldExpirePersistence 字段过期时间以绝对时间持久化，重放后一致
+func TestHashFieldExpirePersistence(t *testing.T) {
+	s := newTestStore(t)
+	for _, line := range []string{
+		"hset h a 1 b 2 c 3 d 4",
+		"hexpire h 100 FIELDS 1 a",
+		"hpexpire h 200000 FIELDS 1 b",
+		"hpexpireat h " + deadline + " FIELDS 1 c",
+		"hexpire h 100 FIELDS 1 d",
+		"hpersist h FIELDS 1 d",
+	} {
+		s.exec(line)
+	}
+
+	assertReplayed(t, s, "hpexpiretime h FIELDS 4 a b c d", "hmget h a b c d")
+}
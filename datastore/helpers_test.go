@@ -0,0 +1,104 @@
+package datastore
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	def "github.com/lovelydayss/goredis/interface"
+)
+
+// recordPersister 记录持久化指令，用于校验重放结果
+type recordPersister struct {
+	cmds [][][]byte
+}
+
+func (r *recordPersister) Reloader() (io.ReadCloser, error) { return nil, nil }
+
+func (r *recordPersister) PersistCmd(ctx context.Context, cmd [][]byte) {
+	if def.IsLoadingPattern(ctx) {
+		return
+	}
+	r.cmds = append(r.cmds, append([][]byte{}, cmd...))
+}
+
+func (r *recordPersister) Close() {}
+
+// testStore 基于执行器的测试封装
+type testStore struct {
+	executor  def.Executor
+	persister *recordPersister
+}
+
+func newTestStore(t *testing.T) *testStore {
+	persister := &recordPersister{}
+	executor := NewDBExecutor(NewKVStore(persister))
+	t.Cleanup(executor.Close)
+	return &testStore{executor: executor, persister: persister}
+}
+
+// do 执行以空格分隔的指令，返回协议原文
+func (s *testStore) do(ctx context.Context, cmdLine [][]byte) string {
+	cmd := &def.Command{
+		Ctx:      ctx,
+		Cmd:      def.CmdType(strings.ToLower(string(cmdLine[0]))),
+		Args:     cmdLine[1:],
+		Receiver: make(chan def.Reply, 1),
+	}
+	s.executor.Entrance() <- cmd
+	return string((<-cmd.Receiver).ToBytes())
+}
+
+func (s *testStore) exec(line string) string {
+	var cmdLine [][]byte
+	for _, field := range strings.Fields(line) {
+		cmdLine = append(cmdLine, []byte(field))
+	}
+	return s.do(context.Background(), cmdLine)
+}
+
+// replay 将持久化的指令重放到新的实例中
+func (s *testStore) replay(t *testing.T) *testStore {
+	replayed := newTestStore(t)
+	for _, cmdLine := range s.persister.cmds {
+		replayed.do(def.SetLoadingPattern(context.Background()), cmdLine)
+	}
+	return replayed
+}
+
+// replyCase 在全新实例中依次执行 setup 后，cmd 的期望返回
+type replyCase struct {
+	name  string
+	setup []string
+	cmd   string
+	want  string
+}
+
+// runReplyCases 逐个用例在全新实例中执行并校验返回
+func runReplyCases(t *testing.T, tests []replyCase) {
+	t.Helper()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestStore(t)
+			for _, line := range tt.setup {
+				s.exec(line)
+			}
+			if got := s.exec(tt.cmd); got != tt.want {
+				t.Errorf("%s => %q, want %q", tt.cmd, got, tt.want)
+			}
+		})
+	}
+}
+
+// assertReplayed 重放持久化的指令，逐条比对查询结果与原实例一致
+func assertReplayed(t *testing.T, s *testStore, queries ...string) *testStore {
+	t.Helper()
+	replayed := s.replay(t)
+	for _, query := range queries {
+		if got, want := replayed.exec(query), s.exec(query); got != want {
+			t.Errorf("replayed %s => %q, want %q", query, got, want)
+		}
+	}
+	return replayed
+}
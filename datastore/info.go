@@ -0,0 +1,82 @@
+package datastore
+
+import (
+	"fmt"
+	"strings"
+
+	def "github.com/lovelydayss/goredis/interface"
+)
+
+// infoSection INFO 指令的信息分段
+type infoSection struct {
+	name string
+	gen  func(k *KVStore) string
+}
+
+// infoSections 按输出顺序排列的信息分段
+var infoSections = []infoSection{
+	{name: "memory", gen: (*KVStore).memoryInfo},
+	{name: "stats", gen: (*KVStore).statsInfo},
+	{name: "keyspace", gen: (*KVStore).keyspaceInfo},
+}
+
+// Info 返回服务端运行信息，可指定一个或多个 section 过滤
+func (k *KVStore) Info(cmd *def.Command) def.Reply {
+	wanted := make(map[string]struct{}, len(cmd.Args))
+	for _, arg := range cmd.Args {
+		wanted[strings.ToLower(string(arg))] = struct{}{}
+	}
+
+	_, all := wanted["all"]
+	_, everything := wanted["everything"]
+	_, defaults := wanted["default"]
+	all = all || everything || defaults || len(wanted) == 0
+
+	var buf strings.Builder
+	for _, section := range infoSections {
+		if _, ok := wanted[section.name]; !ok && !all {
+			continue
+		}
+		if buf.Len() > 0 {
+			buf.WriteString(def.CRLF)
+		}
+		buf.WriteString(section.gen(k))
+	}
+
+	return def.NewBulkReply([]byte(buf.String()))
+}
+
+// memoryInfo 内存信息
+func (k *KVStore) memoryInfo() string {
+	used := k.usedMemory()
+	return "# Memory" + def.CRLF +
+		fmt.Sprintf("used_memory:%d", used) + def.CRLF +
+		fmt.Sprintf("used_memory_human:%s", humanMemory(used)) + def.CRLF +
+		fmt.Sprintf("used_memory_peak:%d", k.memStats.peak) + def.CRLF +
+		fmt.Sprintf("used_memory_peak_human:%s", humanMemory(k.memStats.peak)) + def.CRLF +
+		fmt.Sprintf("used_memory_overhead:%d", used-k.memStats.dataset) + def.CRLF +
+		fmt.Sprintf("used_memory_dataset:%d", k.memStats.dataset) + def.CRLF +
+		fmt.Sprintf("maxmemory:%d", k.eviction.maxMemory) + def.CRLF +
+		fmt.Sprintf("maxmemory_human:%s", humanMemory(k.eviction.maxMemory)) + def.CRLF +
+		fmt.Sprintf("maxmemory_policy:%s", k.eviction.policy) + def.CRLF
+}
+
+// statsInfo 统计信息
+func (k *KVStore) statsInfo() string {
+	return "# Stats" + def.CRLF +
+		fmt.Sprintf("expired_keys:%d", k.expireStats.expiredKeys) + def.CRLF +
+		fmt.Sprintf("expired_subkeys:%d", k.expireStats.expiredFields) + def.CRLF +
+		fmt.Sprintf("expired_stale_perc:%.2f", k.expireStats.stalePerc*100) + def.CRLF +
+		fmt.Sprintf("expired_time_cap_reached_count:%d", k.expireStats.timeCapReachedCount) + def.CRLF +
+		fmt.Sprintf("expire_cycle_cpu_milliseconds:%d", k.expireStats.cycleTime.Milliseconds()) + def.CRLF +
+		fmt.Sprintf("evicted_keys:%d", k.evictionStats.evictedKeys) + def.CRLF
+}
+
+// keyspaceInfo key 空间信息
+func (k *KVStore) keyspaceInfo() string {
+	res := "# Keyspace" + def.CRLF
+	if k.data.Len() == 0 {
+		return res
+	}
+	return res + fmt.Sprintf("db0:keys=%d,expires=%d,avg_ttl=%d", k.data.Len(), len(k.expiredAt), k.expireStats.avgTTL) + def.CRLF
+}
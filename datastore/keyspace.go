@@ -0,0 +1,210 @@
+package datastore
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	mhash "github.com/lovelydayss/goredis/datastruct/hash"
+	mlist "github.com/lovelydayss/goredis/datastruct/list"
+	mset "github.com/lovelydayss/goredis/datastruct/set"
+	msortedset "github.com/lovelydayss/goredis/datastruct/sorted_set"
+	mstring "github.com/lovelydayss/goredis/datastruct/string"
+	def "github.com/lovelydayss/goredis/interface"
+)
+
+// 通用 key 空间操作
+
+// embstrSizeLimit 与 redis 一致，长度不超过该值的字符串编码为 embstr
+const embstrSizeLimit = 44
+
+// Del 删除一个或多个 key，返回实际删除的数量
+func (k *KVStore) Del(cmd *def.Command) def.Reply {
+	args := cmd.Args
+	if len(args) < 1 {
+		return def.NewSyntaxErrReply()
+	}
+
+	var deleted int64
+	for _, arg := range args {
+		if k.remove(string(arg)) {
+			deleted++
+		}
+	}
+
+	if deleted > 0 {
+		k.persister.PersistCmd(cmd.Ctx, cmd.GetCmd()) // 持久化
+	}
+	return def.NewIntReply(deleted)
+}
+
+// Unlink 与 Del 语义一致，单协程执行下无需异步释放
+func (k *KVStore) Unlink(cmd *def.Command) def.Reply {
+	return k.Del(cmd)
+}
+
+// Exists 返回存在的 key 数量，重复的 key 会重复计数
+func (k *KVStore) Exists(cmd *def.Command) def.Reply {
+	args := cmd.Args
+	if len(args) < 1 {
+		return def.NewSyntaxErrReply()
+	}
+
+	var cnt int64
+	for _, arg := range args {
+		if _, ok := k.lookup(string(arg)); ok {
+			cnt++
+		}
+	}
+	return def.NewIntReply(cnt)
+}
+
+// Type 返回 key 对应值的类型
+func (k *KVStore) Type(cmd *def.Command) def.Reply {
+	args := cmd.Args
+	if len(args) != 1 {
+		return def.NewSyntaxErrReply()
+	}
+
+	v, ok := k.lookup(string(args[0]))
+	if !ok {
+		return def.NewSimpleStringReply("none")
+	}
+	return def.NewSimpleStringReply(typeOf(v))
+}
+
+// Rename 重命名 key，目标 key 存在时覆盖
+func (k *KVStore) Rename(cmd *def.Command) def.Reply {
+	if len(cmd.Args) != 2 {
+		return def.NewSyntaxErrReply()
+	}
+
+	if errReply := k.rename(cmd); errReply != nil {
+		return errReply
+	}
+	return def.NewOKReply()
+}
+
+// RenameNX 仅当目标 key 不存在时重命名
+func (k *KVStore) RenameNX(cmd *def.Command) def.Reply {
+	if len(cmd.Args) != 2 {
+		return def.NewSyntaxErrReply()
+	}
+
+	src, dst := string(cmd.Args[0]), string(cmd.Args[1])
+	if _, ok := k.lookup(src); ok && src != dst {
+		if _, exist := k.lookup(dst); exist {
+			return def.NewIntReply(0)
+		}
+	}
+
+	if errReply := k.rename(cmd); errReply != nil {
+		return errReply
+	}
+	if src == dst {
+		return def.NewIntReply(0)
+	}
+	return def.NewIntReply(1)
+}
+
+// rename 实际重命名执行，过期时间随 key 一同迁移
+func (k *KVStore) rename(cmd *def.Command) def.Reply {
+	src, dst := string(cmd.Args[0]), string(cmd.Args[1])
+	v, ok := k.lookup(src)
+	if !ok {
+		return def.NewErrReply("ERR no such key")
+	}
+
+	if src == dst {
+		return nil
+	}
+
+	expiredAt, withTTL := k.expiredAt[src]
+	meta, withMeta := k.access[src]
+	k.remove(src)
+	k.remove(dst)
+
+	if adapter, ok := v.(def.CmdAdapter); ok {
+		adapter.SetKey(dst)
+	}
+	k.data.Put(dst, v)
+	if withTTL {
+		k.expire(dst, expiredAt)
+	}
+	if withMeta {
+		k.access[dst] = meta
+		k.memStats.dataset += meta.memory
+		k.memStats.overhead += keyMemory(dst)
+	}
+	k.signalReady(dst)
+	if hmap, ok := v.(mhash.HashMap); ok {
+		k.trackFieldExpire(dst, hmap)
+	}
+
+	k.persister.PersistCmd(cmd.Ctx, cmd.GetCmd()) // 持久化
+	return nil
+}
+
+// typeOf 获取值对应的 redis 类型名称
+func typeOf(v interface{}) string {
+	switch v.(type) {
+	case mstring.String:
+		return "string"
+	case mlist.List:
+		return "list"
+	case mhash.HashMap:
+		return "hash"
+	case mset.Set:
+		return "set"
+	case msortedset.SortedSet:
+		return "zset"
+	default:
+		return "none"
+	}
+}
+
+// Object OBJECT ENCODING key，查询 key 的内部编码
+func (k *KVStore) Object(cmd *def.Command) def.Reply {
+	args := cmd.Args
+	if len(args) < 1 {
+		return def.NewSyntaxErrReply()
+	}
+
+	if sub := strings.ToLower(string(args[0])); sub != "encoding" {
+		return def.NewErrReply(fmt.Sprintf("ERR unknown subcommand '%s'", args[0]))
+	}
+	if len(args) != 2 {
+		return def.NewSyntaxErrReply()
+	}
+
+	v, ok := k.lookup(string(args[1]))
+	if !ok {
+		return def.NewNillReply()
+	}
+	return def.NewBulkReply([]byte(encodingOf(v)))
+}
+
+// encodingOf 获取值对应的 redis 编码名称
+func encodingOf(v interface{}) string {
+	switch val := v.(type) {
+	case mstring.String:
+		raw := val.Bytes()
+		if n, err := strconv.ParseInt(string(raw), 10, 64); err == nil && strconv.FormatInt(n, 10) == string(raw) {
+			return "int"
+		}
+		if len(raw) <= embstrSizeLimit {
+			return "embstr"
+		}
+		return "raw"
+	case mlist.List:
+		return "quicklist"
+	case mhash.HashMap:
+		return val.Encoding()
+	case mset.Set:
+		return val.Encoding()
+	case msortedset.SortedSet:
+		return "skiplist"
+	default:
+		return "none"
+	}
+}
@@ -0,0 +1,88 @@
+package datastore
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// TestKeyspace 通用 key 空间指令的边界行为
+func TestKeyspace(t *testing.T) {
+	runReplyCases(t, []replyCase{
+		{"del counts existing keys once", []string{"set a 1"}, "del a a b", ":1\r\n"},
+		{"del without key", nil, "del", "-Err syntax error\r\n"},
+		{"unlink", []string{"set a 1", "rpush b x"}, "unlink a b c", ":2\r\n"},
+		{"exists counts duplicates", []string{"set a 1"}, "exists a a b", ":2\r\n"},
+		{"deleted key does not exist", []string{"set a 1", "del a"}, "exists a", ":0\r\n"},
+
+		{"type string", []string{"set k v"}, "type k", "+string\r\n"},
+		{"type list", []string{"rpush k v"}, "type k", "+list\r\n"},
+		{"type hash", []string{"hset k f v"}, "type k", "+hash\r\n"},
+		{"type set", []string{"sadd k v"}, "type k", "+set\r\n"},
+		{"type zset", []string{"zadd k 1 v"}, "type k", "+zset\r\n"},
+		{"type missing key", nil, "type k", "+none\r\n"},
+
+		{"rename missing key", nil, "rename a b", "-ERR no such key\r\n"},
+		{"rename overwrites other type", []string{"set a 1", "rpush b x", "rename a b"}, "type b", "+string\r\n"},
+		{"rename removes source", []string{"set a 1", "rename a b"}, "exists a", ":0\r\n"},
+		{"rename to itself", []string{"set a 1", "rename a a"}, "get a", "$1\r\n1\r\n"},
+		{"rename keeps ttl", []string{"set a 1", "expire a 100", "rename a b"}, "ttl b", ":100\r\n"},
+		{"rename drops ttl of dst", []string{"set b 2", "expire b 100", "set a 1", "rename a b"}, "ttl b", ":-1\r\n"},
+		{"rename arity", []string{"set a 1"}, "rename a", "-Err syntax error\r\n"},
+
+		{"renamenx", []string{"set a 1"}, "renamenx a b", ":1\r\n"},
+		{"renamenx existing dst", []string{"set a 1", "set b 2"}, "renamenx a b", ":0\r\n"},
+		{"renamenx existing dst keeps src", []string{"set a 1", "set b 2", "renamenx a b"}, "get a", "$1\r\n1\r\n"},
+		{"renamenx to itself", []string{"set a 1"}, "renamenx a a", ":0\r\n"},
+		{"renamenx missing key", nil, "renamenx a b", "-ERR no such key\r\n"},
+	})
+}
+
+// TestKeyspacePersistence 删除与重命名的重放结果一致，未生效的指令不写入 aof
+func TestKeyspacePersistence(t *testing.T) {
+	s := newTestStore(t)
+	for _, line := range []string{
+		"set a 1",
+		"set b 2",
+		"expire b 100",
+		"lpush l y x",
+		"rename b c",
+		"renamenx a c",
+		"renamenx l m",
+		"del a",
+	} {
+		s.exec(line)
+	}
+
+	recorded := len(s.persister.cmds)
+	for _, line := range []string{"del a none", "renamenx c m", "rename none x"} {
+		s.exec(line)
+	}
+	if n := len(s.persister.cmds); n != recorded {
+		t.Errorf("no-op commands persisted %d records", n-recorded)
+	}
+
+	assertReplayed(t, s, "exists a b", "get c", "ttl c", "lrange m 0 -1", "type l")
+}
+
+// TestObjectEncoding 小集合与小 hash 表使用紧凑编码，超出阈值后转换为哈希表
+func TestObjectEncoding(t *testing.T) {
+	long := strings.Repeat("x", 65)
+	ints := make([]string, 513)
+	for i := range ints {
+		ints[i] = strconv.Itoa(i)
+	}
+	runReplyCases(t, []replyCase{
+		{"int", []string{"set k 123"}, "object encoding k", "$3\r\nint\r\n"},
+		{"embstr", []string{"set k abc"}, "object encoding k", "$6\r\nembstr\r\n"},
+		{"intset", []string{"sadd k 1 2 3"}, "object encoding k", "$6\r\nintset\r\n"},
+		{"set listpack", []string{"sadd k 1 a"}, "object encoding k", "$8\r\nlistpack\r\n"},
+		{"intset overflow", []string{"sadd k " + strings.Join(ints, " ")}, "object encoding k", "$9\r\nhashtable\r\n"},
+		{"set long member", []string{"sadd k a", "sadd k " + long}, "object encoding k", "$9\r\nhashtable\r\n"},
+		{"hash listpack", []string{"hset k f v"}, "object encoding k", "$8\r\nlistpack\r\n"},
+		{"hash long value", []string{"hset k f v", "hset k g " + long}, "object encoding k", "$9\r\nhashtable\r\n"},
+		{"list", []string{"rpush k a"}, "object encoding k", "$9\r\nquicklist\r\n"},
+		{"missing key", nil, "object encoding k", "$-1\r\n"},
+		{"unknown subcommand", nil, "object freq k", "-ERR unknown subcommand 'freq'\r\n"},
+	})
+}
@@ -0,0 +1,661 @@
+package datastore
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	mlist "github.com/lovelydayss/goredis/datastruct/list"
+	def "github.com/lovelydayss/goredis/interface"
+)
+
+// list 类型指令
+
+// LPush 依次将元素推入头部，返回推入后的长度
+func (k *KVStore) LPush(cmd *def.Command) def.Reply {
+	return k.push(cmd, true)
+}
+
+// RPush 依次将元素推入尾部，返回推入后的长度
+func (k *KVStore) RPush(cmd *def.Command) def.Reply {
+	return k.push(cmd, false)
+}
+
+// push 推入实际执行，key 不存在时新建链表
+func (k *KVStore) push(cmd *def.Command, left bool) def.Reply {
+	args := cmd.Args
+	if len(args) < 2 {
+		return def.NewSyntaxErrReply()
+	}
+
+	key := string(args[0])
+	list, err := k.getAsList(key)
+	if err != nil {
+		return def.NewErrReply(err.Error())
+	}
+
+	if list == nil {
+		list = mlist.NewListEntity(key)
+		k.putAsList(key, list)
+	}
+
+	for _, element := range args[1:] {
+		if left {
+			list.LPush(element)
+		} else {
+			list.RPush(element)
+		}
+	}
+	k.signalReady(key)
+
+	k.persister.PersistCmd(cmd.Ctx, cmd.GetCmd()) // 持久化
+	return def.NewIntReply(list.Len())
+}
+
+// LPop LPOP key [count]，从头部弹出元素
+func (k *KVStore) LPop(cmd *def.Command) def.Reply {
+	return k.pop(cmd, true)
+}
+
+// RPop RPOP key [count]，从尾部弹出元素
+func (k *KVStore) RPop(cmd *def.Command) def.Reply {
+	return k.pop(cmd, false)
+}
+
+// pop 弹出实际执行
+// 未指定 count 时返回单个元素，key 不存在返回 nil；
+// 指定 count 时返回至多 count 个元素组成的数组，key 不存在返回 nil 数组；链表弹空后删除 key
+func (k *KVStore) pop(cmd *def.Command, left bool) def.Reply {
+	args := cmd.Args
+	if len(args) < 1 || len(args) > 2 {
+		return def.NewSyntaxErrReply()
+	}
+
+	key := string(args[0])
+	withCount, cnt := len(args) == 2, int64(1)
+	if withCount {
+		var err error
+		if cnt, err = strconv.ParseInt(string(args[1]), 10, 64); err != nil || cnt < 0 {
+			return def.NewErrReply("ERR value is out of range, must be positive")
+		}
+	}
+
+	list, err := k.getAsList(key)
+	if err != nil {
+		return def.NewErrReply(err.Error())
+	}
+
+	if list == nil {
+		if withCount {
+			return def.NewNillMultiBulkReply()
+		}
+		return def.NewNillReply()
+	}
+
+	var poped [][]byte
+	if left {
+		poped = list.LPop(cnt)
+	} else {
+		poped = list.RPop(cnt)
+	}
+
+	if len(poped) > 0 {
+		k.removeIfEmptyList(key, list)
+		k.persister.PersistCmd(cmd.Ctx, cmd.GetCmd()) // 持久化
+	}
+
+	if !withCount {
+		return def.NewBulkReply(poped[0])
+	}
+	return def.NewMultiBulkReply(poped)
+}
+
+// LRange 获取闭区间 [start, stop] 内的元素，下标为负数时从尾部开始计数，越界部分被截断
+func (k *KVStore) LRange(cmd *def.Command) def.Reply {
+	args := cmd.Args
+	if len(args) != 3 {
+		return def.NewSyntaxErrReply()
+	}
+
+	key := string(args[0])
+	start, err1 := strconv.ParseInt(string(args[1]), 10, 64)
+	stop, err2 := strconv.ParseInt(string(args[2]), 10, 64)
+	if err1 != nil || err2 != nil {
+		return def.NewErrReply("ERR value is not an integer or out of range")
+	}
+
+	list, err := k.getAsList(key)
+	if err != nil {
+		return def.NewErrReply(err.Error())
+	}
+
+	if list == nil {
+		return def.NewEmptyMultiBulkReply()
+	}
+
+	return def.NewMultiBulkReply(list.Range(start, stop))
+}
+
+// LLen 链表长度，key 不存在时返回 0
+func (k *KVStore) LLen(cmd *def.Command) def.Reply {
+	args := cmd.Args
+	if len(args) != 1 {
+		return def.NewSyntaxErrReply()
+	}
+
+	list, err := k.getAsList(string(args[0]))
+	if err != nil {
+		return def.NewErrReply(err.Error())
+	}
+	if list == nil {
+		return def.NewIntReply(0)
+	}
+	return def.NewIntReply(list.Len())
+}
+
+// LIndex 获取下标对应的元素，下标为负数时从尾部开始计数
+func (k *KVStore) LIndex(cmd *def.Command) def.Reply {
+	args := cmd.Args
+	if len(args) != 2 {
+		return def.NewSyntaxErrReply()
+	}
+
+	index, err := strconv.ParseInt(string(args[1]), 10, 64)
+	if err != nil {
+		return def.NewErrReply("ERR value is not an integer or out of range")
+	}
+
+	list, err := k.getAsList(string(args[0]))
+	if err != nil {
+		return def.NewErrReply(err.Error())
+	}
+	if list == nil {
+		return def.NewNillReply()
+	}
+
+	element, ok := list.Index(index)
+	if !ok {
+		return def.NewNillReply()
+	}
+	return def.NewBulkReply(element)
+}
+
+// LSet 修改下标对应的元素
+func (k *KVStore) LSet(cmd *def.Command) def.Reply {
+	args := cmd.Args
+	if len(args) != 3 {
+		return def.NewSyntaxErrReply()
+	}
+
+	index, err := strconv.ParseInt(string(args[1]), 10, 64)
+	if err != nil {
+		return def.NewErrReply("ERR value is not an integer or out of range")
+	}
+
+	list, err := k.getAsList(string(args[0]))
+	if err != nil {
+		return def.NewErrReply(err.Error())
+	}
+	if list == nil {
+		return def.NewErrReply("ERR no such key")
+	}
+
+	if !list.Set(index, args[2]) {
+		return def.NewErrReply("ERR index out of range")
+	}
+
+	k.persister.PersistCmd(cmd.Ctx, cmd.GetCmd()) // 持久化
+	return def.NewOKReply()
+}
+
+// LInsert LINSERT key BEFORE|AFTER pivot element
+// 返回插入后的长度，未找到 pivot 时返回 -1，key 不存在时返回 0
+func (k *KVStore) LInsert(cmd *def.Command) def.Reply {
+	args := cmd.Args
+	if len(args) != 4 {
+		return def.NewSyntaxErrReply()
+	}
+
+	var before bool
+	switch strings.ToLower(string(args[1])) {
+	case "before":
+		before = true
+	case "after":
+	default:
+		return def.NewSyntaxErrReply()
+	}
+
+	list, err := k.getAsList(string(args[0]))
+	if err != nil {
+		return def.NewErrReply(err.Error())
+	}
+	if list == nil {
+		return def.NewIntReply(0)
+	}
+
+	if !list.Insert(args[2], args[3], before) {
+		return def.NewIntReply(-1)
+	}
+
+	k.persister.PersistCmd(cmd.Ctx, cmd.GetCmd()) // 持久化
+	return def.NewIntReply(list.Len())
+}
+
+// LRem 删除等于 element 的元素，count 的正负决定删除方向，为 0 时全部删除
+func (k *KVStore) LRem(cmd *def.Command) def.Reply {
+	args := cmd.Args
+	if len(args) != 3 {
+		return def.NewSyntaxErrReply()
+	}
+
+	key := string(args[0])
+	count, err := strconv.ParseInt(string(args[1]), 10, 64)
+	if err != nil {
+		return def.NewErrReply("ERR value is not an integer or out of range")
+	}
+
+	list, err := k.getAsList(key)
+	if err != nil {
+		return def.NewErrReply(err.Error())
+	}
+	if list == nil {
+		return def.NewIntReply(0)
+	}
+
+	removed := list.Rem(count, args[2])
+	if removed == 0 {
+		return def.NewIntReply(0)
+	}
+
+	k.removeIfEmptyList(key, list)
+	k.persister.PersistCmd(cmd.Ctx, cmd.GetCmd()) // 持久化
+	return def.NewIntReply(removed)
+}
+
+// LTrim 仅保留闭区间 [start, stop] 内的元素，链表为空时删除 key
+func (k *KVStore) LTrim(cmd *def.Command) def.Reply {
+	args := cmd.Args
+	if len(args) != 3 {
+		return def.NewSyntaxErrReply()
+	}
+
+	key := string(args[0])
+	start, err1 := strconv.ParseInt(string(args[1]), 10, 64)
+	stop, err2 := strconv.ParseInt(string(args[2]), 10, 64)
+	if err1 != nil || err2 != nil {
+		return def.NewErrReply("ERR value is not an integer or out of range")
+	}
+
+	list, err := k.getAsList(key)
+	if err != nil {
+		return def.NewErrReply(err.Error())
+	}
+	if list == nil {
+		return def.NewOKReply()
+	}
+
+	list.Trim(start, stop)
+	k.removeIfEmptyList(key, list)
+	k.persister.PersistCmd(cmd.Ctx, cmd.GetCmd()) // 持久化
+	return def.NewOKReply()
+}
+
+// LPos LPOS key element [RANK rank] [COUNT num-matches] [MAXLEN len]
+// 未指定 COUNT 时返回首个匹配的下标或 nil，指定 COUNT 时返回下标数组
+func (k *KVStore) LPos(cmd *def.Command) def.Reply {
+	args := cmd.Args
+	if len(args) < 2 {
+		return def.NewSyntaxErrReply()
+	}
+
+	var (
+		rank      int64 = 1
+		count     int64
+		maxLen    int64
+		withCount bool
+	)
+	for i := 2; i < len(args); i += 2 {
+		if i+1 >= len(args) {
+			return def.NewSyntaxErrReply()
+		}
+		v, err := strconv.ParseInt(string(args[i+1]), 10, 64)
+		if err != nil {
+			return def.NewErrReply("ERR value is not an integer or out of range")
+		}
+
+		switch strings.ToLower(string(args[i])) {
+		case "rank":
+			if v == 0 {
+				return def.NewErrReply("ERR RANK can't be zero: use 1 to start from the first match, 2 from the second ... or use negative to start from the end of the list")
+			}
+			rank = v
+		case "count":
+			if v < 0 {
+				return def.NewErrReply("ERR COUNT can't be negative")
+			}
+			count, withCount = v, true
+		case "maxlen":
+			if v < 0 {
+				return def.NewErrReply("ERR MAXLEN can't be negative")
+			}
+			maxLen = v
+		default:
+			return def.NewSyntaxErrReply()
+		}
+	}
+
+	list, err := k.getAsList(string(args[0]))
+	if err != nil {
+		return def.NewErrReply(err.Error())
+	}
+
+	var positions []int64
+	if list != nil {
+		if !withCount {
+			count = 1
+		}
+		positions = list.Pos(args[1], rank, count, maxLen)
+	}
+
+	if !withCount {
+		if len(positions) == 0 {
+			return def.NewNillReply()
+		}
+		return def.NewIntReply(positions[0])
+	}
+
+	replies := make([]def.Reply, 0, len(positions))
+	for _, pos := range positions {
+		replies = append(replies, def.NewIntReply(pos))
+	}
+	return def.NewArrayReply(replies)
+}
+
+// LMove LMOVE source destination LEFT|RIGHT LEFT|RIGHT
+// 从 source 一端弹出元素并推入 destination 一端，source 与 destination 可以相同
+func (k *KVStore) LMove(cmd *def.Command) def.Reply {
+	args := cmd.Args
+	if len(args) != 4 {
+		return def.NewSyntaxErrReply()
+	}
+
+	from, ok1 := parseListSide(args[2])
+	to, ok2 := parseListSide(args[3])
+	if !ok1 || !ok2 {
+		return def.NewSyntaxErrReply()
+	}
+
+	return k.lmove(cmd.Ctx, cmd.GetCmd(), string(args[0]), string(args[1]), from, to)
+}
+
+// RPopLPush 从 source 尾部弹出元素并推入 destination 头部，等价于 LMOVE source destination RIGHT LEFT
+func (k *KVStore) RPopLPush(cmd *def.Command) def.Reply {
+	args := cmd.Args
+	if len(args) != 2 {
+		return def.NewSyntaxErrReply()
+	}
+
+	return k.lmove(cmd.Ctx, cmd.GetCmd(), string(args[0]), string(args[1]), false, true)
+}
+
+// parseListSide 解析 LEFT|RIGHT，left 为 true 表示头部
+func parseListSide(raw []byte) (left bool, ok bool) {
+	switch strings.ToLower(string(raw)) {
+	case "left":
+		return true, true
+	case "right":
+		return false, true
+	default:
+		return false, false
+	}
+}
+
+// lmove 元素移动实际执行
+// 执行器单协程处理指令，弹出与推入之间不会穿插其他指令；
+// 整笔操作以 persistCmd 持久化为一条记录，重放时弹出的元素与执行时一致
+func (k *KVStore) lmove(ctx context.Context, persistCmd [][]byte, src, dst string, fromLeft, toLeft bool) def.Reply {
+	srcList, err := k.getAsList(src)
+	if err != nil {
+		return def.NewErrReply(err.Error())
+	}
+	if srcList == nil {
+		return def.NewNillReply()
+	}
+
+	// 先校验目标类型，避免弹出后无法推入导致元素丢失
+	dstList, err := k.getAsList(dst)
+	if err != nil {
+		return def.NewErrReply(err.Error())
+	}
+
+	var poped [][]byte
+	if fromLeft {
+		poped = srcList.LPop(1)
+	} else {
+		poped = srcList.RPop(1)
+	}
+	if len(poped) == 0 {
+		return def.NewNillReply()
+	}
+	element := poped[0]
+
+	if dstList == nil {
+		dstList = mlist.NewListEntity(dst)
+		k.putAsList(dst, dstList)
+	}
+	if toLeft {
+		dstList.LPush(element)
+	} else {
+		dstList.RPush(element)
+	}
+	k.signalReady(dst)
+
+	k.removeIfEmptyList(src, srcList)
+	k.persister.PersistCmd(ctx, persistCmd) // 持久化
+	return def.NewBulkReply(element)
+}
+
+// removeIfEmptyList 链表为空时删除 key，与 redis 一致不保留空链表
+func (k *KVStore) removeIfEmptyList(key string, list mlist.List) {
+	if list.Len() == 0 {
+		k.remove(key)
+	}
+}
+
+// BLPop BLPOP key [key ...] timeout
+// 从首个非空链表头部弹出元素，返回 [key, element]；均为空时挂起直至有数据推入或超时
+func (k *KVStore) BLPop(cmd *def.Command) def.Reply {
+	return k.blockingPop(cmd, true)
+}
+
+// BRPop BRPOP key [key ...] timeout，从尾部弹出元素
+func (k *KVStore) BRPop(cmd *def.Command) def.Reply {
+	return k.blockingPop(cmd, false)
+}
+
+// blockingPop 阻塞弹出实际执行，持久化为对应 key 的 LPOP/RPOP
+func (k *KVStore) blockingPop(cmd *def.Command, left bool) def.Reply {
+	args := cmd.Args
+	if len(args) < 2 {
+		return def.NewSyntaxErrReply()
+	}
+
+	timeout, err := parseTimeout(args[len(args)-1])
+	if err != nil {
+		return def.NewErrReply(err.Error())
+	}
+
+	keys := make([]string, 0, len(args)-1)
+	for _, arg := range args[:len(args)-1] {
+		keys = append(keys, string(arg))
+	}
+
+	key, poped, err := k.mpop(keys, left, 1)
+	if err != nil {
+		return def.NewErrReply(err.Error())
+	}
+	if poped == nil {
+		return newBlockedReply(keys, timeout)
+	}
+
+	k.persistPop(cmd, key, left, -1) // 持久化
+	return def.NewMultiBulkReply([][]byte{[]byte(key), poped[0]})
+}
+
+// BLMove BLMOVE source destination LEFT|RIGHT LEFT|RIGHT timeout
+// source 为空时挂起直至有数据推入或超时，持久化为 LMOVE
+func (k *KVStore) BLMove(cmd *def.Command) def.Reply {
+	args := cmd.Args
+	if len(args) != 5 {
+		return def.NewSyntaxErrReply()
+	}
+
+	from, ok1 := parseListSide(args[2])
+	to, ok2 := parseListSide(args[3])
+	if !ok1 || !ok2 {
+		return def.NewSyntaxErrReply()
+	}
+
+	timeout, err := parseTimeout(args[4])
+	if err != nil {
+		return def.NewErrReply(err.Error())
+	}
+
+	src, dst := string(args[0]), string(args[1])
+	persistCmd := append([][]byte{[]byte(def.CmdTypeLMove)}, args[:4]...)
+	reply := k.lmove(cmd.Ctx, persistCmd, src, dst, from, to)
+	if _, ok := reply.(*def.NillReply); ok {
+		return newBlockedReply([]string{src}, timeout)
+	}
+	return reply
+}
+
+// LMPop LMPOP numkeys key [key ...] LEFT|RIGHT [COUNT count]
+// 从首个非空链表弹出至多 count 个元素，返回 [key, [element ...]]，均为空时返回 nil 数组
+func (k *KVStore) LMPop(cmd *def.Command) def.Reply {
+	keys, left, cnt, err := parseMPop(cmd.Args, parseListSide)
+	if err != nil {
+		return def.NewErrReply(err.Error())
+	}
+
+	return k.lmpop(cmd, keys, left, cnt)
+}
+
+// BLMPop BLMPOP timeout numkeys key [key ...] LEFT|RIGHT [COUNT count]
+// 均为空时挂起直至有数据推入或超时
+func (k *KVStore) BLMPop(cmd *def.Command) def.Reply {
+	args := cmd.Args
+	if len(args) < 1 {
+		return def.NewSyntaxErrReply()
+	}
+
+	timeout, err := parseTimeout(args[0])
+	if err != nil {
+		return def.NewErrReply(err.Error())
+	}
+
+	keys, left, cnt, err := parseMPop(args[1:], parseListSide)
+	if err != nil {
+		return def.NewErrReply(err.Error())
+	}
+
+	reply := k.lmpop(cmd, keys, left, cnt)
+	if _, ok := reply.(*def.NillMultiBulkReply); ok {
+		return newBlockedReply(keys, timeout)
+	}
+	return reply
+}
+
+// lmpop 批量弹出实际执行，持久化为对应 key 的 LPOP/RPOP key count
+func (k *KVStore) lmpop(cmd *def.Command, keys []string, left bool, cnt int64) def.Reply {
+	key, poped, err := k.mpop(keys, left, cnt)
+	if err != nil {
+		return def.NewErrReply(err.Error())
+	}
+	if poped == nil {
+		return def.NewNillMultiBulkReply()
+	}
+
+	k.persistPop(cmd, key, left, int64(len(poped))) // 持久化
+	return def.NewArrayReply([]def.Reply{
+		def.NewBulkReply([]byte(key)),
+		def.NewMultiBulkReply(poped),
+	})
+}
+
+// parseMPop 解析 numkeys key [key ...] LEFT|RIGHT [COUNT count]，parseSide 解析弹出方向
+func parseMPop(args [][]byte, parseSide func(raw []byte) (bool, bool)) (keys []string, left bool, cnt int64, err error) {
+	if len(args) < 3 {
+		return nil, false, 0, def.NewSyntaxErrReply()
+	}
+
+	numKeys, err := strconv.ParseInt(string(args[0]), 10, 64)
+	if err != nil {
+		return nil, false, 0, def.NewErrReply("ERR value is not an integer or out of range")
+	}
+	if numKeys <= 0 {
+		return nil, false, 0, def.NewErrReply("ERR numkeys should be greater than 0")
+	}
+	if numKeys > int64(len(args)-2) {
+		return nil, false, 0, def.NewSyntaxErrReply()
+	}
+
+	for _, arg := range args[1 : numKeys+1] {
+		keys = append(keys, string(arg))
+	}
+
+	var ok bool
+	if left, ok = parseSide(args[numKeys+1]); !ok {
+		return nil, false, 0, def.NewSyntaxErrReply()
+	}
+
+	cnt = 1
+	switch rest := args[numKeys+2:]; {
+	case len(rest) == 0:
+	case len(rest) == 2 && strings.ToLower(string(rest[0])) == "count":
+		if cnt, err = strconv.ParseInt(string(rest[1]), 10, 64); err != nil || cnt <= 0 {
+			return nil, false, 0, def.NewErrReply("ERR count should be greater than 0")
+		}
+	default:
+		return nil, false, 0, def.NewSyntaxErrReply()
+	}
+
+	return keys, left, cnt, nil
+}
+
+// mpop 从首个非空链表弹出至多 cnt 个元素，均为空时 poped 为 nil
+// 遇到类型错误的 key 直接返回错误，与 redis 一致
+func (k *KVStore) mpop(keys []string, left bool, cnt int64) (key string, poped [][]byte, err error) {
+	for _, key = range keys {
+		list, err := k.getAsList(key)
+		if err != nil {
+			return "", nil, err
+		}
+		if list == nil || list.Len() == 0 {
+			continue
+		}
+
+		if left {
+			poped = list.LPop(cnt)
+		} else {
+			poped = list.RPop(cnt)
+		}
+		k.removeIfEmptyList(key, list)
+		return key, poped, nil
+	}
+	return "", nil, nil
+}
+
+// persistPop 将弹出操作持久化为 LPOP/RPOP key [count]，cnt 小于 0 时不携带 count
+func (k *KVStore) persistPop(cmd *def.Command, key string, left bool, cnt int64) {
+	cmdType := def.CmdTypeRPop
+	if left {
+		cmdType = def.CmdTypeLPop
+	}
+
+	cmdLine := [][]byte{[]byte(cmdType), []byte(key)}
+	if cnt >= 0 {
+		cmdLine = append(cmdLine, []byte(strconv.FormatInt(cnt, 10)))
+	}
+	k.persister.PersistCmd(cmd.Ctx, cmdLine)
+}
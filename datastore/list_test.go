@@ -0,0 +1,217 @@
+package datastore
+
+import (
+	"bytes"
+	"testing"
+)
+
+// TestListConformance 链表指令与 redis 行为的一致性
+func TestListConformance(t *testing.T) {
+	const (
+		nilBulk  = "$-1\r\n"
+		nilArray = "*-1\r\n"
+		empty    = "*0\r\n"
+		wrongTyp = "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"
+	)
+
+	tests := []struct {
+		name  string
+		setup []string
+		cmd   string
+		want  string
+	}{
+		// LRANGE
+		{"lrange all", []string{"rpush l a b c"}, "lrange l 0 -1", "*3\r\n$1\r\na\r\n$1\r\nb\r\n$1\r\nc\r\n"},
+		{"lrange negative start", []string{"rpush l a b c"}, "lrange l -2 -1", "*2\r\n$1\r\nb\r\n$1\r\nc\r\n"},
+		{"lrange stop clamped", []string{"rpush l a b c"}, "lrange l 1 100", "*2\r\n$1\r\nb\r\n$1\r\nc\r\n"},
+		{"lrange start clamped", []string{"rpush l a b c"}, "lrange l -100 0", "*1\r\n$1\r\na\r\n"},
+		{"lrange start after end", []string{"rpush l a b c"}, "lrange l 5 10", empty},
+		{"lrange start after stop", []string{"rpush l a b c"}, "lrange l 2 1", empty},
+		{"lrange stop before head", []string{"rpush l a b c"}, "lrange l 0 -4", empty},
+		{"lrange missing key", nil, "lrange l 0 -1", empty},
+		{"lrange not integer", []string{"rpush l a"}, "lrange l a 1", "-ERR value is not an integer or out of range\r\n"},
+		{"lrange wrong type", []string{"set l a"}, "lrange l 0 -1", wrongTyp},
+
+		// LPOP / RPOP
+		{"lpop single", []string{"rpush l a b c"}, "lpop l", "$1\r\na\r\n"},
+		{"rpop single", []string{"rpush l a b c"}, "rpop l", "$1\r\nc\r\n"},
+		{"lpop count", []string{"rpush l a b c"}, "lpop l 2", "*2\r\n$1\r\na\r\n$1\r\nb\r\n"},
+		{"rpop count in pop order", []string{"rpush l a b c"}, "rpop l 2", "*2\r\n$1\r\nc\r\n$1\r\nb\r\n"},
+		{"lpop count larger than list", []string{"rpush l a b"}, "lpop l 5", "*2\r\n$1\r\na\r\n$1\r\nb\r\n"},
+		{"rpop count larger than list", []string{"rpush l a b"}, "rpop l 5", "*2\r\n$1\r\nb\r\n$1\r\na\r\n"},
+		{"lpop count one", []string{"rpush l a b"}, "lpop l 1", "*1\r\n$1\r\na\r\n"},
+		{"lpop count zero", []string{"rpush l a b"}, "lpop l 0", empty},
+		{"lpop missing key", nil, "lpop l", nilBulk},
+		{"lpop count missing key", nil, "lpop l 2", nilArray},
+		{"rpop count missing key", nil, "rpop l 2", nilArray},
+		{"lpop negative count", []string{"rpush l a"}, "lpop l -1", "-ERR value is out of range, must be positive\r\n"},
+		{"lpop wrong type", []string{"set l a"}, "lpop l", wrongTyp},
+		{"popped empty list is deleted", []string{"rpush l a b", "lpop l 2"}, "exists l", ":0\r\n"},
+		{"popped empty list has no type", []string{"rpush l a", "rpop l"}, "type l", "+none\r\n"},
+
+		// LPUSH / RPUSH
+		{"rpush new key", nil, "rpush l a b", ":2\r\n"},
+		{"lpush order", []string{"lpush l a b c"}, "lrange l 0 -1", "*3\r\n$1\r\nc\r\n$1\r\nb\r\n$1\r\na\r\n"},
+		{"push without element", nil, "rpush l", "-Err syntax error\r\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestStore(t)
+			for _, line := range tt.setup {
+				s.exec(line)
+			}
+			if got := s.exec(tt.cmd); got != tt.want {
+				t.Errorf("%s => %q, want %q", tt.cmd, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestListPersistence 链表指令持久化后重放的结果一致
+func TestListPersistence(t *testing.T) {
+	s := newTestStore(t)
+	for _, line := range []string{
+		"rpush l a b c d e",
+		"lpush l z",
+		"rpop l 2",
+		"lpop l",
+		"rpush drained x",
+		"lpop drained 3",
+		"rpush drained y",
+	} {
+		s.exec(line)
+	}
+
+	replayed := s.replay(t)
+	for _, cmd := range []string{"lrange l 0 -1", "lrange drained 0 -1"} {
+		if got, want := replayed.exec(cmd), s.exec(cmd); got != want {
+			t.Errorf("replayed %s => %q, want %q", cmd, got, want)
+		}
+	}
+}
+
+// TestListEditConformance 按下标及按值访问、修改链表的指令与 redis 行为的一致性
+func TestListEditConformance(t *testing.T) {
+	const (
+		nilBulk  = "$-1\r\n"
+		wrongTyp = "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"
+		abc      = "rpush l a b c"
+		abcs     = "rpush l a b c 1 2 3 c c" // c 位于下标 2、6、7
+		lrange   = "lrange l 0 -1"
+	)
+
+	runReplyCases(t, []replyCase{
+		// LINDEX / LSET
+		{"lindex", []string{abc}, "lindex l 1", "$1\r\nb\r\n"},
+		{"lindex negative", []string{abc}, "lindex l -1", "$1\r\nc\r\n"},
+		{"lindex out of range", []string{abc}, "lindex l 3", nilBulk},
+		{"lindex missing key", nil, "lindex l 0", nilBulk},
+		{"lset", []string{abc, "lset l -1 z"}, lrange, "*3\r\n$1\r\na\r\n$1\r\nb\r\n$1\r\nz\r\n"},
+		{"lset out of range", []string{abc}, "lset l 3 z", "-ERR index out of range\r\n"},
+		{"lset negative out of range", []string{abc}, "lset l -4 z", "-ERR index out of range\r\n"},
+		{"lset out of range keeps list", []string{abc, "lset l 3 z"}, lrange, "*3\r\n$1\r\na\r\n$1\r\nb\r\n$1\r\nc\r\n"},
+		{"lset missing key", nil, "lset l 0 z", "-ERR no such key\r\n"},
+		{"lset wrong type", []string{"set l a"}, "lset l 0 z", wrongTyp},
+
+		// LINSERT
+		{"linsert before", []string{abc}, "linsert l before b x", ":4\r\n"},
+		{"linsert after", []string{abc, "linsert l after c x"}, lrange, "*4\r\n$1\r\na\r\n$1\r\nb\r\n$1\r\nc\r\n$1\r\nx\r\n"},
+		{"linsert pivot missing", []string{abc}, "linsert l before z x", ":-1\r\n"},
+		{"linsert missing key", nil, "linsert l before a x", ":0\r\n"},
+		{"linsert bad position", []string{abc}, "linsert l middle a x", "-Err syntax error\r\n"},
+
+		// LREM，count 为负数时从尾部开始删除
+		{"lrem head first", []string{"rpush l x a x b x", "lrem l 2 x"}, lrange, "*3\r\n$1\r\na\r\n$1\r\nb\r\n$1\r\nx\r\n"},
+		{"lrem negative count", []string{"rpush l x a x b x"}, "lrem l -2 x", ":2\r\n"},
+		{"lrem negative count tail first", []string{"rpush l x a x b x", "lrem l -2 x"}, lrange, "*3\r\n$1\r\nx\r\n$1\r\na\r\n$1\r\nb\r\n"},
+		{"lrem zero removes all", []string{"rpush l x a x b x"}, "lrem l 0 x", ":3\r\n"},
+		{"lrem last element deletes key", []string{"rpush l x x", "lrem l 0 x"}, "exists l", ":0\r\n"},
+		{"lrem missing element", []string{abc}, "lrem l 0 z", ":0\r\n"},
+
+		// LTRIM
+		{"ltrim", []string{abc, "ltrim l 1 -1"}, lrange, "*2\r\n$1\r\nb\r\n$1\r\nc\r\n"},
+		{"ltrim out of range deletes key", []string{abc, "ltrim l 5 10"}, "exists l", ":0\r\n"},
+		{"ltrim missing key", nil, "ltrim l 0 1", "+OK\r\n"},
+
+		// LPOS RANK / COUNT / MAXLEN
+		{"lpos first", []string{abcs}, "lpos l c", ":2\r\n"},
+		{"lpos rank", []string{abcs}, "lpos l c rank 2", ":6\r\n"},
+		{"lpos negative rank", []string{abcs}, "lpos l c rank -1", ":7\r\n"},
+		{"lpos rank past matches", []string{abcs}, "lpos l c rank 4", nilBulk},
+		{"lpos count", []string{abcs}, "lpos l c count 2", "*2\r\n:2\r\n:6\r\n"},
+		{"lpos count zero returns all", []string{abcs}, "lpos l c count 0", "*3\r\n:2\r\n:6\r\n:7\r\n"},
+		{"lpos negative rank count", []string{abcs}, "lpos l c rank -1 count 2", "*2\r\n:7\r\n:6\r\n"},
+		{"lpos rank count", []string{abcs}, "lpos l c rank 2 count 0", "*2\r\n:6\r\n:7\r\n"},
+		{"lpos maxlen", []string{abcs}, "lpos l c count 0 maxlen 3", "*1\r\n:2\r\n"},
+		{"lpos maxlen too short", []string{abcs}, "lpos l c maxlen 2", nilBulk},
+		{"lpos negative rank maxlen", []string{abcs}, "lpos l c rank -2 maxlen 1", nilBulk},
+		{"lpos no match count", []string{abcs}, "lpos l z count 0", "*0\r\n"},
+		{"lpos missing key", nil, "lpos l c", nilBulk},
+		{"lpos missing key count", nil, "lpos l c count 1", "*0\r\n"},
+		{"lpos rank zero", []string{abcs}, "lpos l c rank 0", "-ERR RANK can't be zero: use 1 to start from the first match, 2 from the second ... or use negative to start from the end of the list\r\n"},
+		{"lpos negative count", []string{abcs}, "lpos l c count -1", "-ERR COUNT can't be negative\r\n"},
+		{"lpos negative maxlen", []string{abcs}, "lpos l c maxlen -1", "-ERR MAXLEN can't be negative\r\n"},
+
+		// LMOVE / RPOPLPUSH，source 与 destination 相同时为旋转
+		{"lmove", []string{abc}, "lmove l d left right", "$1\r\na\r\n"},
+		{"lmove pushes to destination", []string{abc, "rpush d x", "lmove l d left left"}, "lrange d 0 -1", "*2\r\n$1\r\na\r\n$1\r\nx\r\n"},
+		{"lmove rotate right to left", []string{abc, "lmove l l right left"}, lrange, "*3\r\n$1\r\nc\r\n$1\r\na\r\n$1\r\nb\r\n"},
+		{"lmove rotate left to right", []string{abc, "lmove l l left right"}, lrange, "*3\r\n$1\r\nb\r\n$1\r\nc\r\n$1\r\na\r\n"},
+		{"lmove rotate single element", []string{"rpush l a", "lmove l l left right"}, lrange, "*1\r\n$1\r\na\r\n"},
+		{"rpoplpush rotate", []string{abc, "rpoplpush l l"}, lrange, "*3\r\n$1\r\nc\r\n$1\r\na\r\n$1\r\nb\r\n"},
+		{"rpoplpush", []string{abc, "rpoplpush l d"}, "lrange d 0 -1", "*1\r\n$1\r\nc\r\n"},
+		{"lmove last element deletes source", []string{"rpush l a", "lmove l d left left"}, "exists l", ":0\r\n"},
+		{"lmove missing source", nil, "lmove l d left left", nilBulk},
+		{"lmove missing source creates nothing", []string{"lmove l d left left"}, "exists d", ":0\r\n"},
+		{"lmove wrong type destination", []string{abc, "set d x"}, "lmove l d left left", wrongTyp},
+		{"lmove wrong type destination keeps source", []string{abc, "set d x", "lmove l d left left"}, lrange, "*3\r\n$1\r\na\r\n$1\r\nb\r\n$1\r\nc\r\n"},
+		{"rpoplpush wrong type destination keeps source", []string{abc, "set d x", "rpoplpush l d"}, lrange, "*3\r\n$1\r\na\r\n$1\r\nb\r\n$1\r\nc\r\n"},
+		{"lmove wrong type source", []string{"set l x"}, "lmove l d left left", wrongTyp},
+		{"lmove bad side", []string{abc}, "lmove l d up left", "-Err syntax error\r\n"},
+	})
+}
+
+// TestListMovePersistence LMOVE 与 RPOPLPUSH 各自持久化为一条记录，重放后状态一致
+func TestListMovePersistence(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup []string
+		cmd   string
+	}{
+		{"lmove", []string{"lpush l c b a", "lpush d x"}, "lmove l d right left"},
+		{"lmove rotate", []string{"lpush l c b a"}, "lmove l l left right"},
+		{"lmove drains source", []string{"lpush l a"}, "lmove l d left right"},
+		{"rpoplpush", []string{"lpush l c b a"}, "rpoplpush l d"},
+		{"rpoplpush rotate", []string{"lpush l c b a"}, "rpoplpush l l"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestStore(t)
+			for _, line := range tt.setup {
+				s.exec(line)
+			}
+
+			before := len(s.persister.cmds)
+			s.exec(tt.cmd)
+			if records := s.persister.cmds[before:]; len(records) != 1 || string(bytes.Join(records[0], []byte(" "))) != tt.cmd {
+				t.Fatalf("%s persisted %q, want a single record", tt.cmd, records)
+			}
+
+			assertReplayed(t, s, "lrange l 0 -1", "lrange d 0 -1", "exists l", "exists d")
+		})
+	}
+
+	// 目标类型错误时不持久化，源链表保持不变
+	s := newTestStore(t)
+	s.exec("lpush l c b a")
+	s.exec("set d x")
+	before := len(s.persister.cmds)
+	s.exec("lmove l d left left")
+	s.exec("rpoplpush l d")
+	if records := s.persister.cmds[before:]; len(records) != 0 {
+		t.Errorf("failed moves persisted %q", records)
+	}
+	assertReplayed(t, s, "lrange l 0 -1", "get d")
+}
@@ -0,0 +1,134 @@
+package datastore
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	def "github.com/lovelydayss/goredis/interface"
+)
+
+const (
+	keyOverhead    = 80 // 每个 key 在 data 及 access 中的条目开销
+	expireOverhead = 96 // 每个过期时间在 expiredAt 及时间轮中的开销
+)
+
+// memoryStats 内存占用统计，随 key 的访问与删除实时维护
+type memoryStats struct {
+	dataset  int64 // 所有 value 的内存占用
+	overhead int64 // 所有 key 自身及其条目的内存占用
+	peak     int64 // 内存占用峰值
+}
+
+// keyMemory key 自身及其条目的内存占用
+func keyMemory(key string) int64 {
+	return keyOverhead + int64(len(key))
+}
+
+// valueMemory value 的内存占用
+func valueMemory(v interface{}) int64 {
+	adapter, ok := v.(def.MemoryAdapter)
+	if !ok {
+		return 0
+	}
+	return adapter.MemoryUsage()
+}
+
+// usedMemory 当前估算的内存占用
+func (k *KVStore) usedMemory() int64 {
+	return k.memStats.dataset + k.memStats.overhead + int64(len(k.expiredAt))*expireOverhead
+}
+
+// Memory MEMORY 指令，支持 USAGE 与 STATS 子指令
+func (k *KVStore) Memory(cmd *def.Command) def.Reply {
+	args := cmd.Args
+	if len(args) < 1 {
+		return def.NewSyntaxErrReply()
+	}
+
+	switch strings.ToLower(string(args[0])) {
+	case "usage":
+		return k.memoryUsage(args[1:])
+	case "stats":
+		if len(args) != 1 {
+			return def.NewSyntaxErrReply()
+		}
+		return k.memoryStats()
+	default:
+		return def.NewErrReply(fmt.Sprintf("ERR unknown subcommand '%s'", args[0]))
+	}
+}
+
+// memoryUsage MEMORY USAGE key [SAMPLES count]
+// redis 抽样 count 个嵌套元素估算聚合类型的内存占用，这里各类型实体的内存占用随增删实时维护，
+// 结果始终是精确累计值，无需抽样，SAMPLES 仅为兼容 redis 而校验格式，不影响结果
+func (k *KVStore) memoryUsage(args [][]byte) def.Reply {
+	if len(args) != 1 && len(args) != 3 {
+		return def.NewSyntaxErrReply()
+	}
+
+	if len(args) == 3 {
+		if strings.ToLower(string(args[1])) != "samples" {
+			return def.NewSyntaxErrReply()
+		}
+		// count 为 0 时 redis 统计全部元素，与这里的精确值一致，其余取值同样不影响结果
+		if samples, err := strconv.ParseInt(string(args[2]), 10, 64); err != nil || samples < 0 {
+			return def.NewSyntaxErrReply()
+		}
+	}
+
+	// 不经过 lookup，避免更新 key 的访问信息
+	key := string(args[0])
+	k.ExpirePreprocess(key)
+	v, ok := k.data.Get(key)
+	if !ok {
+		return def.NewNillReply()
+	}
+
+	size := keyMemory(key) + valueMemory(v)
+	if _, ok := k.expiredAt[key]; ok {
+		size += expireOverhead
+	}
+	return def.NewIntReply(size)
+}
+
+// memoryStats MEMORY STATS，以名称、数值交替的数组返回
+func (k *KVStore) memoryStats() def.Reply {
+	var (
+		used        = k.usedMemory()
+		keys        = int64(k.data.Len())
+		bytesPerKey int64
+		datasetPerc float64
+	)
+	if keys > 0 {
+		bytesPerKey = used / keys
+	}
+	if used > 0 {
+		datasetPerc = float64(k.memStats.dataset) * 100 / float64(used)
+	}
+
+	return def.NewArrayReply([]def.Reply{
+		def.NewBulkReply([]byte("peak.allocated")), def.NewIntReply(k.memStats.peak),
+		def.NewBulkReply([]byte("total.allocated")), def.NewIntReply(used),
+		def.NewBulkReply([]byte("overhead.total")), def.NewIntReply(used - k.memStats.dataset),
+		def.NewBulkReply([]byte("keys.count")), def.NewIntReply(keys),
+		def.NewBulkReply([]byte("keys.bytes-per-key")), def.NewIntReply(bytesPerKey),
+		def.NewBulkReply([]byte("dataset.bytes")), def.NewIntReply(k.memStats.dataset),
+		def.NewBulkReply([]byte("dataset.percentage")), def.NewBulkReply([]byte(strconv.FormatFloat(datasetPerc, 'f', 2, 64))),
+		def.NewBulkReply([]byte("expires.count")), def.NewIntReply(int64(len(k.expiredAt))),
+	})
+}
+
+// humanMemory 内存大小转换为便于阅读的格式
+func humanMemory(n int64) string {
+	switch {
+	case n >= 1<<30:
+		return fmt.Sprintf("%.2fG", float64(n)/(1<<30))
+	case n >= 1<<20:
+		return fmt.Sprintf("%.2fM", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.2fK", float64(n)/(1<<10))
+	default:
+		return fmt.Sprintf("%dB", n)
+	}
+}
@@ -0,0 +1,128 @@
+package datastore
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// TestMemoryCommand MEMORY 指令语法
+func TestMemoryCommand(t *testing.T) {
+	runReplyCases(t, []replyCase{
+		{"usage missing key", nil, "memory usage k", "$-1\r\n"},
+		{"usage samples", []string{"set k v"}, "memory usage k samples 0", ":" + strconv.Itoa(keyOverhead+1+stringUsage("v")) + "\r\n"},
+		{"usage samples negative", []string{"set k v"}, "memory usage k samples -1", "-Err syntax error\r\n"},
+		{"usage samples not integer", []string{"set k v"}, "memory usage k samples x", "-Err syntax error\r\n"},
+		{"usage unknown option", []string{"set k v"}, "memory usage k count 5", "-Err syntax error\r\n"},
+		{"stats arity", nil, "memory stats x", "-Err syntax error\r\n"},
+		{"unknown subcommand", nil, "memory doctor", "-ERR unknown subcommand 'doctor'\r\n"},
+	})
+}
+
+// TestMemoryUsageSamples 内存占用为精确累计值，SAMPLES 取值不影响结果
+func TestMemoryUsageSamples(t *testing.T) {
+	s := newTestStore(t)
+	for i := 0; i < 100; i++ {
+		s.exec("rpush l " + strings.Repeat("x", i+1))
+	}
+
+	want := s.exec("memory usage l")
+	for _, samples := range []string{"0", "1", "5", "1000"} {
+		if got := s.exec("memory usage l samples " + samples); got != want {
+			t.Errorf("memory usage l samples %s => %q, want %q", samples, got, want)
+		}
+	}
+}
+
+// stringUsage 新写入的字符串 value 的内存占用
+func stringUsage(v string) int {
+	k := NewKVStore(&recordPersister{}).(*KVStore)
+	k.put("k", v)
+	val, _ := k.data.Get("k")
+	return int(valueMemory(val))
+}
+
+// parseStats 解析 MEMORY STATS 回复中的名称、整数值对
+func parseStats(reply string) map[string]int64 {
+	lines := strings.Split(reply, "\r\n")
+	stats := make(map[string]int64)
+	for i := 1; i+2 < len(lines); i++ {
+		if !strings.HasPrefix(lines[i], "$") || !strings.HasPrefix(lines[i+2], ":") {
+			continue
+		}
+		if v, err := strconv.ParseInt(lines[i+2][1:], 10, 64); err == nil {
+			stats[lines[i+1]] = v
+		}
+	}
+	return stats
+}
+
+// parseBulks 解析多行字符串数组回复
+func parseBulks(reply string) []string {
+	lines := strings.Split(reply, "\r\n")
+	var res []string
+	for i := 1; i+1 < len(lines); i++ {
+		if strings.HasPrefix(lines[i], "$") {
+			res = append(res, lines[i+1])
+			i++
+		}
+	}
+	return res
+}
+
+// TestMemoryAccounting 各类指令原地修改 value 后，累计的内存占用与逐个 key 统计的结果一致
+func TestMemoryAccounting(t *testing.T) {
+	s := newTestStore(t)
+	for _, line := range []string{
+		"set s hello",
+		"append s world",
+		"setrange s 100 x",
+		"incr n",
+		"incrby n 1000000",
+		"rpush l a b c d e f",
+		"lpop l 2",
+		"lset l 0 longer-element",
+		"lmove l l2 left right",
+		"hset h f1 v1 f2 v2",
+		"hset h f1 much-longer-value",
+		"hdel h f2",
+		"hexpire h 100 fields 1 f1",
+		"sadd set 1 2 3",
+		"sadd set member",
+		"srem set 1",
+		"zadd z 1 a 2 b 3 c",
+		"zincrby z 5 a",
+		"setbit b 1000 1",
+		"setbit b 3 1",
+		"zrem z b",
+		"expire s 100",
+		"rename s s2",
+		"persist s2",
+		"del n",
+		"lpop l2",
+	} {
+		s.exec(line)
+
+		stats := parseStats(s.exec("memory stats"))
+		var sum int64
+		keys := parseBulks(s.exec("keys *"))
+		for _, key := range keys {
+			usage, err := strconv.ParseInt(strings.Trim(s.exec("memory usage "+key), ":\r\n"), 10, 64)
+			if err != nil {
+				t.Fatalf("after %q: memory usage %s: %v", line, key, err)
+			}
+			sum += usage
+		}
+		if stats["total.allocated"] != sum || stats["keys.count"] != int64(len(keys)) {
+			t.Fatalf("after %q: total.allocated %d for %d keys, want %d for %d keys",
+				line, stats["total.allocated"], stats["keys.count"], sum, len(keys))
+		}
+	}
+
+	// 删除所有 key 后内存占用归零，峰值保留
+	s.exec("del s2 l l2 h set z b")
+	stats := parseStats(s.exec("memory stats"))
+	if stats["total.allocated"] != 0 || stats["peak.allocated"] == 0 {
+		t.Errorf("after del all: total %d peak %d", stats["total.allocated"], stats["peak.allocated"])
+	}
+}
@@ -12,8 +12,30 @@ import (
 
 // K-V 存储对应操作
 
+// lookup 获取 key 对应的值，已过期的 key 会被惰性删除
+func (k *KVStore) lookup(key string) (interface{}, bool) {
+	k.ExpirePreprocess(key)
+	k.touched = append(k.touched, key)
+	v, ok := k.data.Get(key)
+	return v, ok
+}
+
+// remove 删除 key 及其过期信息，返回 key 是否存在
+func (k *KVStore) remove(key string) bool {
+	if _, ok := k.lookup(key); !ok {
+		return false
+	}
+
+	k.data.Delete(key)
+	k.untrack(key)
+	k.persist(key)
+	k.fieldExpireWheel.Rem(key)
+	return true
+}
+
+// getAsString 获取字符串
 func (k *KVStore) getAsString(key string) (mstring.String, error) {
-	v, ok := k.data[key]
+	v, ok := k.lookup(key)
 	if !ok {
 		return nil, nil
 	}
@@ -26,17 +48,15 @@ func (k *KVStore) getAsString(key string) (mstring.String, error) {
 	return str, nil
 }
 
-func (k *KVStore) put(key, value string, insertStrategy bool) int64 {
-	if _, ok := k.data[key]; ok && insertStrategy {
-		return 0
-	}
-
-	k.data[key] = mstring.NewString(key, value)
-	return 1
+// put 写入字符串，覆盖写会同时清除原有的过期时间
+func (k *KVStore) put(key, value string) {
+	k.lookup(key)
+	k.data.Put(key, mstring.NewString(key, value))
+	k.persist(key)
 }
 
 func (k *KVStore) getAsList(key string) (mlist.List, error) {
-	v, ok := k.data[key]
+	v, ok := k.lookup(key)
 	if !ok {
 		return nil, nil
 	}
@@ -50,11 +70,11 @@ func (k *KVStore) getAsList(key string) (mlist.List, error) {
 }
 
 func (k *KVStore) putAsList(key string, list mlist.List) {
-	k.data[key] = list
+	k.data.Put(key, list)
 }
 
 func (k *KVStore) getAsHashMap(key string) (mhash.HashMap, error) {
-	v, ok := k.data[key]
+	v, ok := k.lookup(key)
 	if !ok {
 		return nil, nil
 	}
@@ -68,11 +88,11 @@ func (k *KVStore) getAsHashMap(key string) (mhash.HashMap, error) {
 }
 
 func (k *KVStore) putAsHashMap(key string, hmap mhash.HashMap) {
-	k.data[key] = hmap
+	k.data.Put(key, hmap)
 }
 
 func (k *KVStore) getAsSet(key string) (mset.Set, error) {
-	v, ok := k.data[key]
+	v, ok := k.lookup(key)
 	if !ok {
 		return nil, nil
 	}
@@ -86,11 +106,11 @@ func (k *KVStore) getAsSet(key string) (mset.Set, error) {
 }
 
 func (k *KVStore) putAsSet(key string, set mset.Set) {
-	k.data[key] = set
+	k.data.Put(key, set)
 }
 
 func (k *KVStore) getAsSortedSet(key string) (msortedset.SortedSet, error) {
-	v, ok := k.data[key]
+	v, ok := k.lookup(key)
 	if !ok {
 		return nil, nil
 	}
@@ -104,23 +124,21 @@ func (k *KVStore) getAsSortedSet(key string) (msortedset.SortedSet, error) {
 }
 
 func (k *KVStore) putAsSortedSet(key string, zset msortedset.SortedSet) {
-	k.data[key] = zset
+	k.data.Put(key, zset)
 }
 
+// getAsBitmap 获取位图，位图与字符串互通，为字符串字节之上的视图
 func (k *KVStore) getAsBitmap(key string) (mbitmap.BitMap, error) {
-	v, ok := k.data[key]
-	if !ok {
-		return nil, nil
+	str, err := k.getAsString(key)
+	if str == nil || err != nil {
+		return nil, err
 	}
-
-	bmap, ok := v.(mbitmap.BitMap)
-	if !ok {
-		return nil, def.NewWrongTypeErrReply()
-	}
-
-	return bmap, nil
+	return str.BitMap(), nil
 }
 
-func (k *KVStore) putAsBitmap(key string, bmap mbitmap.BitMap) {
-	k.data[key] = bmap
+// putAsBitmap 以空字符串新建 key，返回其位图视图
+func (k *KVStore) putAsBitmap(key string) mbitmap.BitMap {
+	str := mstring.NewString(key, "")
+	k.data.Put(key, str)
+	return str.BitMap()
 }
@@ -0,0 +1,55 @@
+package datastore
+
+import (
+	"math"
+	"math/rand"
+
+	def "github.com/lovelydayss/goredis/interface"
+)
+
+// sampleDistinct 从 size 个成员中随机选取 cnt 个不重复的成员，0 < cnt < size
+// forEach 遍历全部成员，random 随机返回一个成员
+func sampleDistinct(size, cnt int64, forEach func(fn func(member string)), random func() string) []string {
+	// count 与成员数接近时随机选取命中重复的概率较高，改为打乱全部成员后截取
+	if cnt*3 > size {
+		members := make([]string, 0, size)
+		forEach(func(member string) {
+			members = append(members, member)
+		})
+		for i := int64(0); i < cnt; i++ {
+			j := i + rand.Int63n(size-i)
+			members[i], members[j] = members[j], members[i]
+		}
+		return members[:cnt]
+	}
+
+	// count 远小于成员数，随机选取并去重
+	members := make([]string, 0, cnt)
+	seen := make(map[string]struct{}, cnt)
+	for int64(len(members)) < cnt {
+		member := random()
+		if _, ok := seen[member]; ok {
+			continue
+		}
+		seen[member] = struct{}{}
+		members = append(members, member)
+	}
+	return members
+}
+
+// randomPreallocLimit count 为负数时结果预分配的最大元素数量
+const randomPreallocLimit = 1024
+
+// sampleRepeated count 为负数时逐个随机选取 -count 个元素，允许重复，random 返回一个元素的回包内容
+// 与 redis 一致拒绝小于 -math.MaxInt64/2 的 count；-count 可能远大于集合大小，预分配容量不超过 randomPreallocLimit
+func sampleRepeated(cnt int64, random func() [][]byte) ([][]byte, error) {
+	if cnt < -math.MaxInt64/2 {
+		return nil, def.NewErrReply("ERR value is out of range")
+	}
+
+	items := make([][]byte, 0, min(-cnt, randomPreallocLimit))
+	for i := int64(0); i < -cnt; i++ {
+		items = append(items, random()...)
+	}
+	return items, nil
+}
@@ -0,0 +1,32 @@
+package datastore
+
+import (
+	"math"
+	"testing"
+)
+
+// TestSampleRepeated count 为负数时的边界，超出范围的 count 在选取前即被拒绝
+func TestSampleRepeated(t *testing.T) {
+	tests := []struct {
+		cnt     int64
+		wantLen int
+		wantErr bool
+	}{
+		{-1, 1, false},
+		{-randomPreallocLimit - 1, randomPreallocLimit + 1, false},
+		{-math.MaxInt64/2 - 1, 0, true},
+		{-math.MaxInt64, 0, true},
+		{math.MinInt64, 0, true},
+	}
+
+	for _, tt := range tests {
+		var calls int
+		items, err := sampleRepeated(tt.cnt, func() [][]byte {
+			calls++
+			return [][]byte{[]byte("a")}
+		})
+		if (err != nil) != tt.wantErr || len(items) != tt.wantLen || calls != tt.wantLen {
+			t.Errorf("sampleRepeated(%d) => %d items in %d calls, err %v", tt.cnt, len(items), calls, err)
+		}
+	}
+}
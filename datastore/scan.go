@@ -0,0 +1,255 @@
+package datastore
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	msortedset "github.com/lovelydayss/goredis/datastruct/sorted_set"
+	def "github.com/lovelydayss/goredis/interface"
+	"github.com/lovelydayss/goredis/lib"
+)
+
+// 游标遍历相关指令
+
+// scanOption SCAN 系列指令选项
+type scanOption struct {
+	match    string // glob 风格的过滤规则，为空表示不过滤
+	count    int    // 单次遍历期望返回的元素数量，仅作为提示
+	typ      string // 按类型过滤，仅 SCAN 支持
+	noValues bool   // 不返回 value，仅 HSCAN 支持
+}
+
+// parseScan 解析 cursor [MATCH pattern] [COUNT count] [TYPE type] [NOVALUES]
+func parseScan(cmdType def.CmdType, args [][]byte) (uint64, *scanOption, error) {
+	if len(args) < 1 {
+		return 0, nil, def.NewSyntaxErrReply()
+	}
+
+	cursor, err := strconv.ParseUint(string(args[0]), 10, 64)
+	if err != nil {
+		return 0, nil, def.NewErrReply("ERR invalid cursor")
+	}
+
+	opt := scanOption{count: 10}
+	for i := 1; i < len(args); i++ {
+		flag := strings.ToLower(string(args[i]))
+		switch {
+		case flag == "match" && i+1 < len(args):
+			opt.match = string(args[i+1])
+			i++
+		case flag == "count" && i+1 < len(args):
+			count, err := strconv.Atoi(string(args[i+1]))
+			if err != nil {
+				return 0, nil, def.NewErrReply("ERR value is not an integer or out of range")
+			}
+			if count < 1 {
+				return 0, nil, def.NewSyntaxErrReply()
+			}
+			opt.count = count
+			i++
+		case flag == "type" && i+1 < len(args) && cmdType == def.CmdTypeScan:
+			opt.typ = strings.ToLower(string(args[i+1]))
+			i++
+		case flag == "novalues" && cmdType == def.CmdTypeHScan:
+			opt.noValues = true
+		default:
+			return 0, nil, def.NewSyntaxErrReply()
+		}
+	}
+
+	// 匹配任意字符串的规则等价于不过滤
+	if opt.match == "*" {
+		opt.match = ""
+	}
+	return cursor, &opt, nil
+}
+
+// matched 判断元素是否满足 MATCH 规则
+func (o *scanOption) matched(str string) bool {
+	return o.match == "" || lib.GlobMatch(o.match, str)
+}
+
+// scanLoop 从 cursor 开始逐个桶遍历，直至收集到 count 个元素、遍历结束
+// 或访问桶数达到 count 的 10 倍，避免稀疏的哈希表单次遍历耗时过长
+func scanLoop(cursor uint64, count int, step func(cursor uint64) uint64, collected func() int) uint64 {
+	// count 过大时 count*10 会溢出为负数，导致只访问一个桶
+	if count > math.MaxInt/10 {
+		count = math.MaxInt / 10
+	}
+	for maxIterations := count * 10; ; maxIterations-- {
+		cursor = step(cursor)
+		if cursor == 0 || maxIterations <= 1 || collected() >= count {
+			return cursor
+		}
+	}
+}
+
+// scanReply 以 [下一个游标, 元素数组] 格式回包
+func scanReply(cursor uint64, items [][]byte) def.Reply {
+	return def.NewArrayReply([]def.Reply{
+		def.NewBulkReply([]byte(strconv.FormatUint(cursor, 10))),
+		def.NewMultiBulkReply(items),
+	})
+}
+
+// Scan SCAN cursor [MATCH pattern] [COUNT count] [TYPE type]
+// 遍历全程存在的 key 至少会被返回一次，遍历期间新增或删除的 key 可能返回也可能不返回
+func (k *KVStore) Scan(cmd *def.Command) def.Reply {
+	cursor, opt, err := parseScan(cmd.Cmd, cmd.Args)
+	if err != nil {
+		return def.NewErrReply(err.Error())
+	}
+
+	var keys []string
+	cursor = scanLoop(cursor, opt.count, func(cursor uint64) uint64 {
+		return k.data.Scan(cursor, func(key string, _ interface{}) {
+			keys = append(keys, key)
+		})
+	}, func() int { return len(keys) })
+
+	// 遍历结束后再做过滤，惰性删除过期 key 不会影响哈希表的遍历
+	items := make([][]byte, 0, len(keys))
+	for _, key := range keys {
+		if !opt.matched(key) {
+			continue
+		}
+
+		k.ExpirePreprocess(key)
+		v, ok := k.data.Get(key)
+		if !ok || (opt.typ != "" && typeOf(v) != opt.typ) {
+			continue
+		}
+		items = append(items, []byte(key))
+	}
+	return scanReply(cursor, items)
+}
+
+// Keys 返回所有匹配 pattern 的 key
+func (k *KVStore) Keys(cmd *def.Command) def.Reply {
+	args := cmd.Args
+	if len(args) != 1 {
+		return def.NewSyntaxErrReply()
+	}
+
+	opt := scanOption{match: string(args[0])}
+	if opt.match == "*" {
+		opt.match = ""
+	}
+
+	var keys []string
+	k.data.ForEach(func(key string, _ interface{}) bool {
+		if opt.matched(key) {
+			keys = append(keys, key)
+		}
+		return true
+	})
+
+	items := make([][]byte, 0, len(keys))
+	for _, key := range keys {
+		k.ExpirePreprocess(key)
+		if _, ok := k.data.Get(key); ok {
+			items = append(items, []byte(key))
+		}
+	}
+	return def.NewMultiBulkReply(items)
+}
+
+// HScan HSCAN key cursor [MATCH pattern] [COUNT count] [NOVALUES]
+func (k *KVStore) HScan(cmd *def.Command) def.Reply {
+	if len(cmd.Args) < 1 {
+		return def.NewSyntaxErrReply()
+	}
+
+	cursor, opt, err := parseScan(cmd.Cmd, cmd.Args[1:])
+	if err != nil {
+		return def.NewErrReply(err.Error())
+	}
+
+	hmap, err := k.getAsHashMap(string(cmd.Args[0]))
+	if err != nil {
+		return def.NewErrReply(err.Error())
+	}
+	if hmap == nil {
+		return scanReply(0, nil)
+	}
+
+	var items [][]byte
+	cursor = scanLoop(cursor, opt.count, func(cursor uint64) uint64 {
+		return hmap.Scan(cursor, func(field string, value []byte) {
+			if !opt.matched(field) {
+				return
+			}
+			items = append(items, []byte(field))
+			if !opt.noValues {
+				items = append(items, value)
+			}
+		})
+	}, func() int {
+		if opt.noValues {
+			return len(items)
+		}
+		return len(items) / 2
+	})
+	return scanReply(cursor, items)
+}
+
+// SScan SSCAN key cursor [MATCH pattern] [COUNT count]
+func (k *KVStore) SScan(cmd *def.Command) def.Reply {
+	if len(cmd.Args) < 1 {
+		return def.NewSyntaxErrReply()
+	}
+
+	cursor, opt, err := parseScan(cmd.Cmd, cmd.Args[1:])
+	if err != nil {
+		return def.NewErrReply(err.Error())
+	}
+
+	set, err := k.getAsSet(string(cmd.Args[0]))
+	if err != nil {
+		return def.NewErrReply(err.Error())
+	}
+	if set == nil {
+		return scanReply(0, nil)
+	}
+
+	var items [][]byte
+	cursor = scanLoop(cursor, opt.count, func(cursor uint64) uint64 {
+		return set.Scan(cursor, func(member string) {
+			if opt.matched(member) {
+				items = append(items, []byte(member))
+			}
+		})
+	}, func() int { return len(items) })
+	return scanReply(cursor, items)
+}
+
+// ZScan ZSCAN key cursor [MATCH pattern] [COUNT count]
+func (k *KVStore) ZScan(cmd *def.Command) def.Reply {
+	if len(cmd.Args) < 1 {
+		return def.NewSyntaxErrReply()
+	}
+
+	cursor, opt, err := parseScan(cmd.Cmd, cmd.Args[1:])
+	if err != nil {
+		return def.NewErrReply(err.Error())
+	}
+
+	zset, err := k.getAsSortedSet(string(cmd.Args[0]))
+	if err != nil {
+		return def.NewErrReply(err.Error())
+	}
+	if zset == nil {
+		return scanReply(0, nil)
+	}
+
+	var items [][]byte
+	cursor = scanLoop(cursor, opt.count, func(cursor uint64) uint64 {
+		return zset.Scan(cursor, func(member string, score float64) {
+			if opt.matched(member) {
+				items = append(items, []byte(member), []byte(msortedset.FormatScore(score)))
+			}
+		})
+	}, func() int { return len(items) / 2 })
+	return scanReply(cursor, items)
+}
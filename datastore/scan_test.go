@@ -0,0 +1,248 @@
+package datastore
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// scanAll 从游标 0 开始反复执行 prefix cursor opts，直至游标回到 0，返回全部元素及调用次数
+func scanAll(t *testing.T, s *testStore, prefix, opts string) ([]string, int) {
+	t.Helper()
+
+	var (
+		items  []string
+		cursor = "0"
+	)
+	for calls := 1; ; calls++ {
+		reply := s.exec(strings.TrimSpace(prefix + " " + cursor + " " + opts))
+		if !strings.HasPrefix(reply, "*2\r\n") {
+			t.Fatalf("%s %s %s => %q", prefix, cursor, opts, reply)
+		}
+
+		bulks := parseBulks(reply)
+		cursor, items = bulks[0], append(items, bulks[1:]...)
+		if cursor == "0" {
+			return items, calls
+		}
+		if calls > 10000 {
+			t.Fatalf("%s never returned cursor 0", prefix)
+		}
+	}
+}
+
+// sorted 排序后的副本
+func sorted(items []string) []string {
+	res := append([]string(nil), items...)
+	sort.Strings(res)
+	return res
+}
+
+// distinct 去重并排序，遍历可能重复返回元素
+func distinct(items []string) []string {
+	seen := make(map[string]bool)
+	var res []string
+	for _, item := range items {
+		if !seen[item] {
+			seen[item] = true
+			res = append(res, item)
+		}
+	}
+	return sorted(res)
+}
+
+// TestScanCommands 游标遍历指令的选项解析与单次回包
+func TestScanCommands(t *testing.T) {
+	const (
+		syntaxErr = "-Err syntax error\r\n"
+		wrongType = "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"
+		empty     = "*2\r\n$1\r\n0\r\n*0\r\n"
+	)
+
+	tests := []replyCase{
+		// SCAN
+		{"scan empty db", nil, "scan 0", empty},
+		{"scan invalid cursor", nil, "scan x", "-ERR invalid cursor\r\n"},
+		{"scan count zero", nil, "scan 0 count 0", syntaxErr},
+		{"scan count not integer", nil, "scan 0 count x", "-ERR value is not an integer or out of range\r\n"},
+		{"scan match missing pattern", nil, "scan 0 match", syntaxErr},
+		{"scan novalues rejected", nil, "scan 0 novalues", syntaxErr},
+		{"scan huge count", []string{"set a v"}, "scan 0 count 9223372036854775807", "*2\r\n$1\r\n0\r\n*1\r\n$1\r\na\r\n"},
+		{"scan match", []string{"set a1 v", "set b1 v"}, "scan 0 match a*", "*2\r\n$1\r\n0\r\n*1\r\n$2\r\na1\r\n"},
+		{"scan type", []string{"set a v", "rpush l x"}, "scan 0 type list", "*2\r\n$1\r\n0\r\n*1\r\n$1\r\nl\r\n"},
+		{"scan type uppercase", []string{"set a v", "rpush l x"}, "scan 0 TYPE STRING", "*2\r\n$1\r\n0\r\n*1\r\n$1\r\na\r\n"},
+
+		// KEYS
+		{"keys match", []string{"set one 1", "set two 2", "set three 3"}, "keys t??", "*1\r\n$3\r\ntwo\r\n"},
+		{"keys class", []string{"set a1 1", "set c1 3"}, "keys [ab]1", "*1\r\n$2\r\na1\r\n"},
+		{"keys negated class", []string{"set a1 1", "set c1 3"}, "keys [^ab]1", "*1\r\n$2\r\nc1\r\n"},
+		{"keys no match", []string{"set a 1"}, "keys z*", "*0\r\n"},
+		{"keys arity", nil, "keys", syntaxErr},
+
+		// HSCAN，listpack 编码一次返回全部字段，游标为 0
+		{"hscan missing key", nil, "hscan h 0", empty},
+		{"hscan wrong type", []string{"set h v"}, "hscan h 0", wrongType},
+		{"hscan compact", []string{"hset h a 1"}, "hscan h 0 count 1", "*2\r\n$1\r\n0\r\n*2\r\n$1\r\na\r\n$1\r\n1\r\n"},
+		{"hscan novalues", []string{"hset h a 1"}, "hscan h 0 novalues", "*2\r\n$1\r\n0\r\n*1\r\n$1\r\na\r\n"},
+		{"hscan match", []string{"hset h a 1 b 2"}, "hscan h 0 match b", "*2\r\n$1\r\n0\r\n*2\r\n$1\r\nb\r\n$1\r\n2\r\n"},
+		{"hscan type rejected", []string{"hset h a 1"}, "hscan h 0 type hash", syntaxErr},
+
+		// SSCAN，intset 与 listpack 编码一次返回全部成员
+		{"sscan missing key", nil, "sscan s 0", empty},
+		{"sscan wrong type", []string{"set s v"}, "sscan s 0", wrongType},
+		{"sscan intset", []string{"sadd s 1"}, "sscan s 0 count 1", "*2\r\n$1\r\n0\r\n*1\r\n$1\r\n1\r\n"},
+		{"sscan listpack", []string{"sadd s a"}, "sscan s 0 count 1", "*2\r\n$1\r\n0\r\n*1\r\n$1\r\na\r\n"},
+		{"sscan match", []string{"sadd s a b"}, "sscan s 0 match a", "*2\r\n$1\r\n0\r\n*1\r\n$1\r\na\r\n"},
+		{"sscan novalues rejected", []string{"sadd s a"}, "sscan s 0 novalues", syntaxErr},
+
+		// ZSCAN
+		{"zscan missing key", nil, "zscan z 0", empty},
+		{"zscan wrong type", []string{"set z v"}, "zscan z 0", wrongType},
+		{"zscan", []string{"zadd z 1.5 a"}, "zscan z 0", "*2\r\n$1\r\n0\r\n*2\r\n$1\r\na\r\n$3\r\n1.5\r\n"},
+		{"zscan match", []string{"zadd z 1 a 2 b"}, "zscan z 0 match b", "*2\r\n$1\r\n0\r\n*2\r\n$1\r\nb\r\n$1\r\n2\r\n"},
+	}
+
+	runReplyCases(t, tests)
+}
+
+// TestScanIteration 多次调用遍历完整个 key 空间或聚合类型，遍历全程存在的元素至少返回一次
+func TestScanIteration(t *testing.T) {
+	const n = 300 // 超过紧凑编码阈值，走哈希表游标遍历
+
+	var (
+		keys, names, ints         []string
+		hset, sadd, saddInt, zadd = []string{"hset h"}, []string{"sadd s"}, []string{"sadd i"}, []string{"zadd z"}
+	)
+	for i := 0; i < n; i++ {
+		name := "m" + strconv.Itoa(i)
+		keys = append(keys, "k"+strconv.Itoa(i))
+		names = append(names, name)
+		ints = append(ints, strconv.Itoa(i))
+		hset = append(hset, name, "v")
+		sadd = append(sadd, name)
+		saddInt = append(saddInt, strconv.Itoa(i))
+		zadd = append(zadd, strconv.Itoa(i), name)
+	}
+
+	setup := func(t *testing.T) *testStore {
+		s := newTestStore(t)
+		for _, key := range keys {
+			s.exec("set " + key + " v")
+		}
+		s.exec(strings.Join(hset, " "))
+		s.exec(strings.Join(sadd, " "))
+		s.exec(strings.Join(saddInt, " "))
+		s.exec(strings.Join(zadd, " "))
+		return s
+	}
+
+	t.Run("scan", func(t *testing.T) {
+		s := setup(t)
+		got, calls := scanAll(t, s, "scan", "count 10")
+		want := sorted(append(append([]string(nil), keys...), "h", "s", "i", "z"))
+		if g := distinct(got); strings.Join(g, ",") != strings.Join(want, ",") {
+			t.Errorf("scan returned %d distinct keys, want %d", len(g), len(want))
+		}
+		if calls < 2 {
+			t.Errorf("scan count 10 over %d keys finished in %d call", len(want), calls)
+		}
+	})
+
+	t.Run("scan match and type", func(t *testing.T) {
+		s := setup(t)
+		got, _ := scanAll(t, s, "scan", "match k1? type string")
+		if g := distinct(got); len(g) != 10 || g[0] != "k10" || g[9] != "k19" {
+			t.Errorf("scan match k1? => %q", g)
+		}
+
+		got, _ = scanAll(t, s, "scan", "type zset")
+		if g := distinct(got); len(g) != 1 || g[0] != "z" {
+			t.Errorf("scan type zset => %q", g)
+		}
+	})
+
+	t.Run("keys", func(t *testing.T) {
+		s := setup(t)
+		if got := parseBulks(s.exec("keys k*")); len(got) != n {
+			t.Errorf("keys k* => %d keys, want %d", len(got), n)
+		}
+	})
+
+	t.Run("hscan", func(t *testing.T) {
+		s := setup(t)
+		got, calls := scanAll(t, s, "hscan h", "novalues")
+		if g := distinct(got); strings.Join(g, ",") != strings.Join(sorted(names), ",") || calls < 2 {
+			t.Errorf("hscan novalues => %d distinct fields in %d calls", len(g), calls)
+		}
+
+		got, _ = scanAll(t, s, "hscan h", "match m1?")
+		if len(got) != 20 || got[1] != "v" {
+			t.Errorf("hscan match m1? => %q", got)
+		}
+	})
+
+	t.Run("sscan", func(t *testing.T) {
+		s := setup(t)
+		got, calls := scanAll(t, s, "sscan s", "count 5")
+		if g := distinct(got); strings.Join(g, ",") != strings.Join(sorted(names), ",") || calls < 2 {
+			t.Errorf("sscan => %d distinct members in %d calls", len(g), calls)
+		}
+
+		// intset 编码不受 COUNT 限制，一次返回全部成员
+		got, calls = scanAll(t, s, "sscan i", "count 1")
+		if g := distinct(got); strings.Join(g, ",") != strings.Join(sorted(ints), ",") || calls != 1 {
+			t.Errorf("sscan on intset => %d distinct members in %d calls", len(g), calls)
+		}
+	})
+
+	t.Run("zscan", func(t *testing.T) {
+		s := setup(t)
+		got, calls := scanAll(t, s, "zscan z", "")
+		var names []string
+		for i := 0; i < len(got); i += 2 {
+			names = append(names, got[i])
+			if got[i+1] != got[i][1:] {
+				t.Errorf("zscan %s => score %s", got[i], got[i+1])
+			}
+		}
+		if g := distinct(names); strings.Join(g, ",") != strings.Join(sorted(names), ",") || calls < 2 {
+			t.Errorf("zscan => %d distinct members in %d calls", len(g), calls)
+		}
+	})
+
+	t.Run("keys written during scan", func(t *testing.T) {
+		s := setup(t)
+
+		// 每次调用之间写入并删除大量 key，触发扩容与缩容，初始的 key 至少返回一次
+		var (
+			seen   = make(map[string]bool)
+			cursor = "0"
+		)
+		for round := 0; ; round++ {
+			bulks := parseBulks(s.exec("scan " + cursor + " count 20"))
+			cursor = bulks[0]
+			for _, key := range bulks[1:] {
+				seen[key] = true
+			}
+			if cursor == "0" {
+				break
+			}
+
+			for i := 0; i < 50; i++ {
+				key := "tmp" + strconv.Itoa(round*50+i)
+				if round < 5 {
+					s.exec("set " + key + " v")
+				} else {
+					s.exec("del tmp" + strconv.Itoa((round-5)*50+i))
+				}
+			}
+		}
+
+		for _, key := range keys {
+			if !seen[key] {
+				t.Errorf("%s never returned by scan", key)
+			}
+		}
+	})
+}
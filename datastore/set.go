@@ -0,0 +1,527 @@
+package datastore
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	mset "github.com/lovelydayss/goredis/datastruct/set"
+	def "github.com/lovelydayss/goredis/interface"
+)
+
+// set 类型指令
+
+// SAdd SADD key member [member ...]，返回新增的成员数
+func (k *KVStore) SAdd(cmd *def.Command) def.Reply {
+	args := cmd.Args
+	if len(args) < 2 {
+		return def.NewSyntaxErrReply()
+	}
+
+	key := string(args[0])
+	set, err := k.getAsSet(key)
+	if err != nil {
+		return def.NewErrReply(err.Error())
+	}
+
+	if set == nil {
+		set = mset.NewSetEntity(key, &k.setOptions)
+		k.putAsSet(key, set)
+	}
+
+	var added int64
+	for _, arg := range args[1:] {
+		added += set.Add(string(arg))
+	}
+
+	if added > 0 {
+		k.persister.PersistCmd(cmd.Ctx, cmd.GetCmd()) // 持久化
+	}
+	return def.NewIntReply(added)
+}
+
+// SIsMember 成员是否存在
+func (k *KVStore) SIsMember(cmd *def.Command) def.Reply {
+	args := cmd.Args
+	if len(args) != 2 {
+		return def.NewSyntaxErrReply()
+	}
+
+	set, err := k.getAsSet(string(args[0]))
+	if err != nil {
+		return def.NewErrReply(err.Error())
+	}
+
+	if set == nil {
+		return def.NewIntReply(0)
+	}
+	return def.NewIntReply(set.Exist(string(args[1])))
+}
+
+// SMIsMember 批量判断成员是否存在
+func (k *KVStore) SMIsMember(cmd *def.Command) def.Reply {
+	args := cmd.Args
+	if len(args) < 2 {
+		return def.NewSyntaxErrReply()
+	}
+
+	set, err := k.getAsSet(string(args[0]))
+	if err != nil {
+		return def.NewErrReply(err.Error())
+	}
+
+	results := make([]int64, len(args)-1)
+	if set != nil {
+		for i, member := range args[1:] {
+			results[i] = set.Exist(string(member))
+		}
+	}
+	return intArrayReply(results)
+}
+
+// SRem 删除成员，返回删除的成员数，集合为空时删除 key
+func (k *KVStore) SRem(cmd *def.Command) def.Reply {
+	args := cmd.Args
+	if len(args) < 2 {
+		return def.NewSyntaxErrReply()
+	}
+
+	key := string(args[0])
+	set, err := k.getAsSet(key)
+	if err != nil {
+		return def.NewErrReply(err.Error())
+	}
+
+	if set == nil {
+		return def.NewIntReply(0)
+	}
+
+	var remed int64
+	for _, arg := range args[1:] {
+		remed += set.Rem(string(arg))
+	}
+
+	if remed > 0 {
+		k.removeIfEmptySet(key, set)
+		k.persister.PersistCmd(cmd.Ctx, cmd.GetCmd()) // 持久化
+	}
+	return def.NewIntReply(remed)
+}
+
+// SMembers 获取全部成员，key 不存在时返回空数组
+func (k *KVStore) SMembers(cmd *def.Command) def.Reply {
+	if len(cmd.Args) != 1 {
+		return def.NewSyntaxErrReply()
+	}
+
+	set, err := k.getAsSet(string(cmd.Args[0]))
+	if err != nil {
+		return def.NewErrReply(err.Error())
+	}
+
+	if set == nil {
+		return def.NewEmptyMultiBulkReply()
+	}
+	return def.NewMultiBulkReply(setMembers(set))
+}
+
+// SCard 成员数，key 不存在时返回 0
+func (k *KVStore) SCard(cmd *def.Command) def.Reply {
+	if len(cmd.Args) != 1 {
+		return def.NewSyntaxErrReply()
+	}
+
+	set, err := k.getAsSet(string(cmd.Args[0]))
+	if err != nil {
+		return def.NewErrReply(err.Error())
+	}
+
+	if set == nil {
+		return def.NewIntReply(0)
+	}
+	return def.NewIntReply(set.Len())
+}
+
+// SMove SMOVE source destination member，将成员从 source 移动到 destination
+func (k *KVStore) SMove(cmd *def.Command) def.Reply {
+	args := cmd.Args
+	if len(args) != 3 {
+		return def.NewSyntaxErrReply()
+	}
+
+	src, dst, member := string(args[0]), string(args[1]), string(args[2])
+	srcSet, err := k.getAsSet(src)
+	if err != nil {
+		return def.NewErrReply(err.Error())
+	}
+
+	// 先校验目标类型，避免移出后无法加入导致成员丢失
+	dstSet, err := k.getAsSet(dst)
+	if err != nil {
+		return def.NewErrReply(err.Error())
+	}
+
+	if srcSet == nil || srcSet.Exist(member) == 0 {
+		return def.NewIntReply(0)
+	}
+	if src == dst {
+		return def.NewIntReply(1)
+	}
+
+	srcSet.Rem(member)
+	if dstSet == nil {
+		dstSet = mset.NewSetEntity(dst, &k.setOptions)
+		k.putAsSet(dst, dstSet)
+	}
+	dstSet.Add(member)
+
+	k.removeIfEmptySet(src, srcSet)
+	k.persister.PersistCmd(cmd.Ctx, cmd.GetCmd()) // 持久化
+	return def.NewIntReply(1)
+}
+
+// SPop SPOP key [count]，随机弹出成员
+// 弹出结果随机，持久化时改写为 srem key member [member ...]，保证重放结果一致
+func (k *KVStore) SPop(cmd *def.Command) def.Reply {
+	args := cmd.Args
+	if len(args) < 1 || len(args) > 2 {
+		return def.NewSyntaxErrReply()
+	}
+
+	key := string(args[0])
+	withCount, cnt := len(args) == 2, int64(1)
+	if withCount {
+		var err error
+		if cnt, err = strconv.ParseInt(string(args[1]), 10, 64); err != nil || cnt < 0 {
+			return def.NewErrReply("ERR value is out of range, must be positive")
+		}
+	}
+
+	set, err := k.getAsSet(key)
+	if err != nil {
+		return def.NewErrReply(err.Error())
+	}
+
+	if set == nil {
+		if withCount {
+			return def.NewEmptyMultiBulkReply()
+		}
+		return def.NewNillReply()
+	}
+
+	var popped []string
+	if size := set.Len(); cnt >= size {
+		popped = make([]string, 0, size)
+		set.ForEach(func(member string) bool {
+			popped = append(popped, member)
+			return true
+		})
+	} else if cnt > 0 {
+		popped = sampleDistinct(size, cnt, setForEach(set), set.RandomMember)
+	}
+
+	members := make([][]byte, 0, len(popped))
+	for _, member := range popped {
+		set.Rem(member)
+		members = append(members, []byte(member))
+	}
+
+	if len(members) > 0 {
+		k.removeIfEmptySet(key, set)
+		k.persister.PersistCmd(cmd.Ctx, append([][]byte{[]byte(def.CmdTypeSRem), args[0]}, members...)) // 持久化
+	}
+
+	if !withCount {
+		return def.NewBulkReply(members[0])
+	}
+	return def.NewMultiBulkReply(members)
+}
+
+// SRandMember SRANDMEMBER key [count]，随机返回成员，不修改集合，无需持久化
+// count 为正数时返回至多 count 个不重复成员，为负数时返回 |count| 个成员且允许重复
+func (k *KVStore) SRandMember(cmd *def.Command) def.Reply {
+	args := cmd.Args
+	if len(args) < 1 || len(args) > 2 {
+		return def.NewSyntaxErrReply()
+	}
+
+	set, err := k.getAsSet(string(args[0]))
+	if err != nil {
+		return def.NewErrReply(err.Error())
+	}
+
+	if len(args) == 1 {
+		if set == nil {
+			return def.NewNillReply()
+		}
+		return def.NewBulkReply([]byte(set.RandomMember()))
+	}
+
+	cnt, err := strconv.ParseInt(string(args[1]), 10, 64)
+	if err != nil {
+		return def.NewErrReply("ERR value is not an integer or out of range")
+	}
+
+	if set == nil || cnt == 0 {
+		return def.NewEmptyMultiBulkReply()
+	}
+
+	var members [][]byte
+	switch size := set.Len(); {
+	// 允许重复，逐个随机选取
+	case cnt < 0:
+		members, err = sampleRepeated(cnt, func() [][]byte {
+			return [][]byte{[]byte(set.RandomMember())}
+		})
+		if err != nil {
+			return def.NewErrReply(err.Error())
+		}
+
+	// 不重复且 count 不小于成员数，返回全部成员
+	case cnt >= size:
+		members = setMembers(set)
+
+	// 不重复且 count 小于成员数，随机选取
+	default:
+		for _, member := range sampleDistinct(size, cnt, setForEach(set), set.RandomMember) {
+			members = append(members, []byte(member))
+		}
+	}
+	return def.NewMultiBulkReply(members)
+}
+
+// SInter 多个集合的交集
+func (k *KVStore) SInter(cmd *def.Command) def.Reply {
+	return k.setAlgebra(cmd, sinter)
+}
+
+// SUnion 多个集合的并集
+func (k *KVStore) SUnion(cmd *def.Command) def.Reply {
+	return k.setAlgebra(cmd, sunion)
+}
+
+// SDiff 第一个集合与其余集合的差集
+func (k *KVStore) SDiff(cmd *def.Command) def.Reply {
+	return k.setAlgebra(cmd, sdiff)
+}
+
+// SInterStore 交集结果写入 destination，返回结果成员数
+func (k *KVStore) SInterStore(cmd *def.Command) def.Reply {
+	return k.setAlgebraStore(cmd, sinter)
+}
+
+// SUnionStore 并集结果写入 destination，返回结果成员数
+func (k *KVStore) SUnionStore(cmd *def.Command) def.Reply {
+	return k.setAlgebraStore(cmd, sunion)
+}
+
+// SDiffStore 差集结果写入 destination，返回结果成员数
+func (k *KVStore) SDiffStore(cmd *def.Command) def.Reply {
+	return k.setAlgebraStore(cmd, sdiff)
+}
+
+// SInterCard SINTERCARD numkeys key [key ...] [LIMIT limit]
+// 返回交集的成员数，limit 大于 0 时计数达到 limit 即停止
+func (k *KVStore) SInterCard(cmd *def.Command) def.Reply {
+	args := cmd.Args
+	if len(args) < 2 {
+		return def.NewSyntaxErrReply()
+	}
+
+	numKeys, err := strconv.ParseInt(string(args[0]), 10, 64)
+	if err != nil {
+		return def.NewErrReply("ERR value is not an integer or out of range")
+	}
+	if numKeys <= 0 {
+		return def.NewErrReply("ERR numkeys should be greater than 0")
+	}
+	if numKeys > int64(len(args)-1) {
+		return def.NewErrReply("ERR Number of keys can't be greater than number of args")
+	}
+
+	var limit int64
+	switch rest := args[numKeys+1:]; {
+	case len(rest) == 0:
+	case len(rest) == 2 && strings.ToLower(string(rest[0])) == "limit":
+		if limit, err = strconv.ParseInt(string(rest[1]), 10, 64); err != nil {
+			return def.NewErrReply("ERR value is not an integer or out of range")
+		}
+		if limit < 0 {
+			return def.NewErrReply("ERR LIMIT can't be negative")
+		}
+	default:
+		return def.NewSyntaxErrReply()
+	}
+
+	sets, err := k.getSets(args[1 : numKeys+1])
+	if err != nil {
+		return def.NewErrReply(err.Error())
+	}
+
+	var card int64
+	sinterEach(sets, func(string) bool {
+		card++
+		return limit == 0 || card < limit
+	})
+	return def.NewIntReply(card)
+}
+
+// setAlgebra 集合运算实际执行，不存在的 key 视为空集合
+func (k *KVStore) setAlgebra(cmd *def.Command, op func(sets []mset.Set) []string) def.Reply {
+	if len(cmd.Args) < 1 {
+		return def.NewSyntaxErrReply()
+	}
+
+	sets, err := k.getSets(cmd.Args)
+	if err != nil {
+		return def.NewErrReply(err.Error())
+	}
+
+	result := op(sets)
+	members := make([][]byte, 0, len(result))
+	for _, member := range result {
+		members = append(members, []byte(member))
+	}
+	return def.NewMultiBulkReply(members)
+}
+
+// setAlgebraStore 集合运算结果写入 destination，覆盖原有的值及过期时间，结果为空时删除 destination
+// 集合运算结果确定，以原指令持久化，重放结果一致
+func (k *KVStore) setAlgebraStore(cmd *def.Command, op func(sets []mset.Set) []string) def.Reply {
+	args := cmd.Args
+	if len(args) < 2 {
+		return def.NewSyntaxErrReply()
+	}
+
+	sets, err := k.getSets(args[1:])
+	if err != nil {
+		return def.NewErrReply(err.Error())
+	}
+
+	// 先完成运算，destination 可能同时是参与运算的集合
+	result := op(sets)
+	dst := string(args[0])
+	k.remove(dst)
+	if len(result) > 0 {
+		set := mset.NewSetEntity(dst, &k.setOptions)
+		for _, member := range result {
+			set.Add(member)
+		}
+		k.putAsSet(dst, set)
+	}
+
+	k.persister.PersistCmd(cmd.Ctx, cmd.GetCmd()) // 持久化
+	return def.NewIntReply(int64(len(result)))
+}
+
+// getSets 批量获取集合，不存在的 key 对应 nil，任一 key 类型错误时返回错误
+func (k *KVStore) getSets(keys [][]byte) ([]mset.Set, error) {
+	sets := make([]mset.Set, 0, len(keys))
+	for _, key := range keys {
+		set, err := k.getAsSet(string(key))
+		if err != nil {
+			return nil, err
+		}
+		sets = append(sets, set)
+	}
+	return sets, nil
+}
+
+// sinter 交集
+func sinter(sets []mset.Set) []string {
+	result := []string{}
+	sinterEach(sets, func(member string) bool {
+		result = append(result, member)
+		return true
+	})
+	return result
+}
+
+// sinterEach 逐个处理交集成员，fn 返回 false 时停止
+// 从最小的集合开始遍历，逐个成员判断是否存在于其余集合中
+func sinterEach(sets []mset.Set, fn func(member string) bool) {
+	for _, set := range sets {
+		if set == nil {
+			return
+		}
+	}
+
+	sorted := append([]mset.Set{}, sets...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Len() < sorted[j].Len()
+	})
+
+	sorted[0].ForEach(func(member string) bool {
+		for _, other := range sorted[1:] {
+			if other.Exist(member) == 0 {
+				return true
+			}
+		}
+		return fn(member)
+	})
+}
+
+// sunion 并集
+func sunion(sets []mset.Set) []string {
+	seen := make(map[string]struct{})
+	result := []string{}
+	for _, set := range sets {
+		if set == nil {
+			continue
+		}
+		set.ForEach(func(member string) bool {
+			if _, ok := seen[member]; !ok {
+				seen[member] = struct{}{}
+				result = append(result, member)
+			}
+			return true
+		})
+	}
+	return result
+}
+
+// sdiff 第一个集合中不存在于其余集合的成员
+func sdiff(sets []mset.Set) []string {
+	result := []string{}
+	if sets[0] == nil {
+		return result
+	}
+
+	sets[0].ForEach(func(member string) bool {
+		for _, other := range sets[1:] {
+			if other != nil && other.Exist(member) == 1 {
+				return true
+			}
+		}
+		result = append(result, member)
+		return true
+	})
+	return result
+}
+
+// setMembers 集合的全部成员
+func setMembers(set mset.Set) [][]byte {
+	members := make([][]byte, 0, set.Len())
+	set.ForEach(func(member string) bool {
+		members = append(members, []byte(member))
+		return true
+	})
+	return members
+}
+
+// setForEach 适配 sampleDistinct 的遍历函数
+func setForEach(set mset.Set) func(fn func(member string)) {
+	return func(fn func(member string)) {
+		set.ForEach(func(member string) bool {
+			fn(member)
+			return true
+		})
+	}
+}
+
+// removeIfEmptySet 集合为空时删除 key，与 redis 一致不保留空集合
+func (k *KVStore) removeIfEmptySet(key string, set mset.Set) {
+	if set.Len() == 0 {
+		k.remove(key)
+	}
+}
@@ -0,0 +1,90 @@
+package datastore
+
+import "testing"
+
+// TestSetConformance 集合指令与 redis 行为的一致性
+// 集合成员无序，多成员结果通过 *STORE、SCARD 及 SMISMEMBER 校验
+func TestSetConformance(t *testing.T) {
+	const (
+		nilBulk  = "$-1\r\n"
+		empty    = "*0\r\n"
+		wrongTyp = "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"
+		a        = "sadd a 1 2 3 4"
+		b        = "sadd b 3 4 5"
+		c        = "sadd c 4 5 6"
+	)
+
+	tests := []replyCase{
+		{"sadd returns added", []string{a}, "sadd a 1 9", ":1\r\n"},
+		{"scard", []string{a}, "scard a", ":4\r\n"},
+		{"scard missing key", nil, "scard a", ":0\r\n"},
+		{"smembers missing key", nil, "smembers a", empty},
+		{"smembers single", []string{"sadd a x"}, "smembers a", "*1\r\n$1\r\nx\r\n"},
+		{"smismember", []string{a}, "smismember a 1 9 4", "*3\r\n:1\r\n:0\r\n:1\r\n"},
+		{"smismember missing key", nil, "smismember a 1", "*1\r\n:0\r\n"},
+
+		// 集合运算
+		{"sinter", []string{a, b, c}, "sinter a b c", "*1\r\n$1\r\n4\r\n"},
+		{"sinter missing key", []string{a}, "sinter a none", empty},
+		{"sinterstore", []string{a, b}, "sinterstore d a b", ":2\r\n"},
+		{"sinterstore members", []string{a, b, "sinterstore d a b"}, "smismember d 2 3 4", "*3\r\n:0\r\n:1\r\n:1\r\n"},
+		{"sunionstore", []string{a, b, c}, "sunionstore d a b c", ":6\r\n"},
+		{"sunionstore into source", []string{a, c}, "sunionstore a a c", ":6\r\n"},
+		{"sdiffstore", []string{a, b, c}, "sdiffstore d a b c", ":2\r\n"},
+		{"sdiffstore members", []string{a, b, c, "sdiffstore d a b c"}, "smismember d 1 2 3", "*3\r\n:1\r\n:1\r\n:0\r\n"},
+		{"sdiffstore empty deletes dst", []string{a, "sdiffstore d none a"}, "exists d", ":0\r\n"},
+		{"sinter wrong type", []string{a, "set s v"}, "sinter a s", wrongTyp},
+
+		// SINTERCARD
+		{"sintercard", []string{a, b}, "sintercard 2 a b", ":2\r\n"},
+		{"sintercard limit", []string{a, b}, "sintercard 2 a b limit 1", ":1\r\n"},
+		{"sintercard limit zero", []string{a, b}, "sintercard 2 a b limit 0", ":2\r\n"},
+		{"sintercard numkeys zero", []string{a}, "sintercard 0 a", "-ERR numkeys should be greater than 0\r\n"},
+		{"sintercard too many keys", []string{a}, "sintercard 3 a b", "-ERR Number of keys can't be greater than number of args\r\n"},
+		{"sintercard negative limit", []string{a}, "sintercard 1 a limit -1", "-ERR LIMIT can't be negative\r\n"},
+
+		// SMOVE
+		{"smove", []string{a, b}, "smove a b 1", ":1\r\n"},
+		{"smove missing member", []string{a, b}, "smove a b 9", ":0\r\n"},
+		{"smove missing source", []string{b}, "smove none b 1", ":0\r\n"},
+		{"smove wrong type dst", []string{a, "set s v"}, "smove a s 1", wrongTyp},
+		{"smove last member deletes source", []string{"sadd one x", "smove one b x"}, "exists one", ":0\r\n"},
+
+		// SPOP / SRANDMEMBER
+		{"spop missing key", nil, "spop a", nilBulk},
+		{"spop count missing key", nil, "spop a 2", empty},
+		{"spop negative count", []string{a}, "spop a -1", "-ERR value is out of range, must be positive\r\n"},
+		{"spop single", []string{"sadd a x"}, "spop a", "$1\r\nx\r\n"},
+		{"spop count leaves rest", []string{a, "spop a 3"}, "scard a", ":1\r\n"},
+		{"spop all deletes key", []string{a, "spop a 10"}, "exists a", ":0\r\n"},
+		{"srandmember single", []string{"sadd a x"}, "srandmember a 3", "*1\r\n$1\r\nx\r\n"},
+		{"srandmember negative count repeats", []string{"sadd a x"}, "srandmember a -2", "*2\r\n$1\r\nx\r\n$1\r\nx\r\n"},
+		{"srandmember negative count out of range", []string{"sadd a x y"}, "srandmember a -9223372036854775807", "-ERR value is out of range\r\n"},
+		{"srandmember min int64", []string{"sadd a x y"}, "srandmember a -9223372036854775808", "-ERR value is out of range\r\n"},
+		{"srandmember keeps members", []string{a, "srandmember a 2"}, "scard a", ":4\r\n"},
+	}
+
+	runReplyCases(t, tests)
+}
+
+// TestSetPersistence 随机弹出的成员以实际结果持久化，重放后集合一致
+func TestSetPersistence(t *testing.T) {
+	s := newTestStore(t)
+	for _, line := range []string{
+		"sadd big 1 2 3 4 5 6 7 8 9 10 11 12 13 14 15 16 17 18 19 20",
+		"spop big 3",
+		"spop big",
+		"sadd a 1 2 3",
+		"smove a b 2",
+		"sinterstore c big a",
+	} {
+		s.exec(line)
+	}
+
+	const members = " 1 2 3 4 5 6 7 8 9 10 11 12 13 14 15 16 17 18 19 20"
+	var queries []string
+	for _, key := range []string{"big", "a", "b", "c"} {
+		queries = append(queries, "sintercard 2 "+key+" "+key, "smismember "+key+members)
+	}
+	assertReplayed(t, s, queries...)
+}
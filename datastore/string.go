@@ -0,0 +1,565 @@
+package datastore
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	mstring "github.com/lovelydayss/goredis/datastruct/string"
+	def "github.com/lovelydayss/goredis/interface"
+)
+
+// string 类型指令
+
+// maxStringSize 字符串长度上限，与 redis proto-max-bulk-len 默认值一致
+const maxStringSize = 512 << 20
+
+// Get String 类型 Get 实现
+func (k *KVStore) Get(cmd *def.Command) def.Reply {
+	args := cmd.Args
+	if len(args) != 1 {
+		return def.NewSyntaxErrReply()
+	}
+
+	key := string(args[0])
+	v, err := k.getAsString(key)
+	if err != nil {
+		return def.NewErrReply(err.Error())
+	}
+	if v == nil {
+		return def.NewNillReply()
+	}
+	return def.NewBulkReply(v.Bytes())
+}
+
+// MGet 批量获取，key 不存在或不是字符串类型时对应位置返回 nil
+func (k *KVStore) MGet(cmd *def.Command) def.Reply {
+	args := cmd.Args
+	if len(args) < 1 {
+		return def.NewSyntaxErrReply()
+	}
+
+	res := make([][]byte, 0, len(args))
+	for _, arg := range args {
+		v, err := k.getAsString(string(arg))
+		if err != nil || v == nil {
+			res = append(res, nil)
+			continue
+		}
+		res = append(res, v.Bytes())
+	}
+
+	return def.NewMultiBulkReply(res)
+}
+
+// setOption SET 指令选项
+type setOption struct {
+	nx, xx   bool       // 仅在 key 不存在 / 存在时写入
+	get      bool       // 返回原值
+	keepTTL  bool       // 保留原有的过期时间
+	expireAt *time.Time // 过期时间
+}
+
+// Set SET key value [NX | XX] [GET] [EX seconds | PX milliseconds | EXAT unix-time-seconds | PXAT unix-time-milliseconds | KEEPTTL]
+func (k *KVStore) Set(cmd *def.Command) def.Reply {
+	args := cmd.Args
+	if len(args) < 2 {
+		return def.NewSyntaxErrReply()
+	}
+
+	key, value := string(args[0]), args[1]
+
+	var opt setOption
+	for i := 2; i < len(args); i++ {
+		flag := strings.ToLower(string(args[i]))
+		switch flag {
+		case "nx":
+			if opt.xx {
+				return def.NewSyntaxErrReply()
+			}
+			opt.nx = true
+		case "xx":
+			if opt.nx {
+				return def.NewSyntaxErrReply()
+			}
+			opt.xx = true
+		case "get":
+			opt.get = true
+		case "keepttl":
+			if opt.expireAt != nil {
+				return def.NewSyntaxErrReply()
+			}
+			opt.keepTTL = true
+		case "ex", "px", "exat", "pxat":
+			// 过期参数只允许出现一次，且与 keepttl 互斥
+			if opt.expireAt != nil || opt.keepTTL || i == len(args)-1 {
+				return def.NewSyntaxErrReply()
+			}
+			at, err := parseExpireAt(flag, args[i+1])
+			if err != nil {
+				return def.NewErrReply(err.Error())
+			}
+
+			opt.expireAt = &at
+			i++
+		default:
+			return def.NewSyntaxErrReply()
+		}
+	}
+
+	old, ok, err := k.set(cmd.Ctx, key, value, opt)
+	if err != nil {
+		return def.NewErrReply(err.Error())
+	}
+
+	switch {
+	case opt.get:
+		return def.NewBulkReply(old)
+	case !ok:
+		return def.NewNillReply()
+	default:
+		return def.NewOKReply()
+	}
+}
+
+// set SET 语义实际执行，返回原值（opt.get 时）及是否写入
+// 原值不是字符串类型时，与 redis 一致，带 GET 选项的写入会被拒绝
+func (k *KVStore) set(ctx context.Context, key string, value []byte, opt setOption) ([]byte, bool, error) {
+	_, exists := k.lookup(key)
+
+	var old []byte
+	if opt.get && exists {
+		str, err := k.getAsString(key)
+		if err != nil {
+			return nil, false, err
+		}
+		old = str.Bytes()
+	}
+
+	if (opt.nx && exists) || (opt.xx && !exists) {
+		return old, false, nil
+	}
+
+	// 设置，keepttl 时保留原有的过期时间
+	oldExpireAt, withTTL := k.expiredAt[key]
+	k.put(key, string(value))
+
+	// 持久化时统一改写为毫秒级绝对时间，保证重放结果一致
+	_cmd := [][]byte{[]byte(def.CmdTypeSet), []byte(key), value}
+	switch {
+	case opt.expireAt != nil:
+		k.expire(key, *opt.expireAt)
+		_cmd = append(_cmd, []byte("pxat"), []byte(strconv.FormatInt(opt.expireAt.UnixMilli(), 10)))
+	case opt.keepTTL && withTTL:
+		k.expire(key, oldExpireAt)
+		_cmd = append(_cmd, []byte("keepttl"))
+	}
+
+	k.persister.PersistCmd(ctx, _cmd) // 持久化
+	return old, true, nil
+}
+
+// SetNX key 不存在时写入，返回是否写入
+func (k *KVStore) SetNX(cmd *def.Command) def.Reply {
+	args := cmd.Args
+	if len(args) != 2 {
+		return def.NewSyntaxErrReply()
+	}
+
+	_, ok, _ := k.set(cmd.Ctx, string(args[0]), args[1], setOption{nx: true})
+	if !ok {
+		return def.NewIntReply(0)
+	}
+	return def.NewIntReply(1)
+}
+
+// SetEX 写入并设置过期时间，单位秒
+func (k *KVStore) SetEX(cmd *def.Command) def.Reply {
+	return k.setWithExpire(cmd, "ex")
+}
+
+// PSetEX 写入并设置过期时间，单位毫秒
+func (k *KVStore) PSetEX(cmd *def.Command) def.Reply {
+	return k.setWithExpire(cmd, "px")
+}
+
+// setWithExpire SETEX / PSETEX 实际执行，参数顺序为 key ttl value
+func (k *KVStore) setWithExpire(cmd *def.Command, unit string) def.Reply {
+	args := cmd.Args
+	if len(args) != 3 {
+		return def.NewSyntaxErrReply()
+	}
+
+	expireAt, err := parseExpireAt(unit, args[1])
+	if err != nil {
+		return def.NewErrReply(err.Error())
+	}
+
+	_, _, _ = k.set(cmd.Ctx, string(args[0]), args[2], setOption{expireAt: &expireAt})
+	return def.NewOKReply()
+}
+
+// GetSet 写入并返回原值，原值不是字符串类型时报错
+func (k *KVStore) GetSet(cmd *def.Command) def.Reply {
+	args := cmd.Args
+	if len(args) != 2 {
+		return def.NewSyntaxErrReply()
+	}
+
+	old, _, err := k.set(cmd.Ctx, string(args[0]), args[1], setOption{get: true})
+	if err != nil {
+		return def.NewErrReply(err.Error())
+	}
+	return def.NewBulkReply(old)
+}
+
+// GetDel 获取并删除 key
+func (k *KVStore) GetDel(cmd *def.Command) def.Reply {
+	args := cmd.Args
+	if len(args) != 1 {
+		return def.NewSyntaxErrReply()
+	}
+
+	key := string(args[0])
+	str, err := k.getAsString(key)
+	if err != nil {
+		return def.NewErrReply(err.Error())
+	}
+	if str == nil {
+		return def.NewNillReply()
+	}
+
+	k.remove(key)
+	k.persister.PersistCmd(cmd.Ctx, [][]byte{[]byte(def.CmdTypeDel), []byte(key)}) // 持久化
+	return def.NewBulkReply(str.Bytes())
+}
+
+// GetEX GETEX key [EX seconds | PX milliseconds | EXAT unix-time-seconds | PXAT unix-time-milliseconds | PERSIST]
+// 获取 key 的同时修改其过期时间
+func (k *KVStore) GetEX(cmd *def.Command) def.Reply {
+	args := cmd.Args
+	if len(args) < 1 {
+		return def.NewSyntaxErrReply()
+	}
+
+	key := string(args[0])
+
+	var (
+		expireAt *time.Time
+		persist  bool
+	)
+	for i := 1; i < len(args); i++ {
+		flag := strings.ToLower(string(args[i]))
+		switch flag {
+		case "persist":
+			if expireAt != nil || persist {
+				return def.NewSyntaxErrReply()
+			}
+			persist = true
+		case "ex", "px", "exat", "pxat":
+			if expireAt != nil || persist || i == len(args)-1 {
+				return def.NewSyntaxErrReply()
+			}
+			at, err := parseExpireAt(flag, args[i+1])
+			if err != nil {
+				return def.NewErrReply(err.Error())
+			}
+
+			expireAt = &at
+			i++
+		default:
+			return def.NewSyntaxErrReply()
+		}
+	}
+
+	str, err := k.getAsString(key)
+	if err != nil {
+		return def.NewErrReply(err.Error())
+	}
+	if str == nil {
+		return def.NewNillReply()
+	}
+
+	switch {
+	case expireAt != nil:
+		k.expireAt(cmd.Ctx, pexpireAtCmd(key, *expireAt), key, *expireAt)
+	case persist && k.persist(key):
+		k.persister.PersistCmd(cmd.Ctx, [][]byte{[]byte(def.CmdTypePersist), []byte(key)}) // 持久化
+	}
+	return def.NewBulkReply(str.Bytes())
+}
+
+// MSet 批量写入
+func (k *KVStore) MSet(cmd *def.Command) def.Reply {
+	args := cmd.Args
+	if len(args) == 0 || len(args)&1 == 1 {
+		return def.NewSyntaxErrReply()
+	}
+
+	for i := 0; i < len(args); i += 2 {
+		k.put(string(args[i]), string(args[i+1]))
+	}
+
+	k.persister.PersistCmd(cmd.Ctx, cmd.GetCmd()) // 持久化
+	return def.NewOKReply()
+}
+
+// MSetNX 所有 key 均不存在时批量写入，返回是否写入
+func (k *KVStore) MSetNX(cmd *def.Command) def.Reply {
+	args := cmd.Args
+	if len(args) == 0 || len(args)&1 == 1 {
+		return def.NewSyntaxErrReply()
+	}
+
+	for i := 0; i < len(args); i += 2 {
+		if _, ok := k.lookup(string(args[i])); ok {
+			return def.NewIntReply(0)
+		}
+	}
+
+	for i := 0; i < len(args); i += 2 {
+		k.put(string(args[i]), string(args[i+1]))
+	}
+
+	// 以 mset 持久化，重放时无需再次判断
+	k.persister.PersistCmd(cmd.Ctx, append([][]byte{[]byte(def.CmdTypeMSet)}, args...)) // 持久化
+	return def.NewIntReply(1)
+}
+
+// Incr 整数自增 1
+func (k *KVStore) Incr(cmd *def.Command) def.Reply {
+	if len(cmd.Args) != 1 {
+		return def.NewSyntaxErrReply()
+	}
+	return k.incrBy(cmd, string(cmd.Args[0]), 1)
+}
+
+// Decr 整数自减 1
+func (k *KVStore) Decr(cmd *def.Command) def.Reply {
+	if len(cmd.Args) != 1 {
+		return def.NewSyntaxErrReply()
+	}
+	return k.incrBy(cmd, string(cmd.Args[0]), -1)
+}
+
+// IncrBy 整数自增 increment
+func (k *KVStore) IncrBy(cmd *def.Command) def.Reply {
+	args := cmd.Args
+	if len(args) != 2 {
+		return def.NewSyntaxErrReply()
+	}
+
+	delta, err := strconv.ParseInt(string(args[1]), 10, 64)
+	if err != nil {
+		return def.NewErrReply("ERR value is not an integer or out of range")
+	}
+	return k.incrBy(cmd, string(args[0]), delta)
+}
+
+// DecrBy 整数自减 decrement
+func (k *KVStore) DecrBy(cmd *def.Command) def.Reply {
+	args := cmd.Args
+	if len(args) != 2 {
+		return def.NewSyntaxErrReply()
+	}
+
+	delta, err := strconv.ParseInt(string(args[1]), 10, 64)
+	if err != nil || delta == math.MinInt64 {
+		return def.NewErrReply("ERR value is not an integer or out of range")
+	}
+	return k.incrBy(cmd, string(args[0]), -delta)
+}
+
+// incrBy 整数增减实际执行，key 不存在时视为 0
+func (k *KVStore) incrBy(cmd *def.Command, key string, delta int64) def.Reply {
+	str, err := k.getAsString(key)
+	if err != nil {
+		return def.NewErrReply(err.Error())
+	}
+
+	var cur int64
+	if str != nil {
+		if cur, err = strconv.ParseInt(string(str.Bytes()), 10, 64); err != nil {
+			return def.NewErrReply("ERR value is not an integer or out of range")
+		}
+	}
+
+	if (delta > 0 && cur > math.MaxInt64-delta) || (delta < 0 && cur < math.MinInt64-delta) {
+		return def.NewErrReply("ERR increment or decrement would overflow")
+	}
+
+	cur += delta
+	k.setString(key, str, []byte(strconv.FormatInt(cur, 10)))
+
+	k.persister.PersistCmd(cmd.Ctx, cmd.GetCmd()) // 持久化
+	return def.NewIntReply(cur)
+}
+
+// IncrByFloat 浮点数自增 increment
+// 浮点运算结果与格式化方式相关，持久化时改写为 set key value keepttl，保证重放结果一致
+func (k *KVStore) IncrByFloat(cmd *def.Command) def.Reply {
+	args := cmd.Args
+	if len(args) != 2 {
+		return def.NewSyntaxErrReply()
+	}
+
+	key := string(args[0])
+	delta, err := parseFloat(args[1])
+	if err != nil {
+		return def.NewErrReply("ERR value is not a valid float")
+	}
+
+	str, err := k.getAsString(key)
+	if err != nil {
+		return def.NewErrReply(err.Error())
+	}
+
+	var cur float64
+	if str != nil {
+		if cur, err = parseFloat(str.Bytes()); err != nil {
+			return def.NewErrReply("ERR value is not a valid float")
+		}
+	}
+
+	cur += delta
+	if math.IsNaN(cur) || math.IsInf(cur, 0) {
+		return def.NewErrReply("ERR increment would produce NaN or Infinity")
+	}
+
+	value := []byte(strconv.FormatFloat(cur, 'f', -1, 64))
+	k.setString(key, str, value)
+
+	k.persister.PersistCmd(cmd.Ctx, [][]byte{[]byte(def.CmdTypeSet), []byte(key), value, []byte("keepttl")}) // 持久化
+	return def.NewBulkReply(value)
+}
+
+// parseFloat 解析有限浮点数，拒绝 nan 与 inf
+func parseFloat(raw []byte) (float64, error) {
+	v, err := strconv.ParseFloat(string(raw), 64)
+	if err != nil || len(raw) == 0 || math.IsNaN(v) || math.IsInf(v, 0) {
+		return 0, def.NewSyntaxErrReply()
+	}
+	return v, nil
+}
+
+// setString 原地更新字符串，key 不存在时新建，保留原有的过期时间
+func (k *KVStore) setString(key string, str mstring.String, value []byte) {
+	if str == nil {
+		k.data.Put(key, mstring.NewString(key, string(value)))
+		return
+	}
+	str.Set(value)
+}
+
+// Append 追加写入，key 不存在时等同于 set
+func (k *KVStore) Append(cmd *def.Command) def.Reply {
+	args := cmd.Args
+	if len(args) != 2 {
+		return def.NewSyntaxErrReply()
+	}
+
+	key := string(args[0])
+	str, err := k.getAsString(key)
+	if err != nil {
+		return def.NewErrReply(err.Error())
+	}
+
+	if str != nil && str.Len()+int64(len(args[1])) > maxStringSize {
+		return def.NewErrReply("ERR string exceeds maximum allowed size (proto-max-bulk-len)")
+	}
+
+	if str == nil {
+		str = mstring.NewString(key, "")
+		k.data.Put(key, str)
+	}
+	size := str.Append(args[1])
+
+	k.persister.PersistCmd(cmd.Ctx, cmd.GetCmd()) // 持久化
+	return def.NewIntReply(size)
+}
+
+// StrLen 字符串长度，key 不存在时返回 0
+func (k *KVStore) StrLen(cmd *def.Command) def.Reply {
+	args := cmd.Args
+	if len(args) != 1 {
+		return def.NewSyntaxErrReply()
+	}
+
+	str, err := k.getAsString(string(args[0]))
+	if err != nil {
+		return def.NewErrReply(err.Error())
+	}
+	if str == nil {
+		return def.NewIntReply(0)
+	}
+	return def.NewIntReply(str.Len())
+}
+
+// GetRange 获取子串，start、end 为闭区间且支持负数下标
+func (k *KVStore) GetRange(cmd *def.Command) def.Reply {
+	args := cmd.Args
+	if len(args) != 3 {
+		return def.NewSyntaxErrReply()
+	}
+
+	start, err1 := strconv.ParseInt(string(args[1]), 10, 64)
+	end, err2 := strconv.ParseInt(string(args[2]), 10, 64)
+	if err1 != nil || err2 != nil {
+		return def.NewErrReply("ERR value is not an integer or out of range")
+	}
+
+	str, err := k.getAsString(string(args[0]))
+	if err != nil {
+		return def.NewErrReply(err.Error())
+	}
+	if str == nil {
+		return def.NewBulkReply([]byte{})
+	}
+	return def.NewBulkReply(str.GetRange(start, end))
+}
+
+// SetRange 从 offset 处覆盖写入，长度不足时以零字节填充，返回修改后的长度
+func (k *KVStore) SetRange(cmd *def.Command) def.Reply {
+	args := cmd.Args
+	if len(args) != 3 {
+		return def.NewSyntaxErrReply()
+	}
+
+	key, value := string(args[0]), args[2]
+	offset, err := strconv.ParseInt(string(args[1]), 10, 64)
+	if err != nil {
+		return def.NewErrReply("ERR value is not an integer or out of range")
+	}
+	if offset < 0 {
+		return def.NewErrReply("ERR offset is out of range")
+	}
+
+	str, err := k.getAsString(key)
+	if err != nil {
+		return def.NewErrReply(err.Error())
+	}
+
+	// 写入内容为空时不修改，也不创建 key
+	if len(value) == 0 {
+		if str == nil {
+			return def.NewIntReply(0)
+		}
+		return def.NewIntReply(str.Len())
+	}
+
+	// offset 接近 MaxInt64 时 offset+len(value) 会溢出，改为与差值比较
+	if offset > maxStringSize-int64(len(value)) {
+		return def.NewErrReply("ERR string exceeds maximum allowed size (proto-max-bulk-len)")
+	}
+
+	if str == nil {
+		str = mstring.NewString(key, "")
+		k.data.Put(key, str)
+	}
+	size := str.SetRange(offset, value)
+
+	k.persister.PersistCmd(cmd.Ctx, cmd.GetCmd()) // 持久化
+	return def.NewIntReply(size)
+}
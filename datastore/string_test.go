@@ -0,0 +1,160 @@
+package datastore
+
+import "testing"
+
+// TestStringCommands 数值增减、追加及区间读写的边界行为
+func TestStringCommands(t *testing.T) {
+	const (
+		notInt   = "-ERR value is not an integer or out of range\r\n"
+		overflow = "-ERR increment or decrement would overflow\r\n"
+		tooLarge = "-ERR string exceeds maximum allowed size (proto-max-bulk-len)\r\n"
+		wrongTyp = "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"
+	)
+
+	runReplyCases(t, []replyCase{
+		{"incr missing key", nil, "incr n", ":1\r\n"},
+		{"incrby negative", []string{"set n 10"}, "incrby n -15", ":-5\r\n"},
+		{"incr not integer", []string{"set n 1.5"}, "incr n", notInt},
+		{"incr max", []string{"set n 9223372036854775807"}, "incr n", overflow},
+		{"decr min", []string{"set n -9223372036854775808"}, "decr n", overflow},
+		{"incrby overflow", []string{"set n 1"}, "incrby n 9223372036854775807", overflow},
+		{"overflow keeps value", []string{"set n 9223372036854775807", "incr n"}, "get n", "$19\r\n9223372036854775807\r\n"},
+		{"decrby min int64", []string{"set n 0"}, "decrby n -9223372036854775808", notInt},
+		{"incr wrong type", []string{"rpush n a"}, "incr n", wrongTyp},
+
+		{"incrbyfloat", []string{"set f 10.5"}, "incrbyfloat f 0.1", "$4\r\n10.6\r\n"},
+		{"incrbyfloat exponent", []string{"set f 10.5"}, "incrbyfloat f 5.0e3", "$6\r\n5010.5\r\n"},
+		{"incrbyfloat on integer", []string{"set f 3"}, "incrbyfloat f 1.5", "$3\r\n4.5\r\n"},
+		{"incrbyfloat nan", nil, "incrbyfloat f nan", "-ERR value is not a valid float\r\n"},
+		{"incrbyfloat to inf", []string{"set f 1.7e308"}, "incrbyfloat f 1.7e308", "-ERR increment would produce NaN or Infinity\r\n"},
+
+		{"append missing key", nil, "append s hello", ":5\r\n"},
+		{"append", []string{"set s hello", "append s world"}, "get s", "$10\r\nhelloworld\r\n"},
+		{"append wrong type", []string{"rpush s a"}, "append s x", wrongTyp},
+		{"strlen missing key", nil, "strlen s", ":0\r\n"},
+		{"strlen after incr", []string{"set s 99", "incr s"}, "strlen s", ":3\r\n"},
+
+		{"getrange negative", []string{"set s hello"}, "getrange s -3 -1", "$3\r\nllo\r\n"},
+		{"getrange clamped", []string{"set s hello"}, "getrange s -100 100", "$5\r\nhello\r\n"},
+		{"getrange start after end", []string{"set s hello"}, "getrange s 3 1", "$0\r\n\r\n"},
+		{"getrange huge", []string{"set s hello"}, "getrange s 9223372036854775807 -9223372036854775808", "$0\r\n\r\n"},
+		{"getrange missing key", nil, "getrange s 0 -1", "$0\r\n\r\n"},
+
+		{"setrange pads with zero bytes", []string{"set s ab", "setrange s 4 x"}, "get s", "$5\r\nab\x00\x00x\r\n"},
+		{"setrange overwrites", []string{"set s hello"}, "setrange s 1 ipp", ":5\r\n"},
+		{"setrange missing key", nil, "setrange s 2 x", ":3\r\n"},
+		{"setrange negative offset", nil, "setrange s -1 x", "-ERR offset is out of range\r\n"},
+		{"setrange max size", nil, "setrange s 536870911 ab", tooLarge},
+		{"setrange huge offset", nil, "setrange s 9223372036854775807 ab", tooLarge},
+		{"setrange huge offset creates nothing", []string{"setrange s 9223372036854775807 ab"}, "exists s", ":0\r\n"},
+	})
+}
+
+// TestStringPersistence 原地修改的字符串重放后一致，INCRBYFLOAT 保留过期时间
+func TestStringPersistence(t *testing.T) {
+	s := newTestStore(t)
+	for _, line := range []string{
+		"incr n",
+		"incrby n 41",
+		"decrby n 2",
+		"set f 1.1",
+		"expire f 100",
+		"incrbyfloat f 2.2",
+		"append s hello",
+		"setrange s 8 world",
+		"set big 9223372036854775807",
+		"incr big",
+	} {
+		s.exec(line)
+	}
+
+	assertReplayed(t, s, "get n", "get f", "ttl f", "get s", "get big")
+}
+
+// TestSetOptions SET 选项组合及派生指令的返回值
+func TestSetOptions(t *testing.T) {
+	const (
+		ok       = "+OK\r\n"
+		nilBulk  = "$-1\r\n"
+		syntax   = "-Err syntax error\r\n"
+		wrongTyp = "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"
+	)
+
+	runReplyCases(t, []replyCase{
+		{"set nx px acquires lock", nil, "set lock v nx px 10000", ok},
+		{"set nx px lock held", []string{"set lock v nx px 10000"}, "set lock w nx px 10000", nilBulk},
+		{"set nx px keeps holder", []string{"set lock v nx px 10000", "set lock w nx px 10000"}, "get lock", "$1\r\nv\r\n"},
+		{"set xx missing key", nil, "set k v xx", nilBulk},
+		{"set xx", []string{"set k v"}, "set k w xx", ok},
+		{"set options case insensitive", nil, "set k v NX Px 100", ok},
+		{"set overwrites other type", []string{"rpush k a", "set k v"}, "type k", "+string\r\n"},
+
+		{"set get", []string{"set k v"}, "set k w get", "$1\r\nv\r\n"},
+		{"set get missing key", nil, "set k w get", nilBulk},
+		{"set get wrong type", []string{"rpush k a"}, "set k w get", wrongTyp},
+		{"set get wrong type keeps value", []string{"rpush k a", "set k w get"}, "type k", "+list\r\n"},
+		{"set nx get existing", []string{"set k v"}, "set k w nx get", "$1\r\nv\r\n"},
+		{"set nx get not written", []string{"set k v", "set k w nx get"}, "get k", "$1\r\nv\r\n"},
+		{"set xx get missing key", nil, "set k w xx get", nilBulk},
+
+		{"set nx xx", nil, "set k v nx xx", syntax},
+		{"set xx nx", nil, "set k v xx nx", syntax},
+		{"set ex without value", nil, "set k v ex", syntax},
+		{"set ex px", nil, "set k v ex 10 px 100", syntax},
+		{"set ex keepttl", nil, "set k v ex 10 keepttl", syntax},
+		{"set ex not integer", nil, "set k v ex ten", syntax},
+		{"set ex negative", nil, "set k v ex -1", "-ERR invalid expire time\r\n"},
+		{"set unknown option", nil, "set k v forever", syntax},
+
+		{"getset", []string{"set k v"}, "getset k w", "$1\r\nv\r\n"},
+		{"getset missing key", nil, "getset k w", nilBulk},
+		{"getset clears ttl", []string{"set k v ex 100", "getset k w"}, "ttl k", ":-1\r\n"},
+		{"getset wrong type", []string{"rpush k a"}, "getset k w", wrongTyp},
+		{"getdel", []string{"set k v"}, "getdel k", "$1\r\nv\r\n"},
+		{"getdel deletes", []string{"set k v", "getdel k"}, "exists k", ":0\r\n"},
+		{"getdel wrong type", []string{"rpush k a"}, "getdel k", wrongTyp},
+		{"getex ex", []string{"set k v", "getex k ex 100"}, "ttl k", ":100\r\n"},
+		{"getex persist", []string{"set k v ex 100", "getex k persist"}, "ttl k", ":-1\r\n"},
+		{"getex without option keeps ttl", []string{"set k v ex 100", "getex k"}, "ttl k", ":100\r\n"},
+		{"getex persist ex", []string{"set k v"}, "getex k persist ex 10", syntax},
+		{"getex missing key", nil, "getex k ex 10", nilBulk},
+
+		{"setnx", nil, "setnx k v", ":1\r\n"},
+		{"setnx existing", []string{"set k v"}, "setnx k w", ":0\r\n"},
+		{"setex", []string{"setex k 100 v"}, "ttl k", ":100\r\n"},
+		{"setex zero", nil, "setex k 0 v", "-ERR invalid expire time\r\n"},
+		{"psetex", []string{"psetex k 100000 v"}, "ttl k", ":100\r\n"},
+		{"psetex arity", nil, "psetex k 100", syntax},
+		{"msetnx", nil, "msetnx a 1 b 2", ":1\r\n"},
+		{"msetnx any existing", []string{"set b x"}, "msetnx a 1 b 2", ":0\r\n"},
+		{"msetnx all or nothing", []string{"set b x", "msetnx a 1 b 2"}, "exists a", ":0\r\n"},
+		{"msetnx odd args", nil, "msetnx a 1 b", syntax},
+	})
+}
+
+// TestSetOptionsPersistence 条件写入与过期选项重放后一致，未写入的指令不产生记录
+func TestSetOptionsPersistence(t *testing.T) {
+	s := newTestStore(t)
+	for _, line := range []string{
+		"set lock v nx px 100000",
+		"set k v",
+		"set k w xx keepttl",
+		"setex e 100 v",
+		"getex e persist",
+		"getset g v",
+		"getdel k",
+		"msetnx a 1 b 2",
+	} {
+		s.exec(line)
+	}
+
+	recorded := len(s.persister.cmds)
+	for _, line := range []string{"set lock w nx", "set missing v xx", "msetnx a 3 c 4", "getdel missing"} {
+		s.exec(line)
+	}
+	if n := len(s.persister.cmds); n != recorded {
+		t.Errorf("commands without effect persisted %d records", n-recorded)
+	}
+
+	assertReplayed(t, s, "get lock", "pexpiretime lock", "exists k", "ttl e", "get g", "mget a b c")
+}
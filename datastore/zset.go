@@ -0,0 +1,876 @@
+package datastore
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	msortedset "github.com/lovelydayss/goredis/datastruct/sorted_set"
+	def "github.com/lovelydayss/goredis/interface"
+)
+
+// sorted set 类型指令
+
+// zaddOptions ZADD 的可选参数
+type zaddOptions struct {
+	nx, xx bool // 仅新增成员、仅更新已有成员
+	gt, lt bool // 仅在新分值更大、更小时更新已有成员
+	ch     bool // 返回新增与分值发生变化的成员总数
+	incr   bool // 以分值作为增量，返回更新后的分值
+}
+
+// ZAdd ZADD key [NX | XX] [GT | LT] [CH] [INCR] score member [score member ...]
+// 默认返回新增的成员数；INCR 时返回成员更新后的分值，因选项未更新时返回 nil
+func (k *KVStore) ZAdd(cmd *def.Command) def.Reply {
+	args := cmd.Args
+	if len(args) < 1 {
+		return def.NewSyntaxErrReply()
+	}
+
+	var (
+		opts zaddOptions
+		i    = 1
+	)
+options:
+	for ; i < len(args); i++ {
+		switch strings.ToLower(string(args[i])) {
+		case "nx":
+			opts.nx = true
+		case "xx":
+			opts.xx = true
+		case "gt":
+			opts.gt = true
+		case "lt":
+			opts.lt = true
+		case "ch":
+			opts.ch = true
+		case "incr":
+			opts.incr = true
+		default:
+			break options
+		}
+	}
+
+	pairs := args[i:]
+	if len(pairs) == 0 || len(pairs)&1 != 0 {
+		return def.NewSyntaxErrReply()
+	}
+	if opts.nx && opts.xx {
+		return def.NewErrReply("ERR XX and NX options at the same time are not compatible")
+	}
+	if opts.nx && (opts.gt || opts.lt) || opts.gt && opts.lt {
+		return def.NewErrReply("ERR GT, LT, and/or NX options at the same time are not compatible")
+	}
+	if opts.incr && len(pairs) > 2 {
+		return def.NewErrReply("ERR INCR option supports a single increment-element pair")
+	}
+
+	elems := make([]msortedset.Element, 0, len(pairs)>>1)
+	for j := 0; j < len(pairs); j += 2 {
+		score, err := msortedset.ParseScore(pairs[j])
+		if err != nil {
+			return def.NewErrReply(err.Error())
+		}
+		elems = append(elems, msortedset.Element{Member: string(pairs[j+1]), Score: score})
+	}
+
+	key := string(args[0])
+	zset, err := k.getAsSortedSet(key)
+	if err != nil {
+		return def.NewErrReply(err.Error())
+	}
+
+	var (
+		added, changed int64
+		applied        = make([]msortedset.Element, 0, len(elems))
+	)
+	for _, elem := range elems {
+		var (
+			cur    float64
+			exists bool
+		)
+		if zset != nil {
+			cur, exists = zset.Score(elem.Member)
+		}
+
+		if exists && opts.nx || !exists && opts.xx {
+			continue
+		}
+
+		score := elem.Score
+		if opts.incr && exists {
+			if score += cur; math.IsNaN(score) {
+				return def.NewErrReply("ERR resulting score is not a number (NaN)")
+			}
+		}
+		if exists && (opts.gt && score <= cur || opts.lt && score >= cur) {
+			continue
+		}
+
+		if zset == nil {
+			zset = msortedset.NewSkiplist(key)
+			k.putAsSortedSet(key, zset)
+		}
+
+		switch {
+		case !exists:
+			added++
+		case score != cur:
+			changed++
+		default:
+			applied = append(applied, msortedset.Element{Member: elem.Member, Score: score})
+			continue
+		}
+		zset.Add(score, elem.Member)
+		applied = append(applied, msortedset.Element{Member: elem.Member, Score: score})
+	}
+
+	// 以生效的成员及最终分值持久化，INCR 重放时不受浮点误差影响
+	if added+changed > 0 {
+		k.persistZAdd(cmd, key, applied)
+	}
+	if added > 0 {
+		k.signalReady(key)
+	}
+
+	if opts.incr {
+		if len(applied) == 0 {
+			return def.NewNillReply()
+		}
+		return def.NewBulkReply([]byte(msortedset.FormatScore(applied[0].Score)))
+	}
+	if opts.ch {
+		return def.NewIntReply(added + changed)
+	}
+	return def.NewIntReply(added)
+}
+
+// ZIncrBy ZINCRBY key increment member，返回成员更新后的分值，成员不存在时视为 0
+func (k *KVStore) ZIncrBy(cmd *def.Command) def.Reply {
+	args := cmd.Args
+	if len(args) != 3 {
+		return def.NewSyntaxErrReply()
+	}
+
+	incr, err := msortedset.ParseScore(args[1])
+	if err != nil {
+		return def.NewErrReply(err.Error())
+	}
+
+	key, member := string(args[0]), string(args[2])
+	zset, err := k.getAsSortedSet(key)
+	if err != nil {
+		return def.NewErrReply(err.Error())
+	}
+
+	var cur float64
+	if zset != nil {
+		cur, _ = zset.Score(member)
+	}
+
+	score := cur + incr
+	if math.IsNaN(score) {
+		return def.NewErrReply("ERR resulting score is not a number (NaN)")
+	}
+
+	if zset == nil {
+		zset = msortedset.NewSkiplist(key)
+		k.putAsSortedSet(key, zset)
+	}
+	zset.Add(score, member)
+	k.persistZAdd(cmd, key, []msortedset.Element{{Member: member, Score: score}}) // 持久化
+	k.signalReady(key)
+	return def.NewBulkReply([]byte(msortedset.FormatScore(score)))
+}
+
+// persistZAdd 将成员及最终分值持久化为 ZADD key score member [score member ...]
+func (k *KVStore) persistZAdd(cmd *def.Command, key string, elems []msortedset.Element) {
+	cmdLine := make([][]byte, 0, 2+2*len(elems))
+	cmdLine = append(cmdLine, []byte(def.CmdTypeZAdd), []byte(key))
+	for _, elem := range elems {
+		cmdLine = append(cmdLine, []byte(msortedset.FormatScore(elem.Score)), []byte(elem.Member))
+	}
+	k.persister.PersistCmd(cmd.Ctx, cmdLine)
+}
+
+// ZRangeByScore ZRANGEBYSCORE key min max [WITHSCORES] [LIMIT offset count]
+// 返回分值在区间内的成员，边界以 ( 开头时为开区间，支持 -inf 与 +inf
+func (k *KVStore) ZRangeByScore(cmd *def.Command) def.Reply {
+	return k.zrangeBy(cmd, zrangeByScore, false)
+}
+
+// ZRevRangeByScore ZREVRANGEBYSCORE key max min [WITHSCORES] [LIMIT offset count]，按分值降序返回
+func (k *KVStore) ZRevRangeByScore(cmd *def.Command) def.Reply {
+	return k.zrangeBy(cmd, zrangeByScore, true)
+}
+
+// ZRangeByLex ZRANGEBYLEX key min max [LIMIT offset count]
+// 返回字典序在区间内的成员，边界为 [member、(member、- 或 +
+func (k *KVStore) ZRangeByLex(cmd *def.Command) def.Reply {
+	return k.zrangeBy(cmd, zrangeByLex, false)
+}
+
+// zrangeBy ZRANGEBYSCORE 等按区间查询的指令
+func (k *KVStore) zrangeBy(cmd *def.Command, by int, reverse bool) def.Reply {
+	args := cmd.Args
+	if len(args) < 3 {
+		return def.NewSyntaxErrReply()
+	}
+
+	spec := zrangeSpec{by: by, start: args[1], stop: args[2], reverse: reverse, count: -1}
+	if _, reply := parseZRangeOptions(&spec, args[3:], false); reply != nil {
+		return reply
+	}
+	if spec.withScores && spec.by == zrangeByLex {
+		return def.NewSyntaxErrReply()
+	}
+	return k.zrange(string(args[0]), &spec)
+}
+
+// ZRange ZRANGE key start stop [BYSCORE | BYLEX] [REV] [LIMIT offset count] [WITHSCORES]
+// 默认按排名查询，BYSCORE 与 BYLEX 分别按分值与字典序查询，REV 时 start 与 stop 依次为上界与下界
+func (k *KVStore) ZRange(cmd *def.Command) def.Reply {
+	args := cmd.Args
+	if len(args) < 3 {
+		return def.NewSyntaxErrReply()
+	}
+
+	spec := zrangeSpec{by: zrangeByRank, start: args[1], stop: args[2], count: -1}
+	limit, reply := parseZRangeOptions(&spec, args[3:], true)
+	if reply != nil {
+		return reply
+	}
+
+	if limit && spec.by == zrangeByRank {
+		return def.NewErrReply("ERR syntax error, LIMIT is only supported in combination with either BYSCORE or BYLEX")
+	}
+	if spec.withScores && spec.by == zrangeByLex {
+		return def.NewErrReply("ERR syntax error, WITHSCORES not supported in combination with BYLEX")
+	}
+	return k.zrange(string(args[0]), &spec)
+}
+
+// parseZRangeOptions 解析 ZRANGE 系列指令的可选参数，返回是否指定了 LIMIT
+// withBy 为 false 时不接受 BYSCORE、BYLEX 与 REV
+func parseZRangeOptions(spec *zrangeSpec, args [][]byte, withBy bool) (bool, def.Reply) {
+	var limit bool
+	for i := 0; i < len(args); i++ {
+		switch option := strings.ToLower(string(args[i])); {
+		case withBy && option == "byscore":
+			spec.by = zrangeByScore
+		case withBy && option == "bylex":
+			spec.by = zrangeByLex
+		case withBy && option == "rev":
+			spec.reverse = true
+		case option == "withscores":
+			spec.withScores = true
+		case option == "limit":
+			if i+2 >= len(args) {
+				return false, def.NewSyntaxErrReply()
+			}
+			offset, err1 := strconv.ParseInt(string(args[i+1]), 10, 64)
+			count, err2 := strconv.ParseInt(string(args[i+2]), 10, 64)
+			if err1 != nil || err2 != nil {
+				return false, def.NewErrReply("ERR value is not an integer or out of range")
+			}
+			spec.offset, spec.count, limit = offset, count, true
+			i += 2
+		default:
+			return false, def.NewSyntaxErrReply()
+		}
+	}
+	return limit, nil
+}
+
+// ZCard 成员数，key 不存在时返回 0
+func (k *KVStore) ZCard(cmd *def.Command) def.Reply {
+	args := cmd.Args
+	if len(args) != 1 {
+		return def.NewSyntaxErrReply()
+	}
+
+	zset, err := k.getAsSortedSet(string(args[0]))
+	if err != nil {
+		return def.NewErrReply(err.Error())
+	}
+
+	if zset == nil {
+		return def.NewIntReply(0)
+	}
+	return def.NewIntReply(zset.Len())
+}
+
+// ZScore 获取成员的分值，成员不存在时返回 nil
+func (k *KVStore) ZScore(cmd *def.Command) def.Reply {
+	args := cmd.Args
+	if len(args) != 2 {
+		return def.NewSyntaxErrReply()
+	}
+
+	zset, err := k.getAsSortedSet(string(args[0]))
+	if err != nil {
+		return def.NewErrReply(err.Error())
+	}
+
+	if zset == nil {
+		return def.NewNillReply()
+	}
+
+	score, ok := zset.Score(string(args[1]))
+	if !ok {
+		return def.NewNillReply()
+	}
+	return def.NewBulkReply([]byte(msortedset.FormatScore(score)))
+}
+
+// ZMScore 批量获取成员的分值，不存在的成员对应 nil
+func (k *KVStore) ZMScore(cmd *def.Command) def.Reply {
+	args := cmd.Args
+	if len(args) < 2 {
+		return def.NewSyntaxErrReply()
+	}
+
+	zset, err := k.getAsSortedSet(string(args[0]))
+	if err != nil {
+		return def.NewErrReply(err.Error())
+	}
+
+	scores := make([][]byte, len(args)-1)
+	if zset != nil {
+		for i, member := range args[1:] {
+			if score, ok := zset.Score(string(member)); ok {
+				scores[i] = []byte(msortedset.FormatScore(score))
+			}
+		}
+	}
+	return def.NewMultiBulkReply(scores)
+}
+
+// ZCount 分值在区间内的成员数
+func (k *KVStore) ZCount(cmd *def.Command) def.Reply {
+	args := cmd.Args
+	if len(args) != 3 {
+		return def.NewSyntaxErrReply()
+	}
+
+	interval, err := parseScoreInterval(args[1], args[2])
+	if err != nil {
+		return def.NewErrReply(err.Error())
+	}
+
+	zset, err := k.getAsSortedSet(string(args[0]))
+	if err != nil {
+		return def.NewErrReply(err.Error())
+	}
+
+	if zset == nil {
+		return def.NewIntReply(0)
+	}
+	return def.NewIntReply(zset.Count(interval))
+}
+
+// ZLexCount 字典序在区间内的成员数
+func (k *KVStore) ZLexCount(cmd *def.Command) def.Reply {
+	args := cmd.Args
+	if len(args) != 3 {
+		return def.NewSyntaxErrReply()
+	}
+
+	interval, err := parseLexInterval(args[1], args[2])
+	if err != nil {
+		return def.NewErrReply(err.Error())
+	}
+
+	zset, err := k.getAsSortedSet(string(args[0]))
+	if err != nil {
+		return def.NewErrReply(err.Error())
+	}
+
+	if zset == nil {
+		return def.NewIntReply(0)
+	}
+	return def.NewIntReply(zset.Count(interval))
+}
+
+// ZRank ZRANK key member [WITHSCORE]，获取成员按分值升序的排名
+func (k *KVStore) ZRank(cmd *def.Command) def.Reply {
+	return k.zrank(cmd, false)
+}
+
+// ZRevRank ZREVRANK key member [WITHSCORE]，获取成员按分值降序的排名
+func (k *KVStore) ZRevRank(cmd *def.Command) def.Reply {
+	return k.zrank(cmd, true)
+}
+
+// zrank 获取成员排名，成员不存在时返回 nil，WITHSCORE 时一并返回分值
+func (k *KVStore) zrank(cmd *def.Command, reverse bool) def.Reply {
+	args := cmd.Args
+	if len(args) < 2 || len(args) > 3 {
+		return def.NewSyntaxErrReply()
+	}
+
+	withScore := len(args) == 3
+	if withScore && strings.ToLower(string(args[2])) != "withscore" {
+		return def.NewSyntaxErrReply()
+	}
+
+	zset, err := k.getAsSortedSet(string(args[0]))
+	if err != nil {
+		return def.NewErrReply(err.Error())
+	}
+
+	var (
+		member = string(args[1])
+		rank   int64
+		ok     bool
+	)
+	if zset != nil {
+		rank, ok = zset.Rank(member)
+	}
+
+	if !ok {
+		if withScore {
+			return def.NewNillMultiBulkReply()
+		}
+		return def.NewNillReply()
+	}
+
+	if reverse {
+		rank = zset.Len() - 1 - rank
+	}
+	if !withScore {
+		return def.NewIntReply(rank)
+	}
+
+	score, _ := zset.Score(member)
+	return def.NewArrayReply([]def.Reply{
+		def.NewIntReply(rank),
+		def.NewBulkReply([]byte(msortedset.FormatScore(score))),
+	})
+}
+
+// ZRem 删除成员，返回删除的成员数，有序集合为空时删除 key
+func (k *KVStore) ZRem(cmd *def.Command) def.Reply {
+	args := cmd.Args
+	if len(args) < 2 {
+		return def.NewSyntaxErrReply()
+	}
+
+	key := string(args[0])
+	zset, err := k.getAsSortedSet(key)
+	if err != nil {
+		return def.NewErrReply(err.Error())
+	}
+
+	if zset == nil {
+		return def.NewIntReply(0)
+	}
+
+	var remed int64
+	for _, arg := range args[1:] {
+		remed += zset.Rem(string(arg))
+	}
+
+	if remed > 0 {
+		k.removeIfEmptySortedSet(key, zset)
+		k.persister.PersistCmd(cmd.Ctx, cmd.GetCmd()) // 持久化
+	}
+	return def.NewIntReply(remed)
+}
+
+// ZRemRangeByRank ZREMRANGEBYRANK key start stop，删除排名在闭区间内的成员，返回删除的成员数
+func (k *KVStore) ZRemRangeByRank(cmd *def.Command) def.Reply {
+	return k.zremRange(cmd, zrangeByRank)
+}
+
+// ZRemRangeByScore ZREMRANGEBYSCORE key min max，删除分值在区间内的成员，返回删除的成员数
+func (k *KVStore) ZRemRangeByScore(cmd *def.Command) def.Reply {
+	return k.zremRange(cmd, zrangeByScore)
+}
+
+// ZRemRangeByLex ZREMRANGEBYLEX key min max，删除字典序在区间内的成员，返回删除的成员数
+func (k *KVStore) ZRemRangeByLex(cmd *def.Command) def.Reply {
+	return k.zremRange(cmd, zrangeByLex)
+}
+
+// zremRange ZREMRANGEBY* 指令，有序集合为空时删除 key
+func (k *KVStore) zremRange(cmd *def.Command, by int) def.Reply {
+	args := cmd.Args
+	if len(args) != 3 {
+		return def.NewSyntaxErrReply()
+	}
+
+	var (
+		interval    msortedset.Interval
+		start, stop int64
+		err         error
+	)
+	switch by {
+	case zrangeByRank:
+		start, stop, err = parseRankRange(args[1], args[2])
+	case zrangeByScore:
+		interval, err = parseScoreInterval(args[1], args[2])
+	case zrangeByLex:
+		interval, err = parseLexInterval(args[1], args[2])
+	}
+	if err != nil {
+		return def.NewErrReply(err.Error())
+	}
+
+	key := string(args[0])
+	zset, err := k.getAsSortedSet(key)
+	if err != nil {
+		return def.NewErrReply(err.Error())
+	}
+
+	if zset == nil {
+		return def.NewIntReply(0)
+	}
+
+	var remed int64
+	if by == zrangeByRank {
+		if start, stop, ok := normalizeRankRange(start, stop, zset.Len()); ok {
+			remed = zset.RemRangeByRank(start, stop)
+		}
+	} else {
+		remed = zset.RemRangeBy(interval)
+	}
+
+	if remed > 0 {
+		k.removeIfEmptySortedSet(key, zset)
+		k.persister.PersistCmd(cmd.Ctx, cmd.GetCmd()) // 持久化
+	}
+	return def.NewIntReply(remed)
+}
+
+// ZPopMin ZPOPMIN key [count]，弹出分值最小的成员，返回 [member, score ...]
+func (k *KVStore) ZPopMin(cmd *def.Command) def.Reply {
+	return k.zpop(cmd, true)
+}
+
+// ZPopMax ZPOPMAX key [count]，弹出分值最大的成员，返回 [member, score ...]
+func (k *KVStore) ZPopMax(cmd *def.Command) def.Reply {
+	return k.zpop(cmd, false)
+}
+
+// zpop 弹出实际执行，持久化为 ZREM key member [member ...]
+func (k *KVStore) zpop(cmd *def.Command, min bool) def.Reply {
+	args := cmd.Args
+	if len(args) < 1 || len(args) > 2 {
+		return def.NewSyntaxErrReply()
+	}
+
+	cnt := int64(1)
+	if len(args) == 2 {
+		var err error
+		if cnt, err = strconv.ParseInt(string(args[1]), 10, 64); err != nil {
+			return def.NewErrReply("ERR value is not an integer or out of range")
+		}
+		if cnt < 0 {
+			return def.NewErrReply("ERR value is out of range, must be positive")
+		}
+	}
+
+	key, poped, err := k.zmpop([]string{string(args[0])}, min, cnt)
+	if err != nil {
+		return def.NewErrReply(err.Error())
+	}
+
+	if len(poped) > 0 {
+		k.persistZPop(cmd, key, poped) // 持久化
+	}
+	return elementsReply(poped, true)
+}
+
+// ZMPop ZMPOP numkeys key [key ...] MIN|MAX [COUNT count]
+// 从首个非空有序集合弹出至多 count 个成员，返回 [key, [[member, score] ...]]，均为空时返回 nil 数组
+func (k *KVStore) ZMPop(cmd *def.Command) def.Reply {
+	keys, min, cnt, err := parseMPop(cmd.Args, parseZSetSide)
+	if err != nil {
+		return def.NewErrReply(err.Error())
+	}
+
+	return k.zmpopReply(cmd, keys, min, cnt)
+}
+
+// zmpopReply 批量弹出并生成 ZMPOP 格式的回复
+func (k *KVStore) zmpopReply(cmd *def.Command, keys []string, min bool, cnt int64) def.Reply {
+	key, poped, err := k.zmpop(keys, min, cnt)
+	if err != nil {
+		return def.NewErrReply(err.Error())
+	}
+	if poped == nil {
+		return def.NewNillMultiBulkReply()
+	}
+
+	k.persistZPop(cmd, key, poped) // 持久化
+	elems := make([]def.Reply, 0, len(poped))
+	for _, elem := range poped {
+		elems = append(elems, def.NewMultiBulkReply([][]byte{
+			[]byte(elem.Member),
+			[]byte(msortedset.FormatScore(elem.Score)),
+		}))
+	}
+	return def.NewArrayReply([]def.Reply{
+		def.NewBulkReply([]byte(key)),
+		def.NewArrayReply(elems),
+	})
+}
+
+// BZPopMin BZPOPMIN key [key ...] timeout
+// 从首个非空有序集合弹出分值最小的成员，返回 [key, member, score]；均为空时挂起直至有数据写入或超时
+func (k *KVStore) BZPopMin(cmd *def.Command) def.Reply {
+	return k.blockingZPop(cmd, true)
+}
+
+// BZPopMax BZPOPMAX key [key ...] timeout，弹出分值最大的成员
+func (k *KVStore) BZPopMax(cmd *def.Command) def.Reply {
+	return k.blockingZPop(cmd, false)
+}
+
+// blockingZPop 阻塞弹出实际执行，持久化为对应 key 的 ZREM
+func (k *KVStore) blockingZPop(cmd *def.Command, min bool) def.Reply {
+	args := cmd.Args
+	if len(args) < 2 {
+		return def.NewSyntaxErrReply()
+	}
+
+	timeout, err := parseTimeout(args[len(args)-1])
+	if err != nil {
+		return def.NewErrReply(err.Error())
+	}
+
+	keys := make([]string, 0, len(args)-1)
+	for _, arg := range args[:len(args)-1] {
+		keys = append(keys, string(arg))
+	}
+
+	key, poped, err := k.zmpop(keys, min, 1)
+	if err != nil {
+		return def.NewErrReply(err.Error())
+	}
+	if poped == nil {
+		return newBlockedReply(keys, timeout)
+	}
+
+	k.persistZPop(cmd, key, poped) // 持久化
+	return def.NewMultiBulkReply([][]byte{
+		[]byte(key),
+		[]byte(poped[0].Member),
+		[]byte(msortedset.FormatScore(poped[0].Score)),
+	})
+}
+
+// BZMPop BZMPOP timeout numkeys key [key ...] MIN|MAX [COUNT count]
+// 均为空时挂起直至有数据写入或超时
+func (k *KVStore) BZMPop(cmd *def.Command) def.Reply {
+	args := cmd.Args
+	if len(args) < 1 {
+		return def.NewSyntaxErrReply()
+	}
+
+	timeout, err := parseTimeout(args[0])
+	if err != nil {
+		return def.NewErrReply(err.Error())
+	}
+
+	keys, min, cnt, err := parseMPop(args[1:], parseZSetSide)
+	if err != nil {
+		return def.NewErrReply(err.Error())
+	}
+
+	reply := k.zmpopReply(cmd, keys, min, cnt)
+	if _, ok := reply.(*def.NillMultiBulkReply); ok {
+		return newBlockedReply(keys, timeout)
+	}
+	return reply
+}
+
+// parseZSetSide 解析 MIN|MAX，min 为 true 表示弹出分值最小的成员
+func parseZSetSide(raw []byte) (min bool, ok bool) {
+	switch strings.ToLower(string(raw)) {
+	case "min":
+		return true, true
+	case "max":
+		return false, true
+	default:
+		return false, false
+	}
+}
+
+// zmpop 从首个非空有序集合弹出至多 cnt 个成员，均为空时 poped 为 nil
+// 遇到类型错误的 key 直接返回错误，与 redis 一致
+func (k *KVStore) zmpop(keys []string, min bool, cnt int64) (key string, poped []msortedset.Element, err error) {
+	for _, key = range keys {
+		zset, err := k.getAsSortedSet(key)
+		if err != nil {
+			return "", nil, err
+		}
+		if zset == nil {
+			continue
+		}
+
+		if cnt > zset.Len() {
+			cnt = zset.Len()
+		}
+		poped = []msortedset.Element{}
+		if cnt > 0 {
+			poped = zset.RangeByRank(0, cnt-1, !min)
+		}
+		for _, elem := range poped {
+			zset.Rem(elem.Member)
+		}
+		k.removeIfEmptySortedSet(key, zset)
+		return key, poped, nil
+	}
+	return "", nil, nil
+}
+
+// persistZPop 将弹出操作持久化为 ZREM key member [member ...]
+func (k *KVStore) persistZPop(cmd *def.Command, key string, poped []msortedset.Element) {
+	cmdLine := make([][]byte, 0, 2+len(poped))
+	cmdLine = append(cmdLine, []byte(def.CmdTypeZRem), []byte(key))
+	for _, elem := range poped {
+		cmdLine = append(cmdLine, []byte(elem.Member))
+	}
+	k.persister.PersistCmd(cmd.Ctx, cmdLine)
+}
+
+// removeIfEmptySortedSet 有序集合为空时删除 key，与 redis 一致不保留空有序集合
+func (k *KVStore) removeIfEmptySortedSet(key string, zset msortedset.SortedSet) {
+	if zset.Len() == 0 {
+		k.remove(key)
+	}
+}
+
+// zrange 指令的查询方式
+const (
+	zrangeByRank = iota
+	zrangeByScore
+	zrangeByLex
+)
+
+// zrangeSpec ZRANGE 系列指令的查询参数
+type zrangeSpec struct {
+	by          int
+	start, stop []byte // 查询区间，reverse 时依次为上界与下界
+	reverse     bool
+	offset      int64
+	count       int64 // 至多返回的成员数，负数表示不限制
+	withScores  bool
+}
+
+// zrange 按查询参数获取成员
+func (k *KVStore) zrange(key string, spec *zrangeSpec) def.Reply {
+	min, max := spec.start, spec.stop
+	if spec.reverse {
+		min, max = max, min
+	}
+
+	var (
+		interval msortedset.Interval
+		err      error
+	)
+	switch spec.by {
+	case zrangeByScore:
+		interval, err = parseScoreInterval(min, max)
+	case zrangeByLex:
+		interval, err = parseLexInterval(min, max)
+	}
+	if err != nil {
+		return def.NewErrReply(err.Error())
+	}
+
+	var start, stop int64
+	if spec.by == zrangeByRank {
+		if start, stop, err = parseRankRange(spec.start, spec.stop); err != nil {
+			return def.NewErrReply(err.Error())
+		}
+	}
+
+	zset, err := k.getAsSortedSet(key)
+	if err != nil {
+		return def.NewErrReply(err.Error())
+	}
+
+	if zset == nil {
+		return def.NewEmptyMultiBulkReply()
+	}
+
+	var elems []msortedset.Element
+	if spec.by == zrangeByRank {
+		start, stop, ok := normalizeRankRange(start, stop, zset.Len())
+		if !ok {
+			return def.NewEmptyMultiBulkReply()
+		}
+		elems = zset.RangeByRank(start, stop, spec.reverse)
+	} else {
+		elems = zset.RangeBy(interval, spec.offset, spec.count, spec.reverse)
+	}
+	return elementsReply(elems, spec.withScores)
+}
+
+// parseRankRange 解析排名区间
+func parseRankRange(start, stop []byte) (int64, int64, error) {
+	startRank, err1 := strconv.ParseInt(string(start), 10, 64)
+	stopRank, err2 := strconv.ParseInt(string(stop), 10, 64)
+	if err1 != nil || err2 != nil {
+		return 0, 0, def.NewErrReply("ERR value is not an integer or out of range")
+	}
+	return startRank, stopRank, nil
+}
+
+// normalizeRankRange 规范化排名区间，负数从末尾开始计数，越界部分被截断，区间为空时返回 false
+func normalizeRankRange(start, stop, size int64) (int64, int64, bool) {
+	if start < 0 {
+		start += size
+	}
+	if stop < 0 {
+		stop += size
+	}
+	if start < 0 {
+		start = 0
+	}
+	if stop >= size {
+		stop = size - 1
+	}
+	return start, stop, start <= stop
+}
+
+// parseScoreInterval 解析分值区间
+func parseScoreInterval(min, max []byte) (*msortedset.ScoreInterval, error) {
+	minBorder, err := msortedset.ParseScoreBorder(min)
+	if err != nil {
+		return nil, err
+	}
+	maxBorder, err := msortedset.ParseScoreBorder(max)
+	if err != nil {
+		return nil, err
+	}
+	return &msortedset.ScoreInterval{Min: minBorder, Max: maxBorder}, nil
+}
+
+// parseLexInterval 解析字典序区间
+func parseLexInterval(min, max []byte) (*msortedset.LexInterval, error) {
+	minBorder, err := msortedset.ParseLexBorder(min)
+	if err != nil {
+		return nil, err
+	}
+	maxBorder, err := msortedset.ParseLexBorder(max)
+	if err != nil {
+		return nil, err
+	}
+	return &msortedset.LexInterval{Min: minBorder, Max: maxBorder}, nil
+}
+
+// elementsReply 成员列表回复，withScores 时每个成员后紧跟其分值
+func elementsReply(elems []msortedset.Element, withScores bool) def.Reply {
+	res := make([][]byte, 0, len(elems))
+	for _, elem := range elems {
+		res = append(res, []byte(elem.Member))
+		if withScores {
+			res = append(res, []byte(msortedset.FormatScore(elem.Score)))
+		}
+	}
+	return def.NewMultiBulkReply(res)
+}
@@ -0,0 +1,278 @@
+package datastore
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	msortedset "github.com/lovelydayss/goredis/datastruct/sorted_set"
+	def "github.com/lovelydayss/goredis/interface"
+)
+
+// sorted set 集合运算指令
+
+// zsetAlgebra 有序集合运算，weights 与 zsets 一一对应，aggregate 合并同一成员的分值
+type zsetAlgebra func(zsets []msortedset.SortedSet, weights []float64, aggregate func(a, b float64) float64) msortedset.SortedSet
+
+// zalgebraSpec ZUNION 等指令的参数
+type zalgebraSpec struct {
+	keys       []string
+	weights    []float64
+	aggregate  func(a, b float64) float64
+	withScores bool
+}
+
+// ZUnion ZUNION numkeys key [key ...] [WEIGHTS weight ...] [AGGREGATE SUM|MIN|MAX] [WITHSCORES]
+func (k *KVStore) ZUnion(cmd *def.Command) def.Reply {
+	return k.zsetAlgebra(cmd, zunion, true)
+}
+
+// ZInter ZINTER numkeys key [key ...] [WEIGHTS weight ...] [AGGREGATE SUM|MIN|MAX] [WITHSCORES]
+func (k *KVStore) ZInter(cmd *def.Command) def.Reply {
+	return k.zsetAlgebra(cmd, zinter, true)
+}
+
+// ZDiff ZDIFF numkeys key [key ...] [WITHSCORES]，分值取自第一个有序集合
+func (k *KVStore) ZDiff(cmd *def.Command) def.Reply {
+	return k.zsetAlgebra(cmd, zdiff, false)
+}
+
+// ZUnionStore ZUNIONSTORE destination numkeys key [key ...] [WEIGHTS weight ...] [AGGREGATE SUM|MIN|MAX]
+// 并集结果写入 destination，返回结果成员数
+func (k *KVStore) ZUnionStore(cmd *def.Command) def.Reply {
+	return k.zsetAlgebraStore(cmd, zunion, true)
+}
+
+// ZInterStore 交集结果写入 destination，返回结果成员数
+func (k *KVStore) ZInterStore(cmd *def.Command) def.Reply {
+	return k.zsetAlgebraStore(cmd, zinter, true)
+}
+
+// ZDiffStore 差集结果写入 destination，返回结果成员数
+func (k *KVStore) ZDiffStore(cmd *def.Command) def.Reply {
+	return k.zsetAlgebraStore(cmd, zdiff, false)
+}
+
+// zsetAlgebra 有序集合运算实际执行，不存在的 key 视为空有序集合
+func (k *KVStore) zsetAlgebra(cmd *def.Command, op zsetAlgebra, withWeights bool) def.Reply {
+	spec, err := parseZSetAlgebra(cmd, cmd.Args, withWeights, true)
+	if err != nil {
+		return def.NewErrReply(err.Error())
+	}
+
+	zsets, err := k.getSortedSets(spec.keys)
+	if err != nil {
+		return def.NewErrReply(err.Error())
+	}
+
+	result := op(zsets, spec.weights, spec.aggregate)
+	if result.Len() == 0 {
+		return def.NewEmptyMultiBulkReply()
+	}
+	return elementsReply(result.RangeByRank(0, result.Len()-1, false), spec.withScores)
+}
+
+// zsetAlgebraStore 有序集合运算结果写入 destination，覆盖原有的值及过期时间，结果为空时删除 destination
+// 运算结果确定，以原指令持久化，重放结果一致
+func (k *KVStore) zsetAlgebraStore(cmd *def.Command, op zsetAlgebra, withWeights bool) def.Reply {
+	args := cmd.Args
+	if len(args) < 2 {
+		return def.NewSyntaxErrReply()
+	}
+
+	spec, err := parseZSetAlgebra(cmd, args[1:], withWeights, false)
+	if err != nil {
+		return def.NewErrReply(err.Error())
+	}
+
+	zsets, err := k.getSortedSets(spec.keys)
+	if err != nil {
+		return def.NewErrReply(err.Error())
+	}
+
+	// 先完成运算，destination 可能同时是参与运算的有序集合
+	result := op(zsets, spec.weights, spec.aggregate)
+	dst := string(args[0])
+	k.remove(dst)
+	if result.Len() > 0 {
+		result.SetKey(dst)
+		k.putAsSortedSet(dst, result)
+		k.signalReady(dst)
+	}
+
+	k.persister.PersistCmd(cmd.Ctx, cmd.GetCmd()) // 持久化
+	return def.NewIntReply(result.Len())
+}
+
+// parseZSetAlgebra 解析 numkeys key [key ...] [WEIGHTS weight ...] [AGGREGATE SUM|MIN|MAX] [WITHSCORES]
+// withWeights 为 false 时不接受 WEIGHTS 与 AGGREGATE，withScores 为 false 时不接受 WITHSCORES
+func parseZSetAlgebra(cmd *def.Command, args [][]byte, withWeights, withScores bool) (*zalgebraSpec, error) {
+	if len(args) < 2 {
+		return nil, def.NewSyntaxErrReply()
+	}
+
+	numKeys, err := strconv.ParseInt(string(args[0]), 10, 64)
+	if err != nil {
+		return nil, def.NewErrReply("ERR value is not an integer or out of range")
+	}
+	if numKeys <= 0 {
+		return nil, def.NewErrReply("ERR at least 1 input key is needed for '" + cmd.Cmd.String() + "' command")
+	}
+	if numKeys > int64(len(args)-1) {
+		return nil, def.NewSyntaxErrReply()
+	}
+
+	spec := zalgebraSpec{
+		keys:      make([]string, 0, numKeys),
+		weights:   make([]float64, numKeys),
+		aggregate: aggregateSum,
+	}
+	for i, arg := range args[1 : numKeys+1] {
+		spec.keys = append(spec.keys, string(arg))
+		spec.weights[i] = 1
+	}
+
+	rest := args[numKeys+1:]
+	for i := 0; i < len(rest); i++ {
+		switch option := strings.ToLower(string(rest[i])); {
+		case withWeights && option == "weights":
+			if int64(len(rest)-i-1) < numKeys {
+				return nil, def.NewSyntaxErrReply()
+			}
+			for j := range spec.weights {
+				weight, err := msortedset.ParseScore(rest[i+1+j])
+				if err != nil {
+					return nil, def.NewErrReply("ERR weight value is not a float")
+				}
+				spec.weights[j] = weight
+			}
+			i += int(numKeys)
+		case withWeights && option == "aggregate":
+			if i+1 >= len(rest) {
+				return nil, def.NewSyntaxErrReply()
+			}
+			switch strings.ToLower(string(rest[i+1])) {
+			case "sum":
+				spec.aggregate = aggregateSum
+			case "min":
+				spec.aggregate = math.Min
+			case "max":
+				spec.aggregate = math.Max
+			default:
+				return nil, def.NewSyntaxErrReply()
+			}
+			i++
+		case withScores && option == "withscores":
+			spec.withScores = true
+		default:
+			return nil, def.NewSyntaxErrReply()
+		}
+	}
+	return &spec, nil
+}
+
+// aggregateSum 分值求和，inf 与 -inf 相加得到的 nan 视为 0，与 redis 一致
+func aggregateSum(a, b float64) float64 {
+	if sum := a + b; !math.IsNaN(sum) {
+		return sum
+	}
+	return 0
+}
+
+// weighted 加权后的分值，0 与 inf 相乘得到的 nan 视为 0
+func weighted(score, weight float64) float64 {
+	if v := score * weight; !math.IsNaN(v) {
+		return v
+	}
+	return 0
+}
+
+// getSortedSets 批量获取有序集合，不存在的 key 对应 nil，任一 key 类型错误时返回错误
+func (k *KVStore) getSortedSets(keys []string) ([]msortedset.SortedSet, error) {
+	zsets := make([]msortedset.SortedSet, 0, len(keys))
+	for _, key := range keys {
+		zset, err := k.getAsSortedSet(key)
+		if err != nil {
+			return nil, err
+		}
+		zsets = append(zsets, zset)
+	}
+	return zsets, nil
+}
+
+// zsetElements 有序集合的全部成员，nil 视为空有序集合
+func zsetElements(zset msortedset.SortedSet) []msortedset.Element {
+	if zset == nil || zset.Len() == 0 {
+		return nil
+	}
+	return zset.RangeByRank(0, zset.Len()-1, false)
+}
+
+// zunion 并集
+func zunion(zsets []msortedset.SortedSet, weights []float64, aggregate func(a, b float64) float64) msortedset.SortedSet {
+	result := msortedset.NewSkiplist("")
+	for i, zset := range zsets {
+		for _, elem := range zsetElements(zset) {
+			score := weighted(elem.Score, weights[i])
+			if cur, ok := result.Score(elem.Member); ok {
+				score = aggregate(cur, score)
+			}
+			result.Add(score, elem.Member)
+		}
+	}
+	return result
+}
+
+// zinter 交集，从最小的有序集合开始遍历，逐个成员判断是否存在于其余有序集合中
+func zinter(zsets []msortedset.SortedSet, weights []float64, aggregate func(a, b float64) float64) msortedset.SortedSet {
+	result := msortedset.NewSkiplist("")
+	order := make([]int, 0, len(zsets))
+	for i, zset := range zsets {
+		if zset == nil {
+			return result
+		}
+		order = append(order, i)
+	}
+	sort.Slice(order, func(i, j int) bool {
+		return zsets[order[i]].Len() < zsets[order[j]].Len()
+	})
+
+	smallest := order[0]
+	for _, elem := range zsetElements(zsets[smallest]) {
+		score := weighted(elem.Score, weights[smallest])
+		found := true
+		for _, i := range order[1:] {
+			other, ok := zsets[i].Score(elem.Member)
+			if !ok {
+				found = false
+				break
+			}
+			score = aggregate(score, weighted(other, weights[i]))
+		}
+		if found {
+			result.Add(score, elem.Member)
+		}
+	}
+	return result
+}
+
+// zdiff 第一个有序集合与其余有序集合的差集，分值取自第一个有序集合
+func zdiff(zsets []msortedset.SortedSet, _ []float64, _ func(a, b float64) float64) msortedset.SortedSet {
+	result := msortedset.NewSkiplist("")
+	for _, elem := range zsetElements(zsets[0]) {
+		found := false
+		for _, other := range zsets[1:] {
+			if other == nil {
+				continue
+			}
+			if _, found = other.Score(elem.Member); found {
+				break
+			}
+		}
+		if !found {
+			result.Add(elem.Score, elem.Member)
+		}
+	}
+	return result
+}
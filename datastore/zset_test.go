@@ -0,0 +1,173 @@
+package datastore
+
+import "testing"
+
+// TestSortedSetConformance 有序集合指令与 redis 行为的一致性
+func TestSortedSetConformance(t *testing.T) {
+	const (
+		nilBulk  = "$-1\r\n"
+		empty    = "*0\r\n"
+		wrongTyp = "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"
+		abc      = "zadd z 1 a 2 b 3 c"
+		lex      = "zadd l 0 a 0 b 0 c 0 d 0 e"
+		all      = "-inf +inf"
+	)
+
+	runReplyCases(t, []replyCase{
+		// ZRANGE 按排名
+		{"zrange all", []string{abc}, "zrange z 0 -1", "*3\r\n$1\r\na\r\n$1\r\nb\r\n$1\r\nc\r\n"},
+		{"zrange negative", []string{abc}, "zrange z -2 -1", "*2\r\n$1\r\nb\r\n$1\r\nc\r\n"},
+		{"zrange stop clamped", []string{abc}, "zrange z 1 100", "*2\r\n$1\r\nb\r\n$1\r\nc\r\n"},
+		{"zrange start after stop", []string{abc}, "zrange z 2 1", empty},
+		{"zrange withscores", []string{abc}, "zrange z 0 0 withscores", "*2\r\n$1\r\na\r\n$1\r\n1\r\n"},
+		{"zrange rev", []string{abc}, "zrange z 0 1 rev", "*2\r\n$1\r\nc\r\n$1\r\nb\r\n"},
+		{"zrange same score by member", []string{"zadd z 1 c 1 a 1 b"}, "zrange z 0 -1", "*3\r\n$1\r\na\r\n$1\r\nb\r\n$1\r\nc\r\n"},
+		{"zrange missing key", nil, "zrange z 0 -1", empty},
+		{"zrange wrong type", []string{"set z a"}, "zrange z 0 -1", wrongTyp},
+
+		// 排名与查询
+		{"zrank", []string{abc}, "zrank z b", ":1\r\n"},
+		{"zrank after removal", []string{abc, "zrem z a"}, "zrank z c", ":1\r\n"},
+		{"zrank withscore", []string{abc}, "zrank z c withscore", "*2\r\n:2\r\n$1\r\n3\r\n"},
+		{"zrevrank", []string{abc}, "zrevrank z a", ":2\r\n"},
+		{"zrank missing member", []string{abc}, "zrank z x", nilBulk},
+		{"zrank missing key", nil, "zrank z a", nilBulk},
+		{"zcard", []string{abc}, "zcard z", ":3\r\n"},
+		{"zcard missing key", nil, "zcard z", ":0\r\n"},
+		{"zscore missing member", []string{abc}, "zscore z x", nilBulk},
+		{"zmscore", []string{abc}, "zmscore z c x a", "*3\r\n$1\r\n3\r\n$-1\r\n$1\r\n1\r\n"},
+		{"zcount", []string{abc}, "zcount z 2 +inf", ":2\r\n"},
+		{"zcount missing key", nil, "zcount z " + all, ":0\r\n"},
+
+		// ZRANGE 按分值
+		{"zrange byscore", []string{abc}, "zrange z 2 3 byscore", "*2\r\n$1\r\nb\r\n$1\r\nc\r\n"},
+		{"zrange byscore exclusive", []string{abc}, "zrange z (1 (3 byscore", "*1\r\n$1\r\nb\r\n"},
+		{"zrange byscore inf", []string{abc}, "zrange z -inf +inf byscore", "*3\r\n$1\r\na\r\n$1\r\nb\r\n$1\r\nc\r\n"},
+		{"zrange byscore rev", []string{abc}, "zrange z 3 2 byscore rev", "*2\r\n$1\r\nc\r\n$1\r\nb\r\n"},
+		{"zrange byscore limit", []string{abc}, "zrange z -inf +inf byscore limit 1 1", "*1\r\n$1\r\nb\r\n"},
+		{"zrange byscore limit negative count", []string{abc}, "zrange z -inf +inf byscore limit 1 -1", "*2\r\n$1\r\nb\r\n$1\r\nc\r\n"},
+		{"zrange limit without by", []string{abc}, "zrange z 0 -1 limit 0 1", "-ERR syntax error, LIMIT is only supported in combination with either BYSCORE or BYLEX\r\n"},
+		{"zrevrangebyscore limit", []string{abc}, "zrevrangebyscore z +inf -inf limit 0 2", "*2\r\n$1\r\nc\r\n$1\r\nb\r\n"},
+		{"zrevrangebyscore exclusive", []string{abc}, "zrevrangebyscore z (3 1 withscores", "*4\r\n$1\r\nb\r\n$1\r\n2\r\n$1\r\na\r\n$1\r\n1\r\n"},
+		{"zrangebyscore empty interval", []string{abc}, "zrangebyscore z (2 (2", empty},
+		{"zrangebyscore invalid border", []string{abc}, "zrangebyscore z x 2", "-ERR min or max is not a float\r\n"},
+		{"zrangebyscore exclusive inf", []string{abc}, "zrangebyscore z (-inf (2", "*1\r\n$1\r\na\r\n"},
+		{"zcount exclusive", []string{abc}, "zcount z (1 3", ":2\r\n"},
+
+		// 字典序区间
+		{"zrangebylex inclusive", []string{lex}, "zrangebylex l [b [d", "*3\r\n$1\r\nb\r\n$1\r\nc\r\n$1\r\nd\r\n"},
+		{"zrangebylex exclusive", []string{lex}, "zrangebylex l (b (d", "*1\r\n$1\r\nc\r\n"},
+		{"zrangebylex infinite", []string{lex}, "zrangebylex l - (c", "*2\r\n$1\r\na\r\n$1\r\nb\r\n"},
+		{"zrangebylex limit", []string{lex}, "zrangebylex l - + limit 3 5", "*2\r\n$1\r\nd\r\n$1\r\ne\r\n"},
+		{"zrangebylex plus as min", []string{lex}, "zrangebylex l + -", empty},
+		{"zrange bylex rev", []string{lex}, "zrange l [d [b bylex rev", "*3\r\n$1\r\nd\r\n$1\r\nc\r\n$1\r\nb\r\n"},
+		{"zrangebylex invalid border", []string{lex}, "zrangebylex l b d", "-ERR min or max not valid string range item\r\n"},
+		{"zlexcount", []string{lex}, "zlexcount l (a [c", ":2\r\n"},
+
+		// ZREMRANGEBY*
+		{"zremrangebyscore", []string{abc, "zremrangebyscore z (1 2"}, "zrange z 0 -1", "*2\r\n$1\r\na\r\n$1\r\nc\r\n"},
+		{"zremrangebyscore returns removed", []string{abc}, "zremrangebyscore z -inf (3", ":2\r\n"},
+		{"zremrangebylex", []string{lex, "zremrangebylex l [b (e"}, "zrange l 0 -1", "*2\r\n$1\r\na\r\n$1\r\ne\r\n"},
+		{"zremrangebyrank", []string{abc}, "zremrangebyrank z -2 -1", ":2\r\n"},
+		{"zremrangebyrank keeps spans", []string{"zadd z 1 a 2 b 3 c 4 d 5 e", "zremrangebyrank z 1 2"}, "zrank z e", ":2\r\n"},
+		{"zremrangebyrank all deletes key", []string{abc, "zremrangebyrank z 0 -1"}, "exists z", ":0\r\n"},
+		{"zremrangebyscore missing key", nil, "zremrangebyscore z " + all, ":0\r\n"},
+
+		// 浮点分值
+		{"zscore fraction", []string{"zadd z 1.5 a"}, "zscore z a", "$3\r\n1.5\r\n"},
+		{"zscore inf", []string{"zadd z +inf a"}, "zscore z a", "$3\r\ninf\r\n"},
+		{"score fraction", []string{"zadd z 1.5 a"}, "zrangebyscore z " + all + " withscores", "*2\r\n$1\r\na\r\n$3\r\n1.5\r\n"},
+		{"score exponent", []string{"zadd z 1e3 a"}, "zrangebyscore z " + all + " withscores", "*2\r\n$1\r\na\r\n$4\r\n1000\r\n"},
+		{"score inf", []string{"zadd z +inf a"}, "zrangebyscore z " + all + " withscores", "*2\r\n$1\r\na\r\n$3\r\ninf\r\n"},
+		{"score negative inf", []string{"zadd z -inf a"}, "zrangebyscore z " + all + " withscores", "*2\r\n$1\r\na\r\n$4\r\n-inf\r\n"},
+		{"fractional order", []string{"zadd z 2 a 1.25 b 1.5 c -inf d"}, "zrangebyscore z " + all, "*4\r\n$1\r\nd\r\n$1\r\nb\r\n$1\r\nc\r\n$1\r\na\r\n"},
+		{"fractional bounds", []string{"zadd z 1 a 1.25 b 1.5 c"}, "zrangebyscore z 1.1 1.5", "*2\r\n$1\r\nb\r\n$1\r\nc\r\n"},
+		{"equal scores by member", []string{"zadd z 1 c 1 a 1 b"}, "zrangebyscore z 1 1", "*3\r\n$1\r\na\r\n$1\r\nb\r\n$1\r\nc\r\n"},
+		{"zadd invalid score", nil, "zadd z x a", "-ERR value is not a valid float\r\n"},
+		{"zincrby fraction", []string{"zadd z 0.1 a"}, "zincrby z 0.2 a", "$19\r\n0.30000000000000004\r\n"},
+		{"zincrby inf minus inf", []string{"zadd z +inf a"}, "zincrby z -inf a", "-ERR resulting score is not a number (NaN)\r\n"},
+		{"zadd nan score", nil, "zadd z nan a", "-ERR value is not a valid float\r\n"},
+		{"zrangebyscore invalid bound", []string{"zadd z 1 a"}, "zrangebyscore z x 1", "-ERR min or max is not a float\r\n"},
+		// ZADD 选项
+		{"zadd returns added", []string{abc}, "zadd z 5 a 4 d", ":1\r\n"},
+		{"zadd ch counts changed", []string{abc}, "zadd z ch 5 a 4 d 2 b", ":2\r\n"},
+		{"zadd nx skips existing", []string{abc, "zadd z nx 5 a 4 d"}, "zscore z a", "$1\r\n1\r\n"},
+		{"zadd xx skips new", []string{abc, "zadd z xx 5 a 4 d"}, "zscore z d", nilBulk},
+		{"zadd gt updates greater", []string{abc, "zadd z gt 5 a 0 b"}, "zrange z 0 -1 withscores",
+			"*6\r\n$1\r\nb\r\n$1\r\n2\r\n$1\r\nc\r\n$1\r\n3\r\n$1\r\na\r\n$1\r\n5\r\n"},
+		{"zadd lt updates less", []string{abc, "zadd z lt 5 a 0 b"}, "zscore z b", "$1\r\n0\r\n"},
+		{"zadd gt adds new", []string{abc}, "zadd z gt 1 d", ":1\r\n"},
+		{"zadd nx and xx", []string{abc}, "zadd z nx xx 1 a", "-ERR XX and NX options at the same time are not compatible\r\n"},
+		{"zadd gt and nx", []string{abc}, "zadd z gt nx 1 a", "-ERR GT, LT, and/or NX options at the same time are not compatible\r\n"},
+		{"zadd incr", []string{abc}, "zadd z incr 2.5 a", "$3\r\n3.5\r\n"},
+		{"zadd incr multiple pairs", []string{abc}, "zadd z incr 1 a 2 b", "-ERR INCR option supports a single increment-element pair\r\n"},
+		{"zadd incr nx existing", []string{abc}, "zadd z nx incr 1 a", nilBulk},
+		{"zadd incr gt not applied", []string{abc}, "zadd z gt incr -1 a", nilBulk},
+		{"zincrby", []string{abc}, "zincrby z -1 c", "$1\r\n2\r\n"},
+		{"zincrby new member", nil, "zincrby z 2 a", "$1\r\n2\r\n"},
+		{"zincrby wrong type", []string{"set z a"}, "zincrby z 1 a", wrongTyp},
+
+		// ZPOPMIN / ZPOPMAX / ZMPOP
+		{"zpopmin", []string{abc}, "zpopmin z", "*2\r\n$1\r\na\r\n$1\r\n1\r\n"},
+		{"zpopmax count", []string{abc}, "zpopmax z 2", "*4\r\n$1\r\nc\r\n$1\r\n3\r\n$1\r\nb\r\n$1\r\n2\r\n"},
+		{"zpopmin missing key", nil, "zpopmin z", empty},
+		{"zpopmin all deletes key", []string{abc, "zpopmin z 5"}, "exists z", ":0\r\n"},
+		{"zpopmin negative count", []string{abc}, "zpopmin z -1", "-ERR value is out of range, must be positive\r\n"},
+		{"zmpop first non empty", []string{"zadd y 7 x", abc}, "zmpop 3 none z y max count 2",
+			"*2\r\n$1\r\nz\r\n*2\r\n*2\r\n$1\r\nc\r\n$1\r\n3\r\n*2\r\n$1\r\nb\r\n$1\r\n2\r\n"},
+		{"zmpop all missing", nil, "zmpop 1 z min", "*-1\r\n"},
+		{"zmpop bad direction", []string{abc}, "zmpop 1 z first", "-Err syntax error\r\n"},
+
+		// 集合运算
+		{"zunion sums scores", []string{abc, "zadd y 10 b 20 d"}, "zunion 2 z y withscores",
+			"*8\r\n$1\r\na\r\n$1\r\n1\r\n$1\r\nc\r\n$1\r\n3\r\n$1\r\nb\r\n$2\r\n12\r\n$1\r\nd\r\n$2\r\n20\r\n"},
+		{"zunion weights", []string{abc, "zadd y 10 b"}, "zunion 2 z y weights 2 0.5 withscores",
+			"*6\r\n$1\r\na\r\n$1\r\n2\r\n$1\r\nc\r\n$1\r\n6\r\n$1\r\nb\r\n$1\r\n9\r\n"},
+		{"zunion aggregate max", []string{abc, "zadd y 10 b 0 c"}, "zunion 2 z y aggregate max withscores",
+			"*6\r\n$1\r\na\r\n$1\r\n1\r\n$1\r\nc\r\n$1\r\n3\r\n$1\r\nb\r\n$2\r\n10\r\n"},
+		{"zinter aggregate min", []string{abc, "zadd y 10 b 0 c"}, "zinter 2 z y aggregate min withscores",
+			"*4\r\n$1\r\nc\r\n$1\r\n0\r\n$1\r\nb\r\n$1\r\n2\r\n"},
+		{"zinter missing key", []string{abc}, "zinter 2 z none", empty},
+		{"zdiff", []string{abc, "zadd y 1 b"}, "zdiff 2 z y", "*2\r\n$1\r\na\r\n$1\r\nc\r\n"},
+		{"zunion inf minus inf is zero", []string{"zadd z +inf a", "zadd y -inf a"}, "zunion 2 z y withscores", "*2\r\n$1\r\na\r\n$1\r\n0\r\n"},
+		{"zunionstore", []string{abc, "zadd y 10 b"}, "zunionstore dst 2 z y", ":3\r\n"},
+		{"zdiffstore", []string{abc, "zadd y 1 b"}, "zdiffstore dst 2 z y", ":2\r\n"},
+		{"zinterstore empty deletes dst", []string{abc, "set dst x", "zinterstore dst 2 z none"}, "exists dst", ":0\r\n"},
+		{"zunion numkeys zero", nil, "zunion 0 z", "-ERR at least 1 input key is needed for 'zunion' command\r\n"},
+		{"zunion wrong type", []string{abc, "set y x"}, "zunion 2 z y", wrongTyp},
+
+		{"zrangebyscore missing key", nil, "zrangebyscore z " + all, empty},
+	})
+}
+
+// TestSortedSetPersistence 有序集合指令持久化后重放的结果一致
+func TestSortedSetPersistence(t *testing.T) {
+	s := newTestStore(t)
+	for _, line := range []string{
+		"zadd z 0.1 a 1e-3 b -inf c +inf d 3.14159 e",
+		"zadd z 2.5 a",
+		"zrem z e",
+		"zadd r 1 a 2 b 3 c 4 d 5 e 6 f",
+		"zremrangebyscore r (1 2",
+		"zremrangebyrank r -1 -1",
+		"zadd l 0 a 0 b 0 c 0 d 0 e",
+		"zremrangebylex l (a [c",
+		"zadd drained 1 a",
+		"zremrangebyrank drained 0 -1",
+		"zadd o 1 a 2 b 3 c 4 d",
+		"zadd o gt ch 0 a 5 b",
+		"zadd o incr 1.5 c",
+		"zincrby o 2 d",
+		"zpopmin o",
+		"zmpop 1 o max",
+		"zadd y 1 x 2 b",
+		"zunionstore u 2 o y weights 1 2",
+		"zinterstore i 2 o y aggregate max",
+		"zdiffstore d 2 y o",
+	} {
+		s.exec(line)
+	}
+
+	assertReplayed(t, s, "zrangebyscore z -inf +inf withscores", "zrange r 0 -1 withscores", "zrange l 0 -1", "exists drained",
+		"zrange o 0 -1 withscores", "zrange u 0 -1 withscores", "zrange i 0 -1 withscores", "zrange d 0 -1 withscores")
+}
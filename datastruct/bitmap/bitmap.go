@@ -1,32 +1,31 @@
 package mbitmap
 
 import (
-	"strconv"
-
-	def "github.com/lovelydayss/goredis/interface"
+	"encoding/binary"
+	"math/bits"
 )
 
 // BitMap 位图操作接口
+// 与 redis 一致，偏移量 0 对应首个字节的最高位
 type BitMap interface {
-	Count() []byte
-	SetBit(offset int64, val byte)
-	GetBit(offset int64) []byte
-	def.CmdAdapter
+	Bytes() []byte
+	Len() int64
+	Count(start, end int64) int64
+	Pos(bit byte, start, end int64) int64
+	SetBit(offset int64, val byte) byte
+	GetBit(offset int64) byte
+	SetBits(offset int64, width int, value uint64)
+	GetBits(offset int64, width int) uint64
 }
 
-// BitMapEntity BitMap 实体
+// BitMapEntity BitMap 实体，为字节切片之上的位视图，不单独持有数据
 type BitMapEntity struct {
-	key  string
-	data []byte
+	data *[]byte
 }
 
-// NewBitMapEntity 初始化
-func NewBitMapEntity(key string) BitMap {
-
-	return &BitMapEntity{
-		key:  key,
-		data: make([]byte, 0),
-	}
+// NewBitMap 以字节切片的引用初始化，位图与字符串共享同一份数据，扩容结果写回引用
+func NewBitMap(data *[]byte) BitMap {
+	return &BitMapEntity{data: data}
 }
 
 func toByteSize(bitSize int64) int64 {
@@ -39,58 +38,122 @@ func toByteSize(bitSize int64) int64 {
 // grow 扩容
 func (b *BitMapEntity) grow(bitSize int64) {
 	byteSize := toByteSize(bitSize)
-	gap := byteSize - int64(len(b.data))
+	gap := byteSize - int64(len(*b.data))
 	if gap <= 0 {
 		return
 	}
-	b.data = append(b.data, make([]byte, gap)...)
+	*b.data = append(*b.data, make([]byte, gap)...)
+}
+
+// Bytes 原始字节，返回副本，避免回包期间被后续指令原地修改
+func (b *BitMapEntity) Bytes() []byte {
+	return append([]byte{}, *b.data...)
+}
+
+// Len 位图占用的字节数
+func (b *BitMapEntity) Len() int64 {
+	return int64(len(*b.data))
 }
 
-// Count 位图中 1 的位数
-func (b *BitMapEntity) Count() []byte {
+// Count 闭区间 [start, end] 内 1 的位数，调用方保证 0 <= start <= end < Len * 8
+func (b *BitMapEntity) Count(start, end int64) int64 {
+	data := *b.data
+	first, last := start/8, end/8
+	headMask := byte(0xff) >> (start % 8)
+	tailMask := byte(0xff) << (7 - end%8)
+	if first == last {
+		return int64(bits.OnesCount8(data[first] & headMask & tailMask))
+	}
 
-	// todo
-	return []byte(strconv.FormatInt(int64(len(b.data)*8), 10))
+	count := bits.OnesCount8(data[first]&headMask) + bits.OnesCount8(data[last]&tailMask)
+	return int64(count) + popcount(data[first+1:last])
 }
 
-// SetBit 设置位图某个位置的值
-func (b *BitMapEntity) SetBit(offset int64, val byte) {
+// popcount 统计字节切片中 1 的位数，按 8 字节分组交由 bits.OnesCount64 处理，可编译为 POPCNT 指令
+func popcount(data []byte) int64 {
+	var count int
+	for ; len(data) >= 8; data = data[8:] {
+		count += bits.OnesCount64(binary.LittleEndian.Uint64(data))
+	}
+	for _, v := range data {
+		count += bits.OnesCount8(v)
+	}
+	return int64(count)
+}
+
+// Pos 闭区间 [start, end] 内首个值为 bit 的位，不存在时返回 -1，调用方保证 0 <= start <= end < Len * 8
+func (b *BitMapEntity) Pos(bit byte, start, end int64) int64 {
+	data := *b.data
+	// 整字节均不为 bit 时整体跳过
+	skip := byte(0)
+	if bit == 0 {
+		skip = 0xff
+	}
+
+	for i := start; i <= end; {
+		if i%8 == 0 && i+7 <= end && data[i/8] == skip {
+			i += 8
+			continue
+		}
+		if b.GetBit(i) == bit {
+			return i
+		}
+		i++
+	}
+	return -1
+}
+
+// SetBit 设置位图某个位置的值，返回原来的值
+func (b *BitMapEntity) SetBit(offset int64, val byte) byte {
 
 	byteIndex := offset / 8
-	bitOffset := offset % 8
-	mask := byte(1 << bitOffset)
+	mask := byte(0x80) >> (offset % 8)
 
 	// 不需要扩容时返回空
 	b.grow(offset + 1)
+	data := *b.data
 
-	// 设置第 byteIndex 个字节中 bitOffset 位的值为 val
+	old := byte(0)
+	if data[byteIndex]&mask != 0 {
+		old = 1
+	}
+
+	// 设置第 byteIndex 个字节中 offset % 8 位的值为 val
 	if val > 0 {
 		// set bit
-		(b.data)[byteIndex] |= mask
+		data[byteIndex] |= mask
 	} else {
 		// clear bit
-		(b.data)[byteIndex] &^= mask
+		data[byteIndex] &^= mask
 	}
+	return old
 }
 
-// GetBit 获取位图某个位置的值
-func (b *BitMapEntity) GetBit(offset int64) []byte {
+// GetBit 获取位图某个位置的值，超出位图长度时为 0
+func (b *BitMapEntity) GetBit(offset int64) byte {
+	data := *b.data
 	byteIndex := offset / 8
-	bitOffset := offset % 8
-
-	if byteIndex >= int64(len(b.data)) {
-		return nil
+	if byteIndex >= int64(len(data)) {
+		return 0
 	}
 
 	// 直接移位求解值
-	res := ((b.data)[byteIndex] >> bitOffset) & 0x01
-	return []byte(strconv.FormatInt(int64(res), 10))
+	return (data[byteIndex] >> (7 - offset%8)) & 0x01
 }
 
-// ToCmd 生成setbit指令
-func (b *BitMapEntity) ToCmd() [][]byte {
-	args := make([][]byte, 0, 2+2*len(b.data))
-	args = append(args, []byte(def.CmdTypeBitmapSet), []byte(b.key), b.data)
+// SetBits 将 value 的低 width 位写入从 offset 开始的位，高位在前
+func (b *BitMapEntity) SetBits(offset int64, width int, value uint64) {
+	b.grow(offset + int64(width))
+	for i := 0; i < width; i++ {
+		b.SetBit(offset+int64(i), byte(value>>(width-1-i))&0x01)
+	}
+}
 
-	return args
+// GetBits 读取从 offset 开始的 width 位作为无符号整数，高位在前，超出位图长度的位为 0
+func (b *BitMapEntity) GetBits(offset int64, width int) uint64 {
+	var value uint64
+	for i := 0; i < width; i++ {
+		value = value<<1 | uint64(b.GetBit(offset+int64(i)))
+	}
+	return value
 }
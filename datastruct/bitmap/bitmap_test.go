@@ -1 +1,87 @@
 package mbitmap
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// TestBitMapAgainstBits 随机读写位图，与逐位存储的实现结果逐一比对
+func TestBitMapAgainstBits(t *testing.T) {
+	var (
+		rander = rand.New(rand.NewSource(1))
+		data   []byte
+		got    = NewBitMap(&data)
+		want   []byte // 每个元素存储一位
+	)
+	set := func(offset int64, val byte) {
+		for int64(len(want)) <= offset {
+			want = append(want, 0)
+		}
+		want[offset] = val
+	}
+	get := func(offset int64) byte {
+		if offset < int64(len(want)) {
+			return want[offset]
+		}
+		return 0
+	}
+
+	for i := 0; i < 20000; i++ {
+		switch op := rander.Intn(5); op {
+		case 0:
+			offset, val := int64(rander.Intn(1024)), byte(rander.Intn(2))
+			if old := got.SetBit(offset, val); old != get(offset) {
+				t.Fatalf("op %d: setbit %d old %d, want %d", i, offset, old, get(offset))
+			}
+			set(offset, val)
+		case 1:
+			offset, width := int64(rander.Intn(1024)), rander.Intn(64)+1
+			value := rander.Uint64()
+			got.SetBits(offset, width, value)
+			for j := 0; j < width; j++ {
+				set(offset+int64(j), byte(value>>(width-1-j))&0x01)
+			}
+		case 2:
+			offset, width := int64(rander.Intn(1100)), rander.Intn(64)+1
+			var value uint64
+			for j := 0; j < width; j++ {
+				value = value<<1 | uint64(get(offset+int64(j)))
+			}
+			if g := got.GetBits(offset, width); g != value {
+				t.Fatalf("op %d: getbits %d %d => %d, want %d", i, offset, width, g, value)
+			}
+		case 3, 4:
+			if got.Len() == 0 {
+				continue
+			}
+			start := int64(rander.Intn(int(got.Len() * 8)))
+			end := start + int64(rander.Intn(int(got.Len()*8-start)))
+
+			var count int64
+			pos := [2]int64{-1, -1}
+			for j := start; j <= end; j++ {
+				count += int64(get(j))
+				if pos[get(j)] == -1 {
+					pos[get(j)] = j
+				}
+			}
+			if g := got.Count(start, end); g != count {
+				t.Fatalf("op %d: count %d %d => %d, want %d", i, start, end, g, count)
+			}
+			for bit := byte(0); bit <= 1; bit++ {
+				if g := got.Pos(bit, start, end); g != pos[bit] {
+					t.Fatalf("op %d: pos %d %d %d => %d, want %d", i, bit, start, end, g, pos[bit])
+				}
+			}
+		}
+
+		if wantLen := (int64(len(want)) + 7) / 8; got.Len() != wantLen {
+			t.Fatalf("op %d: len %d, want %d", i, got.Len(), wantLen)
+		}
+	}
+
+	// 扩容结果写回共享的字节切片
+	if int64(len(data)) != got.Len() {
+		t.Fatalf("shared bytes len %d, want %d", len(data), got.Len())
+	}
+}
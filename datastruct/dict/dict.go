@@ -0,0 +1,292 @@
+package mdict
+
+import (
+	"hash/maphash"
+	"math/bits"
+	"math/rand"
+)
+
+const (
+	initSize          = 4  // 初始桶数量
+	shrinkFactor      = 8  // 元素数量低于桶数量的 1/shrinkFactor 时缩容
+	rehashEmptyVisits = 10 // 单步 rehash 最多跳过的空桶数量
+)
+
+// Dict 基于链地址法的哈希表，桶数量恒为 2 的幂
+// 与 go 原生 map 相比，支持游标式的增量遍历以及 O(1) 的随机取 key
+// 与 redis 一致采用渐进式 rehash：扩缩容时同时持有新旧两张表，每次读写只迁移旧表的一个桶
+// ForEach 与 Scan 的回调执行期间暂停 rehash 并推迟扩缩容，回调中可以删除当前 key，
+// 但写入新 key 或删除其他 key 时，不保证这些 key 是否会被本次遍历访问到
+type Dict[V any] struct {
+	tables    [2][]*entry[V] // tables[1] 仅在 rehash 期间存在，新节点只写入 tables[1]
+	size      int
+	rehashIdx int // 旧表中下一个待迁移的桶下标，-1 表示未在 rehash
+	pauses    int // 进行中的遍历数量，大于 0 时暂停 rehash
+	seed      maphash.Seed
+}
+
+// entry 哈希表节点
+type entry[V any] struct {
+	key   string
+	value V
+	next  *entry[V]
+}
+
+// New 初始化
+func New[V any]() *Dict[V] {
+	return &Dict[V]{
+		tables:    [2][]*entry[V]{make([]*entry[V], initSize)},
+		rehashIdx: -1,
+		seed:      maphash.MakeSeed(),
+	}
+}
+
+// Len 元素数量
+func (d *Dict[V]) Len() int {
+	return d.size
+}
+
+// rehashing 是否正在 rehash
+func (d *Dict[V]) rehashing() bool {
+	return d.rehashIdx >= 0
+}
+
+// hash key 的哈希值
+func (d *Dict[V]) hash(key string) uint64 {
+	return maphash.String(d.seed, key)
+}
+
+// mask 表 t 的桶下标掩码
+func (d *Dict[V]) mask(t int) uint64 {
+	return uint64(len(d.tables[t]) - 1)
+}
+
+// find 查找 key 所在的节点，rehash 期间依次查找新旧两张表
+func (d *Dict[V]) find(key string) *entry[V] {
+	h := d.hash(key)
+	for t := 0; t < 2; t++ {
+		for e := d.tables[t][h&d.mask(t)]; e != nil; e = e.next {
+			if e.key == key {
+				return e
+			}
+		}
+		if !d.rehashing() {
+			break
+		}
+	}
+	return nil
+}
+
+// Get 查询 key 对应的值
+func (d *Dict[V]) Get(key string) (V, bool) {
+	d.rehashStep()
+	if e := d.find(key); e != nil {
+		return e.value, true
+	}
+	var zero V
+	return zero, false
+}
+
+// Put 写入键值对，返回 key 是否为新增
+func (d *Dict[V]) Put(key string, value V) bool {
+	d.rehashStep()
+	if e := d.find(key); e != nil {
+		e.value = value
+		return false
+	}
+
+	t := 0
+	if d.rehashing() {
+		t = 1
+	}
+	i := d.hash(key) & d.mask(t)
+	d.tables[t][i] = &entry[V]{key: key, value: value, next: d.tables[t][i]}
+	d.size++
+
+	// 负载因子达到 1 时扩容
+	if d.size >= len(d.tables[0]) {
+		d.resize(len(d.tables[0]) << 1)
+	}
+	return true
+}
+
+// Delete 删除 key，返回被删除的值
+func (d *Dict[V]) Delete(key string) (V, bool) {
+	d.rehashStep()
+	h := d.hash(key)
+	for t := 0; t < 2; t++ {
+		i := h & d.mask(t)
+		for prev, e := (*entry[V])(nil), d.tables[t][i]; e != nil; prev, e = e, e.next {
+			if e.key != key {
+				continue
+			}
+
+			if prev == nil {
+				d.tables[t][i] = e.next
+			} else {
+				prev.next = e.next
+			}
+			d.size--
+
+			if len(d.tables[0]) > initSize && d.size*shrinkFactor < len(d.tables[0]) {
+				d.resize(len(d.tables[0]) >> 1)
+			}
+			return e.value, true
+		}
+		if !d.rehashing() {
+			break
+		}
+	}
+
+	var zero V
+	return zero, false
+}
+
+// resize 创建 n 个桶的新表并开始渐进式 rehash
+// 已在 rehash 或遍历进行中时推迟到之后的写入
+func (d *Dict[V]) resize(n int) {
+	if d.rehashing() || d.pauses > 0 {
+		return
+	}
+	d.tables[1] = make([]*entry[V], n)
+	d.rehashIdx = 0
+}
+
+// rehashStep 将旧表的一个非空桶迁移到新表，最多跳过 rehashEmptyVisits 个空桶
+// 旧表全部迁移后以新表替换旧表，遍历进行中时不迁移
+func (d *Dict[V]) rehashStep() {
+	if !d.rehashing() || d.pauses > 0 {
+		return
+	}
+
+	old := d.tables[0]
+	for empty := 0; d.rehashIdx < len(old) && old[d.rehashIdx] == nil; empty++ {
+		if empty == rehashEmptyVisits {
+			return
+		}
+		d.rehashIdx++
+	}
+
+	if d.rehashIdx < len(old) {
+		for e := old[d.rehashIdx]; e != nil; {
+			next := e.next
+			i := d.hash(e.key) & d.mask(1)
+			e.next = d.tables[1][i]
+			d.tables[1][i] = e
+			e = next
+		}
+		old[d.rehashIdx] = nil
+		d.rehashIdx++
+	}
+
+	if d.rehashIdx == len(old) {
+		d.tables[0], d.tables[1] = d.tables[1], nil
+		d.rehashIdx = -1
+	}
+}
+
+// ForEach 遍历所有键值对，fn 返回 false 时终止遍历
+// fn 中可以删除当前 key，写入或删除其他 key 见 Dict 的说明
+func (d *Dict[V]) ForEach(fn func(key string, value V) bool) {
+	d.pauses++
+	defer func() { d.pauses-- }()
+
+	for t := 0; t < 2; t++ {
+		for _, e := range d.tables[t] {
+			for e != nil {
+				next := e.next
+				if !fn(e.key, e.value) {
+					return
+				}
+				e = next
+			}
+		}
+	}
+}
+
+// Scan 遍历游标指向的桶，返回下一个游标，返回 0 表示遍历结束
+// 与 redis 一致，游标按桶下标的二进制逆序递增：
+// 哈希表在两次调用之间扩缩容时，扩容前已访问的桶对应的所有新桶都不会被再次访问，
+// 因此遍历全程存在的元素至少会被返回一次，缩容时元素可能被重复返回
+// rehash 期间先访问小表中游标指向的桶，再访问大表中由该桶展开得到的所有桶
+// fn 中可以删除当前 key，写入或删除其他 key 见 Dict 的说明
+func (d *Dict[V]) Scan(cursor uint64, fn func(key string, value V)) uint64 {
+	if d.size == 0 {
+		return 0
+	}
+
+	d.pauses++
+	defer func() { d.pauses-- }()
+
+	small, large := d.tables[0], d.tables[1]
+	if !d.rehashing() {
+		mask := uint64(len(small) - 1)
+		scanBucket(small[cursor&mask], fn)
+		return nextCursor(cursor, mask)
+	}
+
+	if len(small) > len(large) {
+		small, large = large, small
+	}
+	m0, m1 := uint64(len(small)-1), uint64(len(large)-1)
+	scanBucket(small[cursor&m0], fn)
+	for {
+		scanBucket(large[cursor&m1], fn)
+		// 游标中超出小表掩码的高位全部回到 0 时，展开得到的桶已访问完
+		if cursor = nextCursor(cursor, m1); cursor&(m0^m1) == 0 {
+			return cursor
+		}
+	}
+}
+
+// scanBucket 依次回调桶中的节点，fn 删除当前节点不影响后续节点
+func scanBucket[V any](e *entry[V], fn func(key string, value V)) {
+	for e != nil {
+		next := e.next
+		fn(e.key, e.value)
+		e = next
+	}
+}
+
+// nextCursor 高位补 1 后对逆序的游标加 1，即在掩码范围内按二进制逆序递增
+func nextCursor(cursor, mask uint64) uint64 {
+	cursor |= ^mask
+	cursor = bits.Reverse64(cursor)
+	cursor++
+	return bits.Reverse64(cursor)
+}
+
+// RandomKey 随机返回一个 key
+func (d *Dict[V]) RandomKey() (string, bool) {
+	if d.size == 0 {
+		return "", false
+	}
+	d.rehashStep()
+
+	// 负载因子不低于 1/shrinkFactor，期望若干次即可命中非空桶
+	// rehash 期间旧表中下标小于 rehashIdx 的桶已迁移，只在其余的桶中选取
+	var e *entry[V]
+	for e == nil {
+		if !d.rehashing() {
+			e = d.tables[0][rand.Intn(len(d.tables[0]))]
+			continue
+		}
+
+		n0 := len(d.tables[0])
+		i := d.rehashIdx + rand.Intn(n0+len(d.tables[1])-d.rehashIdx)
+		if i < n0 {
+			e = d.tables[0][i]
+		} else {
+			e = d.tables[1][i-n0]
+		}
+	}
+
+	var n int
+	for p := e; p != nil; p = p.next {
+		n++
+	}
+	for i := rand.Intn(n); i > 0; i-- {
+		e = e.next
+	}
+	return e.key, true
+}
@@ -0,0 +1,255 @@
+package mdict
+
+import (
+	"math/rand"
+	"strconv"
+	"testing"
+)
+
+// newDict 写入 key0 至 key{n-1}，值为下标
+func newDict(n int) *Dict[int] {
+	d := New[int]()
+	for i := 0; i < n; i++ {
+		d.Put("key"+strconv.Itoa(i), i)
+	}
+	return d
+}
+
+// TestDictAgainstMap 随机增删查，与 map 的结果逐一比对，覆盖渐进式 rehash 中的读写
+func TestDictAgainstMap(t *testing.T) {
+	for round := 0; round < 100; round++ {
+		var (
+			rander = rand.New(rand.NewSource(int64(round)))
+			got    = New[int]()
+			want   = make(map[string]int)
+		)
+
+		for i := 0; i < 2000; i++ {
+			key := strconv.Itoa(rander.Intn(512))
+			v, exist := want[key]
+			switch rander.Intn(3) {
+			case 0:
+				if g, ok := got.Get(key); ok != exist || g != v {
+					t.Fatalf("round %d op %d: get %s => %d %v, want %d %v", round, i, key, g, ok, v, exist)
+				}
+			case 1:
+				if added := got.Put(key, i); added == exist {
+					t.Fatalf("round %d op %d: put %s => %v, want %v", round, i, key, added, !exist)
+				}
+				want[key] = i
+			case 2:
+				if g, ok := got.Delete(key); ok != exist || g != v {
+					t.Fatalf("round %d op %d: delete %s => %d %v, want %d %v", round, i, key, g, ok, v, exist)
+				}
+				delete(want, key)
+			}
+
+			if got.Len() != len(want) {
+				t.Fatalf("round %d op %d: len %d, want %d", round, i, got.Len(), len(want))
+			}
+		}
+
+		seen := make(map[string]int)
+		got.ForEach(func(key string, value int) bool {
+			seen[key] = value
+			return true
+		})
+		if len(seen) != len(want) {
+			t.Fatalf("round %d: foreach visited %d keys, want %d", round, len(seen), len(want))
+		}
+		for key, v := range want {
+			if seen[key] != v {
+				t.Fatalf("round %d: foreach %s => %d, want %d", round, key, seen[key], v)
+			}
+		}
+	}
+}
+
+// TestDictScan 两次调用之间扩缩容或处于 rehash 中时，遍历全程存在的 key 至少返回一次
+func TestDictScan(t *testing.T) {
+	tests := []struct {
+		name   string
+		size   int
+		mutate func(d *Dict[int], call int) // 每次调用 Scan 后执行
+		stable func(key string) bool        // 遍历全程存在的 key
+	}{
+		{
+			name:   "no change",
+			size:   1000,
+			mutate: func(d *Dict[int], call int) {},
+			stable: func(string) bool { return true },
+		},
+		{
+			name: "grow between calls",
+			size: 100,
+			mutate: func(d *Dict[int], call int) {
+				if call == 3 {
+					for i := 0; i < 5000; i++ {
+						d.Put("new"+strconv.Itoa(i), i)
+					}
+				}
+			},
+			stable: func(string) bool { return true },
+		},
+		{
+			name: "shrink between calls",
+			size: 5000,
+			mutate: func(d *Dict[int], call int) {
+				if call == 3 {
+					for i := 50; i < 5000; i++ {
+						d.Delete("key" + strconv.Itoa(i))
+					}
+				}
+			},
+			stable: func(key string) bool {
+				i, _ := strconv.Atoi(key[len("key"):])
+				return i < 50
+			},
+		},
+		{
+			name: "grow then shrink while rehashing",
+			size: 200,
+			mutate: func(d *Dict[int], call int) {
+				// 每次调用之间只推进少量 rehash，使遍历跨越多轮扩缩容
+				for i := 0; i < 20; i++ {
+					key := "tmp" + strconv.Itoa(call*20+i)
+					if call < 40 {
+						d.Put(key, i)
+					} else {
+						d.Delete("tmp" + strconv.Itoa((call-40)*20+i))
+					}
+				}
+			},
+			stable: func(key string) bool { return key[:3] == "key" },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := newDict(tt.size)
+			seen := make(map[string]int)
+
+			var cursor uint64
+			for call := 0; ; call++ {
+				cursor = d.Scan(cursor, func(key string, _ int) {
+					seen[key]++
+				})
+				if cursor == 0 {
+					break
+				}
+				tt.mutate(d, call)
+			}
+
+			for i := 0; i < tt.size; i++ {
+				if key := "key" + strconv.Itoa(i); tt.stable(key) && seen[key] == 0 {
+					t.Errorf("%s never returned by scan", key)
+				}
+			}
+		})
+	}
+}
+
+// TestDictDeleteDuringIteration 回调中删除当前 key 时不会触发 rehash，其余 key 仍被访问一次
+func TestDictDeleteDuringIteration(t *testing.T) {
+	tests := []struct {
+		name string
+		walk func(d *Dict[int], fn func(key string))
+	}{
+		{"foreach", func(d *Dict[int], fn func(key string)) {
+			d.ForEach(func(key string, _ int) bool {
+				fn(key)
+				return true
+			})
+		}},
+		{"scan", func(d *Dict[int], fn func(key string)) {
+			for cursor := d.Scan(0, func(key string, _ int) { fn(key) }); cursor != 0; {
+				cursor = d.Scan(cursor, func(key string, _ int) { fn(key) })
+			}
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			const n = 1000
+			d := newDict(n)
+			buckets := len(d.tables[0]) + len(d.tables[1])
+
+			seen := make(map[string]int)
+			tt.walk(d, func(key string) {
+				seen[key]++
+				if _, ok := d.Delete(key); !ok {
+					t.Fatalf("delete %s during iteration failed", key)
+				}
+				if got := len(d.tables[0]) + len(d.tables[1]); got != buckets {
+					t.Fatalf("table resized from %d to %d buckets during iteration", buckets, got)
+				}
+			})
+
+			if len(seen) != n || d.Len() != 0 {
+				t.Fatalf("visited %d keys, %d left, want %d visited and none left", len(seen), d.Len(), n)
+			}
+			for key, c := range seen {
+				if c != 1 {
+					t.Errorf("%s visited %d times", key, c)
+				}
+			}
+
+			// 遍历结束后推迟的缩容在之后的写入中完成
+			for i := 0; i < 100; i++ {
+				d.Put("x", i)
+				d.Delete("x")
+			}
+			if len(d.tables[0]) >= buckets {
+				t.Errorf("%d buckets after iteration, want shrunk below %d", len(d.tables[0]), buckets)
+			}
+		})
+	}
+}
+
+// TestDictRandomKey 随机 key 只来自当前存在的 key，且所有 key 都可能被选中
+func TestDictRandomKey(t *testing.T) {
+	tests := []struct {
+		name string
+		d    func() *Dict[int]
+		keys int // 存在的 key 数量
+	}{
+		{"empty", func() *Dict[int] { return New[int]() }, 0},
+		{"single", func() *Dict[int] { return newDict(1) }, 1},
+		{"stable", func() *Dict[int] { return newDict(64) }, 64},
+		{"while growing", func() *Dict[int] {
+			d := newDict(64)
+			d.Put("key64", 64) // 达到负载因子，开始扩容
+			return d
+		}, 65},
+		{"after shrink", func() *Dict[int] {
+			d := newDict(1000)
+			for i := 16; i < 1000; i++ {
+				d.Delete("key" + strconv.Itoa(i))
+			}
+			return d
+		}, 16},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := tt.d()
+			seen := make(map[string]bool)
+			for i := 0; i < 200*(tt.keys+1); i++ {
+				key, ok := d.RandomKey()
+				if ok != (tt.keys > 0) {
+					t.Fatalf("random key => %q %v on %d keys", key, ok, tt.keys)
+				}
+				if !ok {
+					continue
+				}
+				if _, exist := d.Get(key); !exist {
+					t.Fatalf("random key %q does not exist", key)
+				}
+				seen[key] = true
+			}
+			if len(seen) != tt.keys {
+				t.Errorf("random key covered %d keys, want %d", len(seen), tt.keys)
+			}
+		})
+	}
+}
@@ -1,54 +1,339 @@
 package mhash
 
-import def "github.com/lovelydayss/goredis/interface"
+import (
+	"math"
+	"math/rand"
+	"strconv"
+
+	mdict "github.com/lovelydayss/goredis/datastruct/dict"
+	msortedset "github.com/lovelydayss/goredis/datastruct/sorted_set"
+	def "github.com/lovelydayss/goredis/interface"
+	"github.com/lovelydayss/goredis/lib"
+)
 
 // HashMap hash表结构接口
 type HashMap interface {
-	Put(key string, value []byte)
-	Get(key string) []byte
+	Put(key string, value []byte) int64
+	Get(key string) ([]byte, bool)
 	Del(key string) int64
+	Len() int64
+	ForEach(fn func(field string, value []byte) bool)
+	RandomField() (string, []byte)
+	Scan(cursor uint64, fn func(field string, value []byte)) uint64
+	Encoding() string
+
+	// 字段过期时间，均为 unix 毫秒级时间戳
+	Expire(field string, expireAt int64) bool
+	ExpireAt(field string) (int64, bool)
+	Persist(field string) bool
+	NextExpire() (int64, bool)
+	ExpireFields(now int64) int64
+
 	def.CmdAdapter
+	def.MultiCmdAdapter
+	def.MemoryAdapter
+}
+
+// hash 表编码，字段较少时采用紧凑列表节省内存，超出阈值后转换为哈希表，不再转换回紧凑编码
+const (
+	EncodingListpack  = "listpack"  // 键值交替排列的数组
+	EncodingHashtable = "hashtable" // 哈希表
+)
+
+const (
+	hashOverhead     = 64 // hash 表实体结构自身的内存开销
+	entryOverhead    = 48 // 每个键值对在 map 中的开销
+	listpackOverhead = 16 // 紧凑列表每个键值对的额外开销
+)
+
+// Options 紧凑编码阈值，超出后转换为哈希表编码
+type Options struct {
+	MaxListpackEntries int // 紧凑列表编码的最大字段数
+	MaxListpackValue   int // 紧凑列表编码的最大字段及值长度
+}
+
+// DefaultOptions redis 默认的紧凑编码阈值
+func DefaultOptions() Options {
+	return Options{
+		MaxListpackEntries: 128,
+		MaxListpackValue:   64,
+	}
 }
 
 // hashMapEntity hash表实体结构
+// 依编码使用紧凑列表或哈希表之一存储键值对
 type hashMapEntity struct {
-	key  string
-	data map[string][]byte
+	key      string
+	encoding string
+	opts     *Options
+	listpack [][]byte // field value 交替排列
+	data     *mdict.Dict[[]byte]
+	memory   int64 // 键值对占用内存，随增删实时维护
+
+	// 字段过期时间索引，按过期时间排序，未设置过期字段时为 nil
+	expires msortedset.SortedSet
 }
 
-// NewHashMapEntity 初始化hash表实体
-func NewHashMapEntity(key string) HashMap {
+// NewHashMapEntity 初始化hash表实体，初始为紧凑列表编码，opts 由调用方持有，不得为 nil
+func NewHashMapEntity(key string, opts *Options) HashMap {
 	return &hashMapEntity{
-		key:  key,
-		data: make(map[string][]byte),
+		key:      key,
+		encoding: EncodingListpack,
+		opts:     opts,
 	}
 }
 
-// Put 添加一个值
-func (h *hashMapEntity) Put(key string, value []byte) {
-	h.data[key] = value
+// Put 添加一个值，返回新增的字段数，覆盖写会同时清除字段的过期时间
+func (h *hashMapEntity) Put(key string, value []byte) int64 {
+	h.Persist(key)
+
+	if h.encoding == EncodingListpack {
+		if len(key) > h.opts.MaxListpackValue || len(value) > h.opts.MaxListpackValue {
+			h.convertToHashtable()
+		} else if i := h.searchListpack(key); i >= 0 {
+			h.memory += int64(len(value) - len(h.listpack[i+1]))
+			h.listpack[i+1] = value
+			return 0
+		} else if len(h.listpack)/2 < h.opts.MaxListpackEntries {
+			h.listpack = append(h.listpack, []byte(key), value)
+			h.memory += listpackOverhead + int64(len(key)+len(value))
+			return 1
+		} else {
+			h.convertToHashtable()
+		}
+	}
+
+	old, ok := h.data.Get(key)
+	h.data.Put(key, value)
+	if ok {
+		h.memory += int64(len(value) - len(old))
+		return 0
+	}
+	h.memory += entryOverhead + int64(len(key)+len(value))
+	return 1
 }
 
 // Get 获取一个值
-func (h *hashMapEntity) Get(key string) []byte {
-	return h.data[key]
+func (h *hashMapEntity) Get(key string) ([]byte, bool) {
+	if h.encoding == EncodingListpack {
+		if i := h.searchListpack(key); i >= 0 {
+			return h.listpack[i+1], true
+		}
+		return nil, false
+	}
+	return h.data.Get(key)
 }
 
 // Del 删除一个值
 func (h *hashMapEntity) Del(key string) int64 {
-	if _, ok := h.data[key]; !ok {
-		return 0
+	var value []byte
+	if h.encoding == EncodingListpack {
+		i := h.searchListpack(key)
+		if i < 0 {
+			return 0
+		}
+		value = h.listpack[i+1]
+		h.listpack = append(h.listpack[:i], h.listpack[i+2:]...)
+		h.memory -= listpackOverhead + int64(len(key)+len(value))
+	} else {
+		var ok bool
+		if value, ok = h.data.Delete(key); !ok {
+			return 0
+		}
+		h.memory -= entryOverhead + int64(len(key)+len(value))
 	}
-	delete(h.data, key)
+
+	h.Persist(key)
 	return 1
 }
 
+// searchListpack 顺序查找字段在紧凑列表中的下标，不存在时返回 -1
+func (h *hashMapEntity) searchListpack(key string) int {
+	for i := 0; i < len(h.listpack); i += 2 {
+		if string(h.listpack[i]) == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// convertToHashtable 紧凑列表转换为哈希表
+func (h *hashMapEntity) convertToHashtable() {
+	data := mdict.New[[]byte]()
+	h.memory = 0
+	for i := 0; i < len(h.listpack); i += 2 {
+		data.Put(string(h.listpack[i]), h.listpack[i+1])
+		h.memory += entryOverhead + int64(len(h.listpack[i])+len(h.listpack[i+1]))
+	}
+	h.listpack = nil
+	h.data = data
+	h.encoding = EncodingHashtable
+}
+
+// Len 字段数
+func (h *hashMapEntity) Len() int64 {
+	if h.encoding == EncodingListpack {
+		return int64(len(h.listpack) / 2)
+	}
+	return int64(h.data.Len())
+}
+
+// ForEach 遍历键值对，fn 返回 false 时停止
+func (h *hashMapEntity) ForEach(fn func(field string, value []byte) bool) {
+	if h.encoding == EncodingListpack {
+		for i := 0; i < len(h.listpack); i += 2 {
+			if !fn(string(h.listpack[i]), h.listpack[i+1]) {
+				return
+			}
+		}
+		return
+	}
+	h.data.ForEach(fn)
+}
+
+// RandomField 随机返回一个键值对，hash 表为空时返回空字段
+func (h *hashMapEntity) RandomField() (string, []byte) {
+	if h.encoding == EncodingListpack {
+		if len(h.listpack) == 0 {
+			return "", nil
+		}
+		i := rand.Intn(len(h.listpack)/2) * 2
+		return string(h.listpack[i]), h.listpack[i+1]
+	}
+
+	field, ok := h.data.RandomKey()
+	if !ok {
+		return "", nil
+	}
+	value, _ := h.data.Get(field)
+	return field, value
+}
+
+// Scan 按游标遍历键值对，返回下一个游标，返回 0 表示遍历结束
+// 紧凑编码与 redis 一致，一次返回全部键值对
+func (h *hashMapEntity) Scan(cursor uint64, fn func(field string, value []byte)) uint64 {
+	if h.encoding == EncodingListpack {
+		h.ForEach(func(field string, value []byte) bool {
+			fn(field, value)
+			return true
+		})
+		return 0
+	}
+	return h.data.Scan(cursor, fn)
+}
+
+// Encoding 当前编码
+func (h *hashMapEntity) Encoding() string {
+	return h.encoding
+}
+
+// Expire 设置字段的过期时间，字段不存在时返回 false
+func (h *hashMapEntity) Expire(field string, expireAt int64) bool {
+	if _, ok := h.Get(field); !ok {
+		return false
+	}
+	if h.expires == nil {
+		h.expires = msortedset.NewSkiplist(h.key)
+	}
+	h.expires.Add(float64(expireAt), field)
+	return true
+}
+
+// ExpireAt 获取字段的过期时间
+func (h *hashMapEntity) ExpireAt(field string) (int64, bool) {
+	if h.expires == nil {
+		return 0, false
+	}
+	expireAt, ok := h.expires.Score(field)
+	return int64(expireAt), ok
+}
+
+// Persist 移除字段的过期时间，返回字段此前是否设置了过期时间
+func (h *hashMapEntity) Persist(field string) bool {
+	if h.expires == nil || h.expires.Rem(field) == 0 {
+		return false
+	}
+	if h.expires.Len() == 0 {
+		h.expires = nil
+	}
+	return true
+}
+
+// NextExpire 获取最早的字段过期时间
+func (h *hashMapEntity) NextExpire() (int64, bool) {
+	if h.expires == nil {
+		return 0, false
+	}
+	_, expireAt, ok := h.expires.Min()
+	return int64(expireAt), ok
+}
+
+// ExpireFields 删除 now 时刻已过期的字段，返回删除的字段数
+func (h *hashMapEntity) ExpireFields(now int64) int64 {
+	if h.expires == nil {
+		return 0
+	}
+
+	// 精确到毫秒，deadline 当刻即视为过期
+	var expired int64
+	for _, field := range h.expires.Range(math.Inf(-1), float64(now)) {
+		expired += h.Del(field)
+	}
+	return expired
+}
+
+// MemoryUsage 估算内存占用
+func (h *hashMapEntity) MemoryUsage() int64 {
+	if h.expires != nil {
+		return hashOverhead + h.memory + h.expires.MemoryUsage()
+	}
+	return hashOverhead + h.memory
+}
+
 // ToCmd Redis 命令解析
 func (h *hashMapEntity) ToCmd() [][]byte {
-	args := make([][]byte, 0, 2+2*len(h.data))
+	args := make([][]byte, 0, 2+2*h.Len())
 	args = append(args, []byte(def.CmdTypeHSet), []byte(h.key))
-	for k, v := range h.data {
-		args = append(args, []byte(k), v)
-	}
+	h.ForEach(func(field string, value []byte) bool {
+		args = append(args, []byte(field), value)
+		return true
+	})
 	return args
 }
+
+// ToCmds 重写 aof 使用，hset 还原未过期的字段，随后逐个以 hpexpireat 还原字段过期时间
+func (h *hashMapEntity) ToCmds() [][][]byte {
+	if h.expires == nil {
+		return [][][]byte{h.ToCmd()}
+	}
+
+	now := lib.TimeNow().UnixMilli()
+	hset := [][]byte{[]byte(def.CmdTypeHSet), []byte(h.key)}
+	var expireCmds [][][]byte
+	h.ForEach(func(field string, value []byte) bool {
+		expireAt, ok := h.ExpireAt(field)
+		if ok && expireAt <= now {
+			return true
+		}
+
+		hset = append(hset, []byte(field), value)
+		if ok {
+			expireCmds = append(expireCmds, [][]byte{
+				[]byte(def.CmdTypeHPExpireAt), []byte(h.key), []byte(strconv.FormatInt(expireAt, 10)),
+				[]byte("FIELDS"), []byte("1"), []byte(field),
+			})
+		}
+		return true
+	})
+
+	// 字段均已过期
+	if len(hset) == 2 {
+		return nil
+	}
+	return append([][][]byte{hset}, expireCmds...)
+}
+
+// SetKey 更新实体对应的 key
+func (h *hashMapEntity) SetKey(key string) {
+	h.key = key
+}
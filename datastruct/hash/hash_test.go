@@ -1 +1,89 @@
 package mhash
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// TestHashAgainstMap 随机读写字段，与 map 的结果逐一比对，并校验编码转换的时机
+func TestHashAgainstMap(t *testing.T) {
+	opts := &Options{MaxListpackEntries: 8, MaxListpackValue: 6}
+
+	for round := 0; round < 200; round++ {
+		var (
+			rander    = rand.New(rand.NewSource(int64(round)))
+			got       = NewHashMapEntity("h", opts)
+			want      = make(map[string]string)
+			hashtable bool
+		)
+		field := func() string {
+			return "f" + strconv.Itoa(rander.Intn(16))
+		}
+		value := func() string {
+			return strings.Repeat("v", rander.Intn(8)+1)
+		}
+
+		for i := 0; i < 48; i++ {
+			f := field()
+			_, exist := want[f]
+			switch op := rander.Intn(4); op {
+			case 0, 1:
+				v := value()
+				var added int64
+				if !exist {
+					added = 1
+				}
+				if g := got.Put(f, []byte(v)); g != added {
+					t.Fatalf("round %d op %d: put %s => %d, want %d", round, i, f, g, added)
+				}
+				want[f] = v
+
+				// 超长值或字段数超出阈值后转换为哈希表，不再转换回紧凑列表
+				hashtable = hashtable || len(v) > 6 || len(want) > 8
+			case 2:
+				var removed int64
+				if exist {
+					removed = 1
+				}
+				if g := got.Del(f); g != removed {
+					t.Fatalf("round %d op %d: del %s => %d, want %d", round, i, f, g, removed)
+				}
+				delete(want, f)
+			case 3:
+				g, ok := got.Get(f)
+				if ok != exist || string(g) != want[f] {
+					t.Fatalf("round %d op %d: get %s => %q %v, want %q %v", round, i, f, g, ok, want[f], exist)
+				}
+			}
+
+			if got.Len() != int64(len(want)) {
+				t.Fatalf("round %d op %d: len %d, want %d", round, i, got.Len(), len(want))
+			}
+			encoding := EncodingListpack
+			if hashtable {
+				encoding = EncodingHashtable
+			}
+			if got.Encoding() != encoding {
+				t.Fatalf("round %d op %d: encoding %s, want %s", round, i, got.Encoding(), encoding)
+			}
+		}
+
+		var pairs, wantPairs []string
+		got.ForEach(func(field string, value []byte) bool {
+			pairs = append(pairs, field+"="+string(value))
+			return true
+		})
+		for f, v := range want {
+			wantPairs = append(wantPairs, f+"="+v)
+		}
+		sort.Strings(pairs)
+		sort.Strings(wantPairs)
+		if fmt.Sprint(pairs) != fmt.Sprint(wantPairs) {
+			t.Fatalf("round %d: pairs %v, want %v", round, pairs, wantPairs)
+		}
+	}
+}
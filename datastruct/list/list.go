@@ -1,6 +1,10 @@
 package mlist
 
-import def "github.com/lovelydayss/goredis/interface"
+import (
+	"bytes"
+
+	def "github.com/lovelydayss/goredis/interface"
+)
 
 // List 链表类型操作接口
 type List interface {
@@ -10,75 +14,486 @@ type List interface {
 	RPop(cnt int64) [][]byte
 	Len() int64
 	Range(start, stop int64) [][]byte
+	Index(index int64) ([]byte, bool)
+	Set(index int64, value []byte) bool
+	Insert(pivot, value []byte, before bool) bool
+	Rem(count int64, value []byte) int64
+	Trim(start, stop int64)
+	Pos(value []byte, rank, count, maxLen int64) []int64
 	def.CmdAdapter
+	def.MemoryAdapter
+}
+
+const (
+	listOverhead    = 64  // 链表实体结构自身的内存开销
+	chunkOverhead   = 48  // 每个分块结构自身的内存开销
+	elementOverhead = 24  // 每个元素的切片头开销
+	chunkSize       = 128 // 每个分块的元素数量上限
+)
+
+// chunk 分块，按顺序保存至多 chunkSize 个连续元素
+type chunk struct {
+	elements   [][]byte
+	prev, next *chunk
+}
+
+// newChunk 初始化分块
+func newChunk() *chunk {
+	return &chunk{elements: make([][]byte, 0, chunkSize)}
 }
 
-// listEntity 链表元素结构体
-// 这里就用数组替代实现了
+// removeAt 删除分块内下标为 i 的元素
+func (c *chunk) removeAt(i int) {
+	copy(c.elements[i:], c.elements[i+1:])
+	c.elements[len(c.elements)-1] = nil
+	c.elements = c.elements[:len(c.elements)-1]
+}
+
+// insertAt 在分块内下标 i 处插入元素
+func (c *chunk) insertAt(i int, value []byte) {
+	c.elements = append(c.elements, nil)
+	copy(c.elements[i+1:], c.elements[i:])
+	c.elements[i] = value
+}
+
+// listEntity 链表实体，参考 redis quicklist 实现为由有界分块组成的双向链表
+// 两端的推入弹出均摊 O(1)，按下标访问只需逐块跳过，复杂度为 O(n/chunkSize)
 type listEntity struct {
-	key  string
-	data [][]byte
+	key        string
+	head, tail *chunk
+	size       int64
+	memory     int64 // 元素及分块占用内存，随增删实时维护
 }
 
 // NewListEntity 初始化链表元素结构体
 func NewListEntity(key string, elements ...[]byte) List {
-	return &listEntity{
-		key:  key,
-		data: elements,
+	l := listEntity{key: key}
+	for _, element := range elements {
+		l.RPush(element)
+	}
+	return &l
+}
+
+// elementMemory 单个元素的内存占用
+func elementMemory(element []byte) int64 {
+	return elementOverhead + int64(len(element))
+}
+
+// linkHead 在头部挂载分块
+func (l *listEntity) linkHead(c *chunk) {
+	c.next = l.head
+	if l.head != nil {
+		l.head.prev = c
+	} else {
+		l.tail = c
+	}
+	l.head = c
+	l.memory += chunkOverhead
+}
+
+// linkTail 在尾部挂载分块
+func (l *listEntity) linkTail(c *chunk) {
+	c.prev = l.tail
+	if l.tail != nil {
+		l.tail.next = c
+	} else {
+		l.head = c
+	}
+	l.tail = c
+	l.memory += chunkOverhead
+}
+
+// linkAfter 在分块 at 之后挂载分块
+func (l *listEntity) linkAfter(at, c *chunk) {
+	if at == l.tail {
+		l.linkTail(c)
+		return
+	}
+	c.prev, c.next = at, at.next
+	at.next.prev = c
+	at.next = c
+	l.memory += chunkOverhead
+}
+
+// unlink 摘除分块
+func (l *listEntity) unlink(c *chunk) {
+	if c.prev != nil {
+		c.prev.next = c.next
+	} else {
+		l.head = c.next
+	}
+	if c.next != nil {
+		c.next.prev = c.prev
+	} else {
+		l.tail = c.prev
+	}
+	c.prev, c.next = nil, nil
+	l.memory -= chunkOverhead
+}
+
+// mergeNext 分块 c 与其后继的元素总数不超过 chunkSize 时将后继并入 c，返回是否合并
+func (l *listEntity) mergeNext(c *chunk) bool {
+	next := c.next
+	if next == nil || len(c.elements)+len(next.elements) > chunkSize {
+		return false
+	}
+	c.elements = append(c.elements, next.elements...)
+	l.unlink(next)
+	next.elements = nil
+	return true
+}
+
+// mergeAround 尝试将分块 c 与前后相邻的分块合并，避免删除或拆分后残留大量未填满的分块
+func (l *listEntity) mergeAround(c *chunk) {
+	// 分块已被摘除或并入其他分块
+	if len(c.elements) == 0 {
+		return
+	}
+	if prev := c.prev; prev != nil && l.mergeNext(prev) {
+		c = prev
+	}
+	l.mergeNext(c)
+}
+
+// remove 删除分块 c 内下标为 i 的元素，分块为空时摘除
+func (l *listEntity) remove(c *chunk, i int) {
+	l.memory -= elementMemory(c.elements[i])
+	l.size--
+	c.removeAt(i)
+	if len(c.elements) == 0 {
+		l.unlink(c)
+	}
+}
+
+// locate 定位下标对应的分块及块内偏移，要求 0 <= index < size
+// 从距离较近的一端开始逐块跳过
+func (l *listEntity) locate(index int64) (*chunk, int) {
+	if index < l.size/2 {
+		c := l.head
+		for index >= int64(len(c.elements)) {
+			index -= int64(len(c.elements))
+			c = c.next
+		}
+		return c, int(index)
+	}
+
+	c, rest := l.tail, l.size-1-index
+	for rest >= int64(len(c.elements)) {
+		rest -= int64(len(c.elements))
+		c = c.prev
+	}
+	return c, len(c.elements) - 1 - int(rest)
+}
+
+// iterate 从下标 index 处开始遍历，reverse 为 true 时向头部方向遍历，fn 返回 false 时终止
+func (l *listEntity) iterate(index int64, reverse bool, fn func(i int64, element []byte) bool) {
+	if index < 0 || index >= l.size {
+		return
+	}
+
+	c, off := l.locate(index)
+	for c != nil {
+		if !fn(index, c.elements[off]) {
+			return
+		}
+
+		if reverse {
+			index, off = index-1, off-1
+			if off < 0 {
+				if c = c.prev; c != nil {
+					off = len(c.elements) - 1
+				}
+			}
+			continue
+		}
+
+		index, off = index+1, off+1
+		if off >= len(c.elements) {
+			c, off = c.next, 0
+		}
 	}
 }
 
 func (l *listEntity) LPush(value []byte) {
-	l.data = append([][]byte{value}, l.data...)
+	if l.head == nil || len(l.head.elements) >= chunkSize {
+		l.linkHead(newChunk())
+	}
+	l.head.insertAt(0, value)
+	l.size++
+	l.memory += elementMemory(value)
 }
 
+// LPop 从头部弹出至多 cnt 个元素，结果按弹出顺序排列
 func (l *listEntity) LPop(cnt int64) [][]byte {
-	if int64(len(l.data)) < cnt {
-		return nil
-	}
+	cnt = min(cnt, l.size)
 
-	poped := l.data[:cnt]
-	l.data = l.data[cnt:]
+	poped := make([][]byte, 0, cnt)
+	for int64(len(poped)) < cnt {
+		h := l.head
+		n := min(int(cnt)-len(poped), len(h.elements))
+		poped = append(poped, h.elements[:n]...)
+		for _, element := range h.elements[:n] {
+			l.memory -= elementMemory(element)
+		}
+
+		// 清空已弹出的槽位，避免底层数组继续引用已弹出的元素
+		clear(h.elements[:n])
+		h.elements = h.elements[n:]
+		if len(h.elements) == 0 {
+			l.unlink(h)
+		}
+	}
+	l.size -= cnt
 	return poped
 }
 
 func (l *listEntity) RPush(value []byte) {
-	l.data = append(l.data, value)
+	if l.tail == nil || len(l.tail.elements) >= chunkSize {
+		l.linkTail(newChunk())
+	}
+	l.tail.elements = append(l.tail.elements, value)
+	l.size++
+	l.memory += elementMemory(value)
 }
 
+// RPop 从尾部弹出至多 cnt 个元素，结果按弹出顺序排列，即尾部元素在前
 func (l *listEntity) RPop(cnt int64) [][]byte {
-	if int64(len(l.data)) < cnt {
-		return nil
-	}
+	cnt = min(cnt, l.size)
+
+	poped := make([][]byte, 0, cnt)
+	for int64(len(poped)) < cnt {
+		t := l.tail
+		n := min(int(cnt)-len(poped), len(t.elements))
+		from := len(t.elements) - n
+		for i := len(t.elements) - 1; i >= from; i-- {
+			poped = append(poped, t.elements[i])
+			l.memory -= elementMemory(t.elements[i])
+		}
 
-	poped := l.data[int64(len(l.data))-cnt:]
-	l.data = l.data[:int64(len(l.data))-cnt]
+		clear(t.elements[from:])
+		t.elements = t.elements[:from]
+		if from == 0 {
+			l.unlink(t)
+		}
+	}
+	l.size -= cnt
 	return poped
 }
 
 func (l *listEntity) Len() int64 {
-	return int64(len(l.data))
+	return l.size
 }
 
+// Range 获取闭区间 [start, stop] 内的元素，下标为负数时从尾部开始计数，越界部分被截断
 func (l *listEntity) Range(start, stop int64) [][]byte {
-	if stop == -1 {
-		stop = int64(len(l.data) - 1)
+	start, stop = l.clamp(start, stop)
+	if start > stop {
+		return [][]byte{}
+	}
+
+	res := make([][]byte, 0, stop-start+1)
+	l.iterate(start, false, func(i int64, element []byte) bool {
+		res = append(res, element)
+		return i < stop
+	})
+	return res
+}
+
+// normalize 将支持负数的下标转换为从头部开始的下标
+func (l *listEntity) normalize(index int64) int64 {
+	if index < 0 {
+		index += l.size
+	}
+	return index
+}
+
+// Index 获取下标对应的元素，下标为负数时从尾部开始计数
+func (l *listEntity) Index(index int64) ([]byte, bool) {
+	index = l.normalize(index)
+	if index < 0 || index >= l.size {
+		return nil, false
+	}
+	c, off := l.locate(index)
+	return c.elements[off], true
+}
+
+// Set 修改下标对应的元素，下标越界时返回 false
+func (l *listEntity) Set(index int64, value []byte) bool {
+	index = l.normalize(index)
+	if index < 0 || index >= l.size {
+		return false
+	}
+	c, off := l.locate(index)
+	l.memory += int64(len(value) - len(c.elements[off]))
+	c.elements[off] = value
+	return true
+}
+
+// Insert 在首个等于 pivot 的元素之前或之后插入，未找到 pivot 时返回 false
+// 插入后分块超出上限时对半拆分，拆分出的分块与相邻分块尝试合并
+func (l *listEntity) Insert(pivot, value []byte, before bool) bool {
+	for c := l.head; c != nil; c = c.next {
+		for i, element := range c.elements {
+			if !bytes.Equal(element, pivot) {
+				continue
+			}
+			if !before {
+				i++
+			}
+			c.insertAt(i, value)
+			l.size++
+			l.memory += elementMemory(value)
+
+			if len(c.elements) > chunkSize {
+				half := len(c.elements) / 2
+				split := newChunk()
+				split.elements = append(split.elements, c.elements[half:]...)
+				clear(c.elements[half:])
+				c.elements = c.elements[:half]
+				l.linkAfter(c, split)
+				l.mergeAround(c)
+				l.mergeAround(split)
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// Rem 删除等于 value 的元素，count > 0 时从头部开始删除至多 count 个，
+// count < 0 时从尾部开始删除至多 -count 个，count = 0 时全部删除，返回删除数量
+// 删除完成后发生过删除的分块与相邻分块尝试合并
+func (l *listEntity) Rem(count int64, value []byte) int64 {
+	var (
+		removed int64
+		limit   = count
+		touched []*chunk
+	)
+	defer func() {
+		for _, c := range touched {
+			l.mergeAround(c)
+		}
+	}()
+
+	if limit < 0 {
+		limit = -limit
+	}
+	reachLimit := func() bool {
+		return limit > 0 && removed >= limit
 	}
 
-	if start < 0 || start >= int64(len(l.data)) {
-		return nil
+	if count >= 0 {
+		for c := l.head; c != nil && !reachLimit(); {
+			next, before := c.next, removed
+			for i := 0; i < len(c.elements) && !reachLimit(); {
+				if !bytes.Equal(c.elements[i], value) {
+					i++
+					continue
+				}
+				l.remove(c, i)
+				removed++
+			}
+			if removed > before {
+				touched = append(touched, c)
+			}
+			c = next
+		}
+		return removed
 	}
 
-	if stop < 0 || stop >= int64(len(l.data)) || stop < start {
-		return nil
+	for c := l.tail; c != nil && !reachLimit(); {
+		prev, before := c.prev, removed
+		for i := len(c.elements) - 1; i >= 0 && !reachLimit(); i-- {
+			if bytes.Equal(c.elements[i], value) {
+				l.remove(c, i)
+				removed++
+			}
+		}
+		if removed > before {
+			touched = append(touched, c)
+		}
+		c = prev
 	}
+	return removed
+}
 
-	return l.data[start : stop+1]
+// clamp 将闭区间 [start, stop] 转换为从头部开始的下标并截断越界部分，区间为空时 start > stop
+func (l *listEntity) clamp(start, stop int64) (int64, int64) {
+	start, stop = l.normalize(start), l.normalize(stop)
+	if start < 0 {
+		start = 0
+	}
+	if stop >= l.size {
+		stop = l.size - 1
+	}
+	return start, stop
+}
+
+// Trim 仅保留闭区间 [start, stop] 内的元素，下标为负数时从尾部开始计数
+func (l *listEntity) Trim(start, stop int64) {
+	start, stop = l.clamp(start, stop)
+	if start > stop {
+		l.head, l.tail = nil, nil
+		l.size, l.memory = 0, 0
+		return
+	}
+
+	l.RPop(l.size - 1 - stop)
+	l.LPop(start)
+
+	// 两端弹出后首尾分块可能未填满
+	l.mergeAround(l.head)
+	l.mergeAround(l.tail)
+}
+
+// Pos 查找等于 value 的元素下标
+// rank 表示从第几个匹配开始返回，为负数时从尾部开始查找；count 为 0 表示返回所有匹配；
+// maxLen 为 0 表示不限制比较的元素数量
+func (l *listEntity) Pos(value []byte, rank, count, maxLen int64) []int64 {
+	var (
+		res      []int64
+		reverse  = rank < 0
+		start    int64
+		compared int64
+	)
+	if reverse {
+		rank, start = -rank, l.size-1
+	}
+
+	l.iterate(start, reverse, func(i int64, element []byte) bool {
+		if maxLen > 0 && compared >= maxLen {
+			return false
+		}
+		compared++
+
+		if !bytes.Equal(element, value) {
+			return true
+		}
+		if rank > 1 {
+			rank--
+			return true
+		}
+		res = append(res, i)
+		return count == 0 || int64(len(res)) < count
+	})
+	return res
+}
+
+// MemoryUsage 估算内存占用
+func (l *listEntity) MemoryUsage() int64 {
+	return listOverhead + l.memory
 }
 
 func (l *listEntity) ToCmd() [][]byte {
 	args := make([][]byte, 0, 2+l.Len())
 	args = append(args, []byte(def.CmdTypeRPush), []byte(l.key))
-	args = append(args, l.data...)
+	for c := l.head; c != nil; c = c.next {
+		args = append(args, c.elements...)
+	}
 	return args
 }
+
+// SetKey 更新实体对应的 key
+func (l *listEntity) SetKey(key string) {
+	l.key = key
+}
@@ -1 +1,440 @@
 package mlist
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"testing"
+
+	def "github.com/lovelydayss/goredis/interface"
+)
+
+// sliceList 替换前基于切片的链表实现，用作对照
+type sliceList struct {
+	key    string
+	data   [][]byte
+	memory int64
+}
+
+// newSliceList 初始化
+func newSliceList(key string, elements ...[]byte) List {
+	l := sliceList{
+		key:  key,
+		data: elements,
+	}
+	for _, element := range elements {
+		l.memory += elementMemory(element)
+	}
+	return &l
+}
+
+func (l *sliceList) LPush(value []byte) {
+	l.data = append([][]byte{value}, l.data...)
+	l.memory += elementMemory(value)
+}
+
+func (l *sliceList) LPop(cnt int64) [][]byte {
+	cnt = min(cnt, int64(len(l.data)))
+
+	poped := l.data[:cnt]
+	l.data = l.data[cnt:]
+	for _, element := range poped {
+		l.memory -= elementMemory(element)
+	}
+	return poped
+}
+
+func (l *sliceList) RPush(value []byte) {
+	l.data = append(l.data, value)
+	l.memory += elementMemory(value)
+}
+
+func (l *sliceList) RPop(cnt int64) [][]byte {
+	cnt = min(cnt, int64(len(l.data)))
+
+	poped := make([][]byte, 0, cnt)
+	for i := int64(len(l.data)) - 1; i >= int64(len(l.data))-cnt; i-- {
+		poped = append(poped, l.data[i])
+		l.memory -= elementMemory(l.data[i])
+	}
+	l.data = l.data[:int64(len(l.data))-cnt]
+	return poped
+}
+
+func (l *sliceList) Len() int64 {
+	return int64(len(l.data))
+}
+
+func (l *sliceList) Range(start, stop int64) [][]byte {
+	start, stop = l.normalize(start), l.normalize(stop)
+	start, stop = max(start, 0), min(stop, int64(len(l.data))-1)
+	if start > stop {
+		return [][]byte{}
+	}
+
+	return l.data[start : stop+1]
+}
+
+// normalize 将支持负数的下标转换为从头部开始的下标
+func (l *sliceList) normalize(index int64) int64 {
+	if index < 0 {
+		index += int64(len(l.data))
+	}
+	return index
+}
+
+// Index 获取下标对应的元素，下标为负数时从尾部开始计数
+func (l *sliceList) Index(index int64) ([]byte, bool) {
+	index = l.normalize(index)
+	if index < 0 || index >= int64(len(l.data)) {
+		return nil, false
+	}
+	return l.data[index], true
+}
+
+// Set 修改下标对应的元素，下标越界时返回 false
+func (l *sliceList) Set(index int64, value []byte) bool {
+	index = l.normalize(index)
+	if index < 0 || index >= int64(len(l.data)) {
+		return false
+	}
+	l.memory += int64(len(value) - len(l.data[index]))
+	l.data[index] = value
+	return true
+}
+
+// Insert 在首个等于 pivot 的元素之前或之后插入，未找到 pivot 时返回 false
+func (l *sliceList) Insert(pivot, value []byte, before bool) bool {
+	for i, element := range l.data {
+		if !bytes.Equal(element, pivot) {
+			continue
+		}
+		if !before {
+			i++
+		}
+		l.data = append(l.data, nil)
+		copy(l.data[i+1:], l.data[i:])
+		l.data[i] = value
+		l.memory += elementMemory(value)
+		return true
+	}
+	return false
+}
+
+// Rem 删除等于 value 的元素，count > 0 时从头部开始删除至多 count 个，
+// count < 0 时从尾部开始删除至多 -count 个，count = 0 时全部删除，返回删除数量
+func (l *sliceList) Rem(count int64, value []byte) int64 {
+	var (
+		removed int64
+		limit   = count
+	)
+	if limit < 0 {
+		limit = -limit
+	}
+
+	keep := func(element []byte) bool {
+		if (limit == 0 || removed < limit) && bytes.Equal(element, value) {
+			removed++
+			l.memory -= elementMemory(element)
+			return false
+		}
+		return true
+	}
+
+	res := make([][]byte, 0, len(l.data))
+	if count >= 0 {
+		for _, element := range l.data {
+			if keep(element) {
+				res = append(res, element)
+			}
+		}
+	} else {
+		for i := len(l.data) - 1; i >= 0; i-- {
+			if keep(l.data[i]) {
+				res = append(res, l.data[i])
+			}
+		}
+		for i, j := 0, len(res)-1; i < j; i, j = i+1, j-1 {
+			res[i], res[j] = res[j], res[i]
+		}
+	}
+	l.data = res
+	return removed
+}
+
+// Trim 仅保留闭区间 [start, stop] 内的元素，下标为负数时从尾部开始计数
+func (l *sliceList) Trim(start, stop int64) {
+	size := int64(len(l.data))
+	start, stop = l.normalize(start), l.normalize(stop)
+	if start < 0 {
+		start = 0
+	}
+	if stop >= size {
+		stop = size - 1
+	}
+
+	var kept [][]byte
+	if start <= stop {
+		kept = l.data[start : stop+1]
+	}
+	for i, element := range l.data {
+		if int64(i) < start || int64(i) > stop {
+			l.memory -= elementMemory(element)
+		}
+	}
+	l.data = append([][]byte{}, kept...)
+}
+
+// Pos 查找等于 value 的元素下标
+// rank 表示从第几个匹配开始返回，为负数时从尾部开始查找；count 为 0 表示返回所有匹配；
+// maxLen 为 0 表示不限制比较的元素数量
+func (l *sliceList) Pos(value []byte, rank, count, maxLen int64) []int64 {
+	var (
+		res  []int64
+		size = int64(len(l.data))
+		step = int64(1)
+		i    = int64(0)
+	)
+	if rank < 0 {
+		rank, step, i = -rank, -1, size-1
+	}
+
+	for compared := int64(0); i >= 0 && i < size && (maxLen == 0 || compared < maxLen); i, compared = i+step, compared+1 {
+		if !bytes.Equal(l.data[i], value) {
+			continue
+		}
+		if rank > 1 {
+			rank--
+			continue
+		}
+		res = append(res, i)
+		if count > 0 && int64(len(res)) >= count {
+			break
+		}
+	}
+	return res
+}
+
+func (l *sliceList) MemoryUsage() int64 {
+	return listOverhead + l.memory
+}
+
+func (l *sliceList) ToCmd() [][]byte {
+	return append([][]byte{[]byte(def.CmdTypeRPush), []byte(l.key)}, l.data...)
+}
+
+func (l *sliceList) SetKey(key string) {
+	l.key = key
+}
+
+// TestListAgainstSlice 随机执行各类操作，与切片实现的结果逐一比对
+func TestListAgainstSlice(t *testing.T) {
+	var (
+		rander = rand.New(rand.NewSource(1))
+		got    = NewListEntity("l")
+		want   = newSliceList("l")
+	)
+	value := func() []byte {
+		return []byte(strconv.Itoa(rander.Intn(8)))
+	}
+	index := func() int64 {
+		return int64(rander.Intn(2*int(want.Len())+1)) - want.Len()
+	}
+
+	for i := 0; i < 50000; i++ {
+		switch op := rander.Intn(10); op {
+		case 0, 1:
+			v := value()
+			got.LPush(v)
+			want.LPush(v)
+		case 2, 3:
+			v := value()
+			got.RPush(v)
+			want.RPush(v)
+		case 4:
+			cnt := int64(rander.Intn(4))
+			assertEqual(t, i, got.LPop(cnt), want.LPop(cnt))
+		case 5:
+			cnt := int64(rander.Intn(4))
+			assertEqual(t, i, got.RPop(cnt), want.RPop(cnt))
+		case 6:
+			pivot, v, before := value(), value(), rander.Intn(2) == 0
+			if got.Insert(pivot, v, before) != want.Insert(pivot, v, before) {
+				t.Fatalf("op %d: insert mismatch", i)
+			}
+		case 7:
+			cnt, v := int64(rander.Intn(5)-2), value()
+			if got.Rem(cnt, v) != want.Rem(cnt, v) {
+				t.Fatalf("op %d: rem mismatch", i)
+			}
+		case 8:
+			idx, v := index(), value()
+			if got.Set(idx, v) != want.Set(idx, v) {
+				t.Fatalf("op %d: set mismatch", i)
+			}
+			g, _ := got.Index(idx)
+			w, _ := want.Index(idx)
+			assertEqual(t, i, [][]byte{g}, [][]byte{w})
+		case 9:
+			start, stop := index(), index()
+			assertEqual(t, i, got.Range(start, stop), want.Range(start, stop))
+			if rander.Intn(50) == 0 {
+				start, stop := index(), index()
+				got.Trim(start, stop)
+				want.Trim(start, stop)
+			}
+			v, rank, cnt := value(), int64(rander.Intn(5)-2), int64(rander.Intn(3))
+			if rank == 0 {
+				rank = 1
+			}
+			if fmt.Sprint(got.Pos(v, rank, cnt, 0)) != fmt.Sprint(want.Pos(v, rank, cnt, 0)) {
+				t.Fatalf("op %d: pos mismatch", i)
+			}
+		}
+
+		if got.Len() != want.Len() {
+			t.Fatalf("op %d: len %d, want %d", i, got.Len(), want.Len())
+		}
+	}
+	assertEqual(t, -1, got.ToCmd(), want.ToCmd())
+	assertEqual(t, -1, got.Range(0, -1), want.Range(0, -1))
+}
+
+// TestListMergeChunks 删除、裁剪及拆分后相邻的未填满分块被合并
+func TestListMergeChunks(t *testing.T) {
+	odd, even := []byte("1"), []byte("0")
+	fill := func(n int) *listEntity {
+		l := NewListEntity("l").(*listEntity)
+		for i := 0; i < n; i++ {
+			if i%2 == 0 {
+				l.RPush(even)
+			} else {
+				l.RPush(odd)
+			}
+		}
+		return l
+	}
+
+	tests := []struct {
+		name   string
+		list   *listEntity
+		op     func(l *listEntity)
+		chunks int
+	}{
+		{"rem from head", fill(3 * chunkSize), func(l *listEntity) { l.Rem(0, odd) }, 2},
+		{"rem from tail", fill(3 * chunkSize), func(l *listEntity) { l.Rem(-2*chunkSize, odd) }, 2},
+		{"rem within limit", fill(3 * chunkSize), func(l *listEntity) { l.Rem(chunkSize/2, odd) }, 3},
+		{"trim both ends", fill(3 * chunkSize), func(l *listEntity) { l.Trim(2*chunkSize-10, 2*chunkSize+9) }, 1},
+		{"insert split", fill(2 * chunkSize), func(l *listEntity) {
+			l.Rem(-chunkSize/2-16, odd)
+			l.Insert(even, odd, true)
+		}, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.op(tt.list)
+
+			var chunks int
+			var size int64
+			for c := tt.list.head; c != nil; c = c.next {
+				if len(c.elements) == 0 || len(c.elements) > chunkSize {
+					t.Fatalf("chunk %d has %d elements", chunks, len(c.elements))
+				}
+				if c.next != nil && c.next.prev != c {
+					t.Fatalf("chunk %d is not linked back", chunks)
+				}
+				chunks++
+				size += int64(len(c.elements))
+			}
+			if chunks != tt.chunks {
+				t.Errorf("chunks %d, want %d", chunks, tt.chunks)
+			}
+			if size != tt.list.Len() {
+				t.Errorf("size %d, want %d", size, tt.list.Len())
+			}
+		})
+	}
+}
+
+func assertEqual(t *testing.T, op int, got, want [][]byte) {
+	t.Helper()
+	if fmt.Sprintf("%q", got) != fmt.Sprintf("%q", want) {
+		t.Fatalf("op %d: got %q, want %q", op, got, want)
+	}
+}
+
+// benchmarkLists 待比较的链表实现
+var benchmarkLists = []struct {
+	name string
+	new  func() List
+}{
+	{"quicklist", func() List { return NewListEntity("l") }},
+	{"slice", func() List { return newSliceList("l") }},
+}
+
+// BenchmarkLPush 向头部推入 1e4 个元素，切片实现每次推入都需要整体搬移
+func BenchmarkLPush(b *testing.B) {
+	for _, impl := range benchmarkLists {
+		b.Run(impl.name, func(b *testing.B) {
+			value := []byte("value")
+			for i := 0; i < b.N; i++ {
+				l := impl.new()
+				for j := 0; j < 10000; j++ {
+					l.LPush(value)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkRPushLPop 队列场景，尾部推入头部弹出
+func BenchmarkRPushLPop(b *testing.B) {
+	for _, impl := range benchmarkLists {
+		b.Run(impl.name, func(b *testing.B) {
+			l, value := impl.new(), []byte("value")
+			for j := 0; j < 10000; j++ {
+				l.RPush(value)
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				l.RPush(value)
+				l.LPop(1)
+			}
+		})
+	}
+}
+
+// BenchmarkIndex 在 1e5 个元素中按随机下标访问
+func BenchmarkIndex(b *testing.B) {
+	for _, impl := range benchmarkLists {
+		b.Run(impl.name, func(b *testing.B) {
+			l, value := impl.new(), []byte("value")
+			for j := 0; j < 100000; j++ {
+				l.RPush(value)
+			}
+			rander := rand.New(rand.NewSource(1))
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				l.Index(int64(rander.Intn(100000)))
+			}
+		})
+	}
+}
+
+// BenchmarkRange 在 1e5 个元素的中部读取 100 个元素
+func BenchmarkRange(b *testing.B) {
+	for _, impl := range benchmarkLists {
+		b.Run(impl.name, func(b *testing.B) {
+			l, value := impl.new(), []byte("value")
+			for j := 0; j < 100000; j++ {
+				l.RPush(value)
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				l.Range(50000, 50099)
+			}
+		})
+	}
+}
@@ -1,63 +1,322 @@
 package mset
 
-import def "github.com/lovelydayss/goredis/interface"
+import (
+	"math/rand"
+	"sort"
+	"strconv"
+
+	mdict "github.com/lovelydayss/goredis/datastruct/dict"
+	def "github.com/lovelydayss/goredis/interface"
+)
 
 // Set 集合数据结构接口
 type Set interface {
 	Add(value string) int64
 	Exist(value string) int64
 	Rem(value string) int64
+	Len() int64
+	ForEach(fn func(member string) bool)
+	RandomMember() string
+	Scan(cursor uint64, fn func(member string)) uint64
+	Encoding() string
 	def.CmdAdapter
+	def.MemoryAdapter
+}
+
+// 集合编码，成员较少时采用紧凑编码节省内存，超出阈值后转换为哈希表，不再转换回紧凑编码
+const (
+	EncodingIntset    = "intset"    // 有序整数数组，成员均为整数时使用
+	EncodingListpack  = "listpack"  // 成员数组，成员数及成员长度较小时使用
+	EncodingHashtable = "hashtable" // 哈希表
+)
+
+const (
+	setOverhead      = 64 // 集合实体结构自身的内存开销
+	memberOverhead   = 32 // 每个成员在 map 中的开销
+	intsetEntrySize  = 8  // 整数集合每个成员的开销
+	listpackOverhead = 16 // 紧凑列表每个成员的额外开销
+)
+
+// Options 紧凑编码阈值，超出后转换为哈希表编码
+type Options struct {
+	MaxIntsetEntries   int // 整数集合编码的最大成员数
+	MaxListpackEntries int // 紧凑列表编码的最大成员数
+	MaxListpackValue   int // 紧凑列表编码的最大成员长度
+}
+
+// DefaultOptions redis 默认的紧凑编码阈值
+func DefaultOptions() Options {
+	return Options{
+		MaxIntsetEntries:   512,
+		MaxListpackEntries: 128,
+		MaxListpackValue:   64,
+	}
 }
 
 // setEntity 集合数据结构实体
-// set 采用值类型为空 map 构建
+// 依编码使用 intset、listpack 或值类型为空结构体的哈希表之一存储成员
 type setEntity struct {
 	key       string
-	container map[string]struct{}
+	encoding  string
+	opts      *Options
+	intset    []int64
+	listpack  []string
+	container *mdict.Dict[struct{}]
+	memory    int64 // 成员占用内存，随增删实时维护
 }
 
-// NewSetEntity 新建集合数据结构实体
-func NewSetEntity(key string) Set {
+// NewSetEntity 新建集合数据结构实体，初始为整数集合编码，opts 由调用方持有，不得为 nil
+func NewSetEntity(key string, opts *Options) Set {
 	return &setEntity{
-		key:       key,
-		container: make(map[string]struct{}),
+		key:      key,
+		encoding: EncodingIntset,
+		opts:     opts,
+	}
+}
+
+// parseIntsetMember 成员可以无损表示为整数时返回对应整数
+func parseIntsetMember(value string) (int64, bool) {
+	v, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || strconv.FormatInt(v, 10) != value {
+		return 0, false
 	}
+	return v, true
 }
 
 // Add 找到插入失败， 否则插入
 func (s *setEntity) Add(value string) int64 {
-	if _, ok := s.container[value]; ok {
+	if s.encoding == EncodingIntset {
+		if v, ok := parseIntsetMember(value); ok {
+			i := sort.Search(len(s.intset), func(i int) bool { return s.intset[i] >= v })
+			if i < len(s.intset) && s.intset[i] == v {
+				return 0
+			}
+			if len(s.intset) < s.opts.MaxIntsetEntries {
+				s.intset = append(s.intset, 0)
+				copy(s.intset[i+1:], s.intset[i:])
+				s.intset[i] = v
+				s.memory += intsetEntrySize
+				return 1
+			}
+			// 与 redis 一致，整数成员超出数量阈值时直接转换为哈希表
+			s.convertToHashtable()
+		} else if len(s.intset)+1 <= s.opts.MaxListpackEntries && len(value) <= s.opts.MaxListpackValue {
+			// 非整数成员，按加入后的规模转换编码
+			s.convertToListpack()
+		} else {
+			s.convertToHashtable()
+		}
+	}
+
+	if s.encoding == EncodingListpack {
+		for _, member := range s.listpack {
+			if member == value {
+				return 0
+			}
+		}
+		if len(s.listpack) < s.opts.MaxListpackEntries && len(value) <= s.opts.MaxListpackValue {
+			s.listpack = append(s.listpack, value)
+			s.memory += listpackOverhead + int64(len(value))
+			return 1
+		}
+		s.convertToHashtable()
+	}
+
+	if !s.container.Put(value, struct{}{}) {
 		return 0
 	}
-	s.container[value] = struct{}{}
+	s.memory += memberOverhead + int64(len(value))
 	return 1
 }
 
 // Exist 查找值是否存在，存在返回 1，否则返回 0
 func (s *setEntity) Exist(value string) int64 {
-	if _, ok := s.container[value]; ok {
-		return 1
+	switch s.encoding {
+	case EncodingIntset:
+		if _, ok := s.searchIntset(value); ok {
+			return 1
+		}
+	case EncodingListpack:
+		if s.searchListpack(value) >= 0 {
+			return 1
+		}
+	default:
+		if _, ok := s.container.Get(value); ok {
+			return 1
+		}
 	}
 	return 0
 }
 
 // Rem 找到则删除，返回成功，否则返回 0
 func (s *setEntity) Rem(value string) int64 {
-	if _, ok := s.container[value]; ok {
-		delete(s.container, value)
-		return 1
+	switch s.encoding {
+	case EncodingIntset:
+		i, ok := s.searchIntset(value)
+		if !ok {
+			return 0
+		}
+		s.intset = append(s.intset[:i], s.intset[i+1:]...)
+		s.memory -= intsetEntrySize
+
+	case EncodingListpack:
+		i := s.searchListpack(value)
+		if i < 0 {
+			return 0
+		}
+		last := len(s.listpack) - 1
+		s.listpack[i] = s.listpack[last]
+		s.listpack = s.listpack[:last]
+		s.memory -= listpackOverhead + int64(len(value))
+
+	default:
+		if _, ok := s.container.Delete(value); !ok {
+			return 0
+		}
+		s.memory -= memberOverhead + int64(len(value))
 	}
-	return 0
+	return 1
+}
+
+// searchIntset 二分查找整数成员的下标
+func (s *setEntity) searchIntset(value string) (int, bool) {
+	v, ok := parseIntsetMember(value)
+	if !ok {
+		return 0, false
+	}
+	i := sort.Search(len(s.intset), func(i int) bool { return s.intset[i] >= v })
+	return i, i < len(s.intset) && s.intset[i] == v
+}
+
+// searchListpack 顺序查找成员的下标，不存在时返回 -1
+func (s *setEntity) searchListpack(value string) int {
+	for i, member := range s.listpack {
+		if member == value {
+			return i
+		}
+	}
+	return -1
+}
+
+// convertToListpack 整数集合转换为紧凑列表
+func (s *setEntity) convertToListpack() {
+	s.listpack = make([]string, 0, len(s.intset)+1)
+	s.memory = 0
+	for _, v := range s.intset {
+		member := strconv.FormatInt(v, 10)
+		s.listpack = append(s.listpack, member)
+		s.memory += listpackOverhead + int64(len(member))
+	}
+	s.intset = nil
+	s.encoding = EncodingListpack
+}
+
+// convertToHashtable 紧凑编码转换为哈希表
+func (s *setEntity) convertToHashtable() {
+	container := mdict.New[struct{}]()
+	s.memory = 0
+	s.ForEach(func(member string) bool {
+		container.Put(member, struct{}{})
+		s.memory += memberOverhead + int64(len(member))
+		return true
+	})
+	s.intset, s.listpack = nil, nil
+	s.container = container
+	s.encoding = EncodingHashtable
+}
+
+// Len 成员数
+func (s *setEntity) Len() int64 {
+	switch s.encoding {
+	case EncodingIntset:
+		return int64(len(s.intset))
+	case EncodingListpack:
+		return int64(len(s.listpack))
+	default:
+		return int64(s.container.Len())
+	}
+}
+
+// ForEach 遍历成员，fn 返回 false 时停止
+func (s *setEntity) ForEach(fn func(member string) bool) {
+	switch s.encoding {
+	case EncodingIntset:
+		for _, v := range s.intset {
+			if !fn(strconv.FormatInt(v, 10)) {
+				return
+			}
+		}
+	case EncodingListpack:
+		for _, member := range s.listpack {
+			if !fn(member) {
+				return
+			}
+		}
+	default:
+		s.container.ForEach(func(member string, _ struct{}) bool {
+			return fn(member)
+		})
+	}
+}
+
+// RandomMember 随机返回一个成员，集合为空时返回空字符串
+func (s *setEntity) RandomMember() string {
+	switch s.encoding {
+	case EncodingIntset:
+		if len(s.intset) == 0 {
+			return ""
+		}
+		return strconv.FormatInt(s.intset[rand.Intn(len(s.intset))], 10)
+	case EncodingListpack:
+		if len(s.listpack) == 0 {
+			return ""
+		}
+		return s.listpack[rand.Intn(len(s.listpack))]
+	default:
+		member, _ := s.container.RandomKey()
+		return member
+	}
+}
+
+// Scan 按游标遍历成员，返回下一个游标，返回 0 表示遍历结束
+// 紧凑编码与 redis 一致，一次返回全部成员
+func (s *setEntity) Scan(cursor uint64, fn func(member string)) uint64 {
+	if s.encoding != EncodingHashtable {
+		s.ForEach(func(member string) bool {
+			fn(member)
+			return true
+		})
+		return 0
+	}
+
+	return s.container.Scan(cursor, func(member string, _ struct{}) {
+		fn(member)
+	})
+}
+
+// Encoding 当前编码
+func (s *setEntity) Encoding() string {
+	return s.encoding
+}
+
+// MemoryUsage 估算内存占用
+func (s *setEntity) MemoryUsage() int64 {
+	return setOverhead + s.memory
 }
 
 // ToCmd 生成集合添加命令
 func (s *setEntity) ToCmd() [][]byte {
-	args := make([][]byte, 0, 2+len(s.container))
+	args := make([][]byte, 0, 2+s.Len())
 	args = append(args, []byte(def.CmdTypeSAdd), []byte(s.key))
-	for k := range s.container {
-		args = append(args, []byte(k))
-	}
+	s.ForEach(func(member string) bool {
+		args = append(args, []byte(member))
+		return true
+	})
 
 	return args
 }
+
+// SetKey 更新实体对应的 key
+func (s *setEntity) SetKey(key string) {
+	s.key = key
+}
@@ -1 +1,144 @@
 package mset
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strconv"
+	"testing"
+)
+
+// TestSetAgainstMap 随机增删成员，与 map 的结果逐一比对，并校验编码转换的时机
+func TestSetAgainstMap(t *testing.T) {
+	opts := &Options{MaxIntsetEntries: 8, MaxListpackEntries: 16, MaxListpackValue: 4}
+
+	for round := 0; round < 200; round++ {
+		var (
+			rander   = rand.New(rand.NewSource(int64(round)))
+			got      = NewSetEntity("s", opts)
+			want     = make(map[string]struct{})
+			encoding = EncodingIntset
+		)
+		member := func() string {
+			switch rander.Intn(20) {
+			case 0:
+				return "m" + strconv.Itoa(rander.Intn(8))
+			case 1:
+				return "member" + strconv.Itoa(rander.Intn(8))
+			default:
+				return strconv.Itoa(rander.Intn(32) - 8)
+			}
+		}
+
+		for i := 0; i < 64; i++ {
+			m := member()
+			_, exist := want[m]
+			switch op := rander.Intn(3); op {
+			case 0, 1:
+				var added int64
+				if !exist {
+					added = 1
+				}
+				if g := got.Add(m); g != added {
+					t.Fatalf("round %d op %d: add %s => %d, want %d", round, i, m, g, added)
+				}
+				want[m] = struct{}{}
+
+				// 编码只会向更通用的方向转换，整数集合超出数量阈值时直接转换为哈希表
+				_, err := strconv.ParseInt(m, 10, 64)
+				switch {
+				case exist:
+				case encoding == EncodingIntset && err == nil:
+					if len(want) > 8 {
+						encoding = EncodingHashtable
+					}
+				case encoding == EncodingIntset:
+					if len(want) <= 16 && len(m) <= 4 {
+						encoding = EncodingListpack
+					} else {
+						encoding = EncodingHashtable
+					}
+				case encoding == EncodingListpack:
+					if len(want) > 16 || len(m) > 4 {
+						encoding = EncodingHashtable
+					}
+				}
+			case 2:
+				var removed int64
+				if exist {
+					removed = 1
+				}
+				if g := got.Rem(m); g != removed {
+					t.Fatalf("round %d op %d: rem %s => %d, want %d", round, i, m, g, removed)
+				}
+				delete(want, m)
+			}
+
+			if got.Len() != int64(len(want)) {
+				t.Fatalf("round %d op %d: len %d, want %d", round, i, got.Len(), len(want))
+			}
+
+			if got.Encoding() != encoding {
+				t.Fatalf("round %d op %d: encoding %s, want %s", round, i, got.Encoding(), encoding)
+			}
+		}
+
+		for m := range want {
+			if got.Exist(m) != 1 {
+				t.Fatalf("round %d: member %s missing", round, m)
+			}
+		}
+		members := make([]string, 0, len(want))
+		got.ForEach(func(member string) bool {
+			members = append(members, member)
+			return true
+		})
+		if got.Encoding() == EncodingIntset && !sort.SliceIsSorted(members, func(i, j int) bool {
+			a, _ := strconv.Atoi(members[i])
+			b, _ := strconv.Atoi(members[j])
+			return a < b
+		}) {
+			t.Fatalf("round %d: intset members %v not sorted", round, members)
+		}
+		sort.Strings(members)
+		wantMembers := make([]string, 0, len(want))
+		for m := range want {
+			wantMembers = append(wantMembers, m)
+		}
+		sort.Strings(wantMembers)
+		if fmt.Sprint(members) != fmt.Sprint(wantMembers) {
+			t.Fatalf("round %d: members %v, want %v", round, members, wantMembers)
+		}
+	}
+}
+
+// TestIntsetConversion 整数集合超出数量阈值时直接转换为哈希表，加入非整数成员时才转换为紧凑列表
+func TestIntsetConversion(t *testing.T) {
+	opts := &Options{MaxIntsetEntries: 2, MaxListpackEntries: 4, MaxListpackValue: 4}
+	tests := []struct {
+		name    string
+		members []string
+		want    string
+	}{
+		{"within intset", []string{"1", "2"}, EncodingIntset},
+		{"intset overflow", []string{"1", "2", "3"}, EncodingHashtable},
+		{"existing member keeps intset", []string{"1", "2", "2"}, EncodingIntset},
+		{"non integer member", []string{"1", "a"}, EncodingListpack},
+		{"non integer canonical form", []string{"1", "01"}, EncodingListpack},
+		{"long member", []string{"1", "abcde"}, EncodingHashtable},
+		{"too many members for listpack", []string{"1", "2", "3", "4", "a"}, EncodingHashtable},
+		{"listpack overflow", []string{"a", "b", "c", "d", "e"}, EncodingHashtable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewSetEntity("s", opts)
+			for _, m := range tt.members {
+				s.Add(m)
+			}
+			if got := s.Encoding(); got != tt.want {
+				t.Errorf("encoding %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
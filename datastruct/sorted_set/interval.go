@@ -0,0 +1,126 @@
+package msortedset
+
+import (
+	def "github.com/lovelydayss/goredis/interface"
+)
+
+var (
+	errInvalidScoreBorder = def.NewErrReply("ERR min or max is not a float")
+	errInvalidLexBorder   = def.NewErrReply("ERR min or max not valid string range item")
+)
+
+// Interval 成员区间，由分值区间 ScoreInterval 或字典序区间 LexInterval 实现
+type Interval interface {
+	aboveMin(n *skiplistNode) bool // 节点不低于区间下界
+	belowMax(n *skiplistNode) bool // 节点不高于区间上界
+	empty() bool                   // 区间是否必然为空
+}
+
+// contains 节点是否落在区间内
+func contains(interval Interval, n *skiplistNode) bool {
+	return interval.aboveMin(n) && interval.belowMax(n)
+}
+
+// ScoreBorder 分值边界，Exclude 为 true 时为开区间边界
+type ScoreBorder struct {
+	Value   float64
+	Exclude bool
+}
+
+// ParseScoreBorder 解析分值边界，以 ( 开头时为开区间边界，支持 -inf 与 +inf
+func ParseScoreBorder(raw []byte) (ScoreBorder, error) {
+	var border ScoreBorder
+	if len(raw) > 0 && raw[0] == '(' {
+		border.Exclude = true
+		raw = raw[1:]
+	}
+
+	score, err := ParseScore(raw)
+	if err != nil {
+		return ScoreBorder{}, errInvalidScoreBorder
+	}
+	border.Value = score
+	return border, nil
+}
+
+// ScoreInterval 分值区间
+type ScoreInterval struct {
+	Min ScoreBorder
+	Max ScoreBorder
+}
+
+func (i *ScoreInterval) aboveMin(n *skiplistNode) bool {
+	return n.score > i.Min.Value || (n.score == i.Min.Value && !i.Min.Exclude)
+}
+
+func (i *ScoreInterval) belowMax(n *skiplistNode) bool {
+	return n.score < i.Max.Value || (n.score == i.Max.Value && !i.Max.Exclude)
+}
+
+func (i *ScoreInterval) empty() bool {
+	return i.Min.Value > i.Max.Value || (i.Min.Value == i.Max.Value && (i.Min.Exclude || i.Max.Exclude))
+}
+
+// LexBorder 字典序边界，对应 redis 的 [member、(member、- 与 +
+// Inf 为 -1 时表示负无穷 -，为 1 时表示正无穷 +
+type LexBorder struct {
+	Value   string
+	Exclude bool
+	Inf     int
+}
+
+// ParseLexBorder 解析字典序边界
+func ParseLexBorder(raw []byte) (LexBorder, error) {
+	switch {
+	case len(raw) == 1 && raw[0] == '-':
+		return LexBorder{Inf: -1}, nil
+	case len(raw) == 1 && raw[0] == '+':
+		return LexBorder{Inf: 1}, nil
+	case len(raw) > 0 && raw[0] == '[':
+		return LexBorder{Value: string(raw[1:])}, nil
+	case len(raw) > 0 && raw[0] == '(':
+		return LexBorder{Value: string(raw[1:]), Exclude: true}, nil
+	default:
+		return LexBorder{}, errInvalidLexBorder
+	}
+}
+
+// compare 比较成员与边界，成员小于、等于、大于边界时分别返回 -1、0、1
+func (b *LexBorder) compare(member string) int {
+	switch {
+	case b.Inf != 0:
+		return -b.Inf
+	case member < b.Value:
+		return -1
+	case member > b.Value:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// LexInterval 字典序区间，仅在成员分值全部相同时有意义，与 redis 一致
+type LexInterval struct {
+	Min LexBorder
+	Max LexBorder
+}
+
+func (i *LexInterval) aboveMin(n *skiplistNode) bool {
+	c := i.Min.compare(n.member)
+	return c > 0 || (c == 0 && !i.Min.Exclude)
+}
+
+func (i *LexInterval) belowMax(n *skiplistNode) bool {
+	c := i.Max.compare(n.member)
+	return c < 0 || (c == 0 && !i.Max.Exclude)
+}
+
+func (i *LexInterval) empty() bool {
+	if i.Min.Inf == 1 || i.Max.Inf == -1 {
+		return true
+	}
+	if i.Min.Inf == -1 || i.Max.Inf == 1 {
+		return false
+	}
+	return i.Min.Value > i.Max.Value || (i.Min.Value == i.Max.Value && (i.Min.Exclude || i.Max.Exclude))
+}
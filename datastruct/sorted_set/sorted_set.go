@@ -5,175 +5,497 @@ import (
 	"math/rand"
 	"strconv"
 
+	mdict "github.com/lovelydayss/goredis/datastruct/dict"
 	def "github.com/lovelydayss/goredis/interface"
 	"github.com/lovelydayss/goredis/lib"
 )
 
 // SortedSet 排序集合接口定义
+// 成员按 (score, member) 排序，分值相同的成员按字典序排列，排名从 0 开始
 type SortedSet interface {
-	Add(score int64, member string)
+	Add(score float64, member string) int64
 	Rem(member string) int64
-	Range(score1, score2 int64) []string
+	Range(min, max float64) []string
+	Score(member string) (float64, bool)
+	Min() (member string, score float64, ok bool)
+	Len() int64
+	Rank(member string) (int64, bool)
+	RangeByRank(start, stop int64, reverse bool) []Element
+	RangeBy(interval Interval, offset, count int64, reverse bool) []Element
+	Count(interval Interval) int64
+	RemRangeByRank(start, stop int64) int64
+	RemRangeBy(interval Interval) int64
+	Scan(cursor uint64, fn func(member string, score float64)) uint64
 	def.CmdAdapter
+	def.MemoryAdapter
 }
 
-// Skipnode 跳跃表节点结构体定义
-type Skipnode struct {
-	score   int64
-	members map[string]struct{}
-	nexts   []*Skipnode
+// Element 成员及其分值
+type Element struct {
+	Member string
+	Score  float64
 }
 
-// NewSkipnode 创建跳跃表节点
-func NewSkipnode(score, height int64) *Skipnode {
-	return &Skipnode{
-		score:   score,
-		members: make(map[string]struct{}),
-		nexts:   make([]*Skipnode, height),
+const (
+	skiplistOverhead = 96 // 跳表实体结构自身的内存开销
+	memberOverhead   = 48 // 每个成员在 memberToScore 中的开销
+	nodeOverhead     = 64 // 每个节点结构的开销
+	levelOverhead    = 16 // 节点每一层指针及跨度的开销
+)
+
+const (
+	maxLevel    = 32   // 跳表最大层数
+	levelFactor = 0.25 // 节点层数逐层增长的概率
+)
+
+// ParseScore 解析分值，支持 -inf 与 +inf，拒绝 nan
+func ParseScore(raw []byte) (float64, error) {
+	score, err := strconv.ParseFloat(string(raw), 64)
+	if err != nil || math.IsNaN(score) {
+		return 0, def.NewErrReply("ERR value is not a valid float")
 	}
+	return score, nil
+}
+
+// FormatScore 与 redis 一致格式化分值，无穷大表示为 inf 与 -inf
+func FormatScore(score float64) string {
+	switch {
+	case math.IsInf(score, 1):
+		return "inf"
+	case math.IsInf(score, -1):
+		return "-inf"
+	default:
+		return strconv.FormatFloat(score, 'g', -1, 64)
+	}
+}
+
+// skiplistLevel 节点在某一层的指针，span 为该层指针跨越的节点数，用于计算排名
+type skiplistLevel struct {
+	forward *skiplistNode
+	span    int64
+}
+
+// skiplistNode 跳表节点，每个成员对应一个节点
+type skiplistNode struct {
+	member   string
+	score    float64
+	backward *skiplistNode
+	levels   []skiplistLevel
+}
+
+// before 节点是否排在 (score, member) 之前
+func (n *skiplistNode) before(score float64, member string) bool {
+	return n.score < score || (n.score == score && n.member < member)
+}
+
+// next 升序的下一个节点
+func (n *skiplistNode) next() *skiplistNode {
+	return n.levels[0].forward
+}
+
+// prev 升序的上一个节点
+func (n *skiplistNode) prev() *skiplistNode {
+	return n.backward
+}
+
+// after 节点是否排在 (score, member) 之后
+func (n *skiplistNode) after(score float64, member string) bool {
+	return n.score > score || (n.score == score && n.member > member)
 }
 
 // skiplist 跳跃表结构体定义
 type skiplist struct {
 	key           string
-	scoreToNode   map[int64]*Skipnode
-	memberToScore map[string]int64
-	head          *Skipnode
+	memberToScore *mdict.Dict[float64]
+	head          *skiplistNode
+	tail          *skiplistNode
+	level         int
+	length        int64
 	rander        *rand.Rand
+	memory        int64 // 成员及节点占用内存，随增删实时维护
 }
 
 // NewSkiplist 创建跳跃表
 func NewSkiplist(key string) SortedSet {
 	return &skiplist{
 		key:           key,
-		memberToScore: make(map[string]int64),
-		scoreToNode:   make(map[int64]*Skipnode),
-		head:          NewSkipnode(0, 0),
+		memberToScore: mdict.New[float64](),
+		head:          &skiplistNode{levels: make([]skiplistLevel, maxLevel)},
+		level:         1,
 		rander:        rand.New((rand.NewSource(lib.TimeNow().UnixNano()))),
 	}
 }
 
-func (s *skiplist) Add(score int64, member string) {
-	// 之前存在，需要删除
-	oldScore, ok := s.memberToScore[member]
+// Add 添加成员或更新成员的分值，返回新增的成员数
+func (s *skiplist) Add(score float64, member string) int64 {
+	// 之前存在，分值变化时先删除再重新插入
+	oldScore, ok := s.memberToScore.Get(member)
 	if ok {
 		if oldScore == score {
-			return
+			return 0
 		}
-		s.rem(oldScore, member)
+		s.delete(oldScore, member)
+		s.insert(score, member)
+		s.memberToScore.Put(member, score)
+		return 0
 	}
 
-	s.memberToScore[member] = score
-	node, ok := s.scoreToNode[score]
-	if ok {
-		node.members[member] = struct{}{}
-		return
+	s.insert(score, member)
+	s.memberToScore.Put(member, score)
+	s.memory += memberOverhead + int64(len(member))
+	return 1
+}
+
+// Rem 删除成员，返回删除的成员数
+func (s *skiplist) Rem(member string) int64 {
+	score, ok := s.memberToScore.Get(member)
+	if !ok {
+		return 0
 	}
 
-	// 新插入，roll 出高度
-	height := s.roll()
-	for int64(len(s.head.nexts)) < height+1 {
-		s.head.nexts = append(s.head.nexts, nil)
+	s.delete(score, member)
+	s.memberToScore.Delete(member)
+	s.memory -= memberOverhead + int64(len(member))
+	return 1
+}
+
+// Range 获取分值在闭区间 [min, max] 内的成员，按 (score, member) 升序排列
+func (s *skiplist) Range(min, max float64) []string {
+	elems := s.RangeBy(&ScoreInterval{Min: ScoreBorder{Value: min}, Max: ScoreBorder{Value: max}}, 0, -1, false)
+	res := make([]string, 0, len(elems))
+	for _, elem := range elems {
+		res = append(res, elem.Member)
 	}
+	return res
+}
+
+// Score 获取成员的分值
+func (s *skiplist) Score(member string) (float64, bool) {
+	return s.memberToScore.Get(member)
+}
 
-	inserted := NewSkipnode(score, height+1)
-	inserted.members[member] = struct{}{}
-	s.scoreToNode[score] = inserted
+// Min 获取排在最前的成员
+func (s *skiplist) Min() (string, float64, bool) {
+	node := s.head.levels[0].forward
+	if node == nil {
+		return "", 0, false
+	}
+	return node.member, node.score, true
+}
 
+// Len 成员数
+func (s *skiplist) Len() int64 {
+	return s.length
+}
+
+// Rank 获取成员按升序的排名
+func (s *skiplist) Rank(member string) (int64, bool) {
+	score, ok := s.memberToScore.Get(member)
+	if !ok {
+		return 0, false
+	}
+
+	// 沿途累加跨度，停在成员对应的节点上时即得到从 1 开始的排名
+	var rank int64
 	move := s.head
-	for i := height; i >= 0; i-- {
-		for move.nexts[i] != nil && move.nexts[i].score < score {
-			move = move.nexts[i]
-			continue
+	for i := s.level - 1; i >= 0; i-- {
+		for move.levels[i].forward != nil && !move.levels[i].forward.after(score, member) {
+			rank += move.levels[i].span
+			move = move.levels[i].forward
 		}
+		if move != s.head && move.member == member {
+			return rank - 1, true
+		}
+	}
+	return 0, false
+}
 
-		inserted.nexts[i] = move.nexts[i]
-		move.nexts[i] = inserted
+// RangeByRank 获取排名在闭区间 [start, stop] 内的成员，调用方保证 0 <= start <= stop < Len
+// reverse 为 true 时按降序计算排名
+func (s *skiplist) RangeByRank(start, stop int64, reverse bool) []Element {
+	res := make([]Element, 0, stop-start+1)
+	if reverse {
+		for node := s.byRank(s.length - start); node != nil && len(res) < cap(res); node = node.backward {
+			res = append(res, Element{Member: node.member, Score: node.score})
+		}
+		return res
+	}
+
+	for node := s.byRank(start + 1); node != nil && len(res) < cap(res); node = node.levels[0].forward {
+		res = append(res, Element{Member: node.member, Score: node.score})
 	}
+	return res
 }
 
-func (s *skiplist) Rem(member string) int64 {
-	// 之前存在，需要删除
-	score, ok := s.memberToScore[member]
-	if !ok {
+// RangeBy 获取区间内的成员，跳过前 offset 个成员后至多返回 count 个，count 为负数时不限制数量
+// reverse 为 true 时从区间的上界开始降序返回
+func (s *skiplist) RangeBy(interval Interval, offset, count int64, reverse bool) []Element {
+	res := []Element{}
+	if offset < 0 {
+		return res
+	}
+
+	node, next := s.firstIn(interval), (*skiplistNode).next
+	if reverse {
+		node, next = s.lastIn(interval), (*skiplistNode).prev
+	}
+
+	for ; node != nil && offset > 0; offset-- {
+		node = next(node)
+	}
+	for ; node != nil && count != 0 && contains(interval, node); count-- {
+		res = append(res, Element{Member: node.member, Score: node.score})
+		node = next(node)
+	}
+	return res
+}
+
+// Count 区间内的成员数，由区间首尾节点的排名相减得到
+func (s *skiplist) Count(interval Interval) int64 {
+	first := s.firstIn(interval)
+	if first == nil {
 		return 0
 	}
-	s.rem(score, member)
-	return 1
+	last := s.lastIn(interval)
+	firstRank, _ := s.Rank(first.member)
+	lastRank, _ := s.Rank(last.member)
+	return lastRank - firstRank + 1
 }
 
-// [score1,score2]
-func (s *skiplist) Range(score1, score2 int64) []string {
-	if score2 == -1 {
-		score2 = math.MaxInt64
+// RemRangeByRank 删除排名在闭区间 [start, stop] 内的成员，调用方保证 0 <= start <= stop < Len
+func (s *skiplist) RemRangeByRank(start, stop int64) int64 {
+	var (
+		update    [maxLevel]*skiplistNode
+		traversed int64
+	)
+	move := s.head
+	for i := s.level - 1; i >= 0; i-- {
+		for move.levels[i].forward != nil && traversed+move.levels[i].span <= start {
+			traversed += move.levels[i].span
+			move = move.levels[i].forward
+		}
+		update[i] = move
 	}
 
-	if score1 > score2 {
-		return []string{}
+	remain := stop - start + 1
+	return s.remFrom(move.levels[0].forward, &update, func(*skiplistNode) bool {
+		remain--
+		return remain >= 0
+	})
+}
+
+// RemRangeBy 删除区间内的成员，返回删除的成员数
+func (s *skiplist) RemRangeBy(interval Interval) int64 {
+	if interval.empty() {
+		return 0
 	}
 
+	var update [maxLevel]*skiplistNode
 	move := s.head
-	for i := len(s.head.nexts) - 1; i >= 0; i-- {
-		for move.nexts[i] != nil && move.nexts[i].score < score1 {
-			move = move.nexts[i]
+	for i := s.level - 1; i >= 0; i-- {
+		for move.levels[i].forward != nil && !interval.aboveMin(move.levels[i].forward) {
+			move = move.levels[i].forward
 		}
+		update[i] = move
 	}
+	return s.remFrom(move.levels[0].forward, &update, interval.belowMax)
+}
 
-	// 来到了 level0 层，move.nexts[i] 如果存在，就是首个 >= score1 的元素
-	if len(move.nexts) == 0 || move.nexts[0] == nil {
-		return []string{}
+// remFrom 从 node 开始依次删除成员，直到 in 返回 false，返回删除的成员数
+// update 为每一层排在 node 之前的节点，删除过程中保持不变
+func (s *skiplist) remFrom(node *skiplistNode, update *[maxLevel]*skiplistNode, in func(n *skiplistNode) bool) int64 {
+	var remed int64
+	for node != nil && in(node) {
+		next := node.next()
+		s.unlink(node, update)
+		s.memberToScore.Delete(node.member)
+		s.memory -= memberOverhead + int64(len(node.member))
+		remed++
+		node = next
+	}
+	return remed
+}
+
+// firstIn 区间内排在最前的节点，不存在时返回 nil
+func (s *skiplist) firstIn(interval Interval) *skiplistNode {
+	if interval.empty() {
+		return nil
 	}
 
-	res := []string{}
-	for move.nexts[0] != nil && move.nexts[0].score >= score1 && move.nexts[0].score <= score2 {
-		for member := range move.nexts[0].members {
-			res = append(res, member)
+	move := s.head
+	for i := s.level - 1; i >= 0; i-- {
+		for move.levels[i].forward != nil && !interval.aboveMin(move.levels[i].forward) {
+			move = move.levels[i].forward
 		}
-		move = move.nexts[0]
 	}
-	return res
+
+	node := move.levels[0].forward
+	if node == nil || !interval.belowMax(node) {
+		return nil
+	}
+	return node
+}
+
+// lastIn 区间内排在最后的节点，不存在时返回 nil
+func (s *skiplist) lastIn(interval Interval) *skiplistNode {
+	if interval.empty() {
+		return nil
+	}
+
+	move := s.head
+	for i := s.level - 1; i >= 0; i-- {
+		for move.levels[i].forward != nil && interval.belowMax(move.levels[i].forward) {
+			move = move.levels[i].forward
+		}
+	}
+
+	if move == s.head || !interval.aboveMin(move) {
+		return nil
+	}
+	return move
+}
+
+// byRank 获取从 1 开始排名为 rank 的节点，不存在时返回 nil
+func (s *skiplist) byRank(rank int64) *skiplistNode {
+	var traversed int64
+	move := s.head
+	for i := s.level - 1; i >= 0; i-- {
+		for move.levels[i].forward != nil && traversed+move.levels[i].span <= rank {
+			traversed += move.levels[i].span
+			move = move.levels[i].forward
+		}
+		if traversed == rank && move != s.head {
+			return move
+		}
+	}
+	return nil
 }
 
-func (s *skiplist) roll() int64 {
-	var level int64
-	for s.rander.Intn(2) > 0 {
+// roll 随机生成节点层数，逐层以 levelFactor 的概率增长
+func (s *skiplist) roll() int {
+	level := 1
+	for level < maxLevel && s.rander.Float64() < levelFactor {
 		level++
 	}
 	return level
 }
 
-func (s *skiplist) rem(score int64, member string) {
-	delete(s.memberToScore, member)
-	skipnode := s.scoreToNode[score]
+// insert 插入节点，调用方保证成员不存在
+func (s *skiplist) insert(score float64, member string) {
+	var (
+		update [maxLevel]*skiplistNode
+		rank   [maxLevel]int64 // 每一层 update 节点的排名
+	)
+	move := s.head
+	for i := s.level - 1; i >= 0; i-- {
+		if i < s.level-1 {
+			rank[i] = rank[i+1]
+		}
+		for move.levels[i].forward != nil && move.levels[i].forward.before(score, member) {
+			rank[i] += move.levels[i].span
+			move = move.levels[i].forward
+		}
+		update[i] = move
+	}
 
-	delete(skipnode.members, member)
-	if len(skipnode.members) > 0 {
-		return
+	// 新插入，roll 出高度，新增的层由头节点直接跨越到末尾
+	level := s.roll()
+	for i := s.level; i < level; i++ {
+		update[i] = s.head
+		update[i].levels[i].span = s.length
 	}
+	if level > s.level {
+		s.level = level
+	}
+
+	node := &skiplistNode{member: member, score: score, levels: make([]skiplistLevel, level)}
+	for i := 0; i < level; i++ {
+		node.levels[i].forward = update[i].levels[i].forward
+		update[i].levels[i].forward = node
 
-	delete(s.scoreToNode, score)
+		node.levels[i].span = update[i].levels[i].span - (rank[0] - rank[i])
+		update[i].levels[i].span = rank[0] - rank[i] + 1
+	}
+
+	// 更高的层跨越了新节点
+	for i := level; i < s.level; i++ {
+		update[i].levels[i].span++
+	}
+
+	if update[0] != s.head {
+		node.backward = update[0]
+	}
+	if node.levels[0].forward != nil {
+		node.levels[0].forward.backward = node
+	} else {
+		s.tail = node
+	}
+	s.length++
+	s.memory += nodeOverhead + levelOverhead*int64(level)
+}
+
+// delete 删除 (score, member) 对应的节点
+func (s *skiplist) delete(score float64, member string) {
+	var update [maxLevel]*skiplistNode
 	move := s.head
-	for i := len(s.head.nexts) - 1; i >= 0; i-- {
-		for move.nexts[i] != nil && move.nexts[i].score < score {
-			move = move.nexts[i]
+	for i := s.level - 1; i >= 0; i-- {
+		for move.levels[i].forward != nil && move.levels[i].forward.before(score, member) {
+			move = move.levels[i].forward
 		}
+		update[i] = move
+	}
 
-		if move.nexts[i] == nil || move.nexts[i].score > score {
-			continue
+	node := move.levels[0].forward
+	if node == nil || node.score != score || node.member != member {
+		return
+	}
+	s.unlink(node, &update)
+}
+
+// unlink 摘除节点，update 为每一层排在节点之前的节点
+func (s *skiplist) unlink(node *skiplistNode, update *[maxLevel]*skiplistNode) {
+	for i := 0; i < s.level; i++ {
+		if update[i].levels[i].forward == node {
+			update[i].levels[i].span += node.levels[i].span - 1
+			update[i].levels[i].forward = node.levels[i].forward
+		} else {
+			update[i].levels[i].span--
 		}
+	}
 
-		remed := move.nexts[i]
-		move.nexts[i] = move.nexts[i].nexts[i]
-		remed.nexts[i] = nil
+	if node.levels[0].forward != nil {
+		node.levels[0].forward.backward = node.backward
+	} else {
+		s.tail = node.backward
 	}
+	for s.level > 1 && s.head.levels[s.level-1].forward == nil {
+		s.level--
+	}
+	s.length--
+	s.memory -= nodeOverhead + levelOverhead*int64(len(node.levels))
+}
+
+// Scan 按游标遍历成员，返回下一个游标，返回 0 表示遍历结束
+func (s *skiplist) Scan(cursor uint64, fn func(member string, score float64)) uint64 {
+	return s.memberToScore.Scan(cursor, fn)
 }
 
+// MemoryUsage 估算内存占用
+func (s *skiplist) MemoryUsage() int64 {
+	return skiplistOverhead + s.memory
+}
+
+// ToCmd 生成 zadd 指令，按 (score, member) 升序排列
 func (s *skiplist) ToCmd() [][]byte {
-	args := make([][]byte, 0, 2+2*len(s.memberToScore))
+	args := make([][]byte, 0, 2+2*s.length)
 	args = append(args, []byte(def.CmdTypeZAdd), []byte(s.key))
-	for member, score := range s.memberToScore {
-		scoreStr := strconv.FormatInt(score, 10)
-		args = append(args, []byte(scoreStr), []byte(member))
+	for node := s.head.levels[0].forward; node != nil; node = node.levels[0].forward {
+		args = append(args, []byte(FormatScore(node.score)), []byte(node.member))
 	}
 	return args
 }
+
+// SetKey 更新实体对应的 key
+func (s *skiplist) SetKey(key string) {
+	s.key = key
+}
@@ -1 +1,240 @@
 package msortedset
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"testing"
+)
+
+// sliceZSet 按 (score, member) 排序的切片，用作跳表的对照
+type sliceZSet struct {
+	elems []Element
+}
+
+func (z *sliceZSet) find(member string) int {
+	for i, elem := range z.elems {
+		if elem.Member == member {
+			return i
+		}
+	}
+	return -1
+}
+
+func (z *sliceZSet) Add(score float64, member string) int64 {
+	var added int64 = 1
+	if i := z.find(member); i >= 0 {
+		z.elems = append(z.elems[:i], z.elems[i+1:]...)
+		added = 0
+	}
+	z.elems = append(z.elems, Element{Member: member, Score: score})
+	sort.Slice(z.elems, func(i, j int) bool {
+		a, b := z.elems[i], z.elems[j]
+		return a.Score < b.Score || (a.Score == b.Score && a.Member < b.Member)
+	})
+	return added
+}
+
+func (z *sliceZSet) Rem(member string) int64 {
+	i := z.find(member)
+	if i < 0 {
+		return 0
+	}
+	z.elems = append(z.elems[:i], z.elems[i+1:]...)
+	return 1
+}
+
+func (z *sliceZSet) Len() int64 {
+	return int64(len(z.elems))
+}
+
+func (z *sliceZSet) Rank(member string) (int64, bool) {
+	if i := z.find(member); i >= 0 {
+		return int64(i), true
+	}
+	return 0, false
+}
+
+func (z *sliceZSet) RangeByRank(start, stop int64, reverse bool) []Element {
+	res := []Element{}
+	for i := start; i <= stop; i++ {
+		if reverse {
+			res = append(res, z.elems[int64(len(z.elems))-1-i])
+		} else {
+			res = append(res, z.elems[i])
+		}
+	}
+	return res
+}
+
+// in 区间内的成员，升序排列
+func (z *sliceZSet) in(interval Interval) []Element {
+	res := []Element{}
+	for _, elem := range z.elems {
+		if contains(interval, &skiplistNode{member: elem.Member, score: elem.Score}) {
+			res = append(res, elem)
+		}
+	}
+	return res
+}
+
+func (z *sliceZSet) RangeBy(interval Interval, offset, count int64, reverse bool) []Element {
+	elems := z.in(interval)
+	if reverse {
+		for i, j := 0, len(elems)-1; i < j; i, j = i+1, j-1 {
+			elems[i], elems[j] = elems[j], elems[i]
+		}
+	}
+
+	res := []Element{}
+	for i := offset; i < int64(len(elems)) && count != 0; i, count = i+1, count-1 {
+		res = append(res, elems[i])
+	}
+	return res
+}
+
+func (z *sliceZSet) RemRangeByRank(start, stop int64) int64 {
+	z.elems = append(z.elems[:start], z.elems[stop+1:]...)
+	return stop - start + 1
+}
+
+func (z *sliceZSet) RemRangeBy(interval Interval) int64 {
+	var removed int64
+	for _, elem := range z.in(interval) {
+		removed += z.Rem(elem.Member)
+	}
+	return removed
+}
+
+// TestSortedSetAgainstSlice 随机执行各类操作，与有序切片的结果逐一比对
+// 分值取值范围较小，以覆盖分值相同时按成员字典序排列的情况
+func TestSortedSetAgainstSlice(t *testing.T) {
+	for _, sameScore := range []bool{false, true} {
+		t.Run(fmt.Sprintf("same score %v", sameScore), func(t *testing.T) {
+			testSortedSetAgainstSlice(t, sameScore)
+		})
+	}
+}
+
+// testSortedSetAgainstSlice sameScore 为 true 时所有成员分值相同，用于比对字典序区间
+func testSortedSetAgainstSlice(t *testing.T, sameScore bool) {
+	var (
+		rander = rand.New(rand.NewSource(1))
+		got    = NewSkiplist("z")
+		want   = &sliceZSet{}
+	)
+	member := func() string {
+		return "m" + strconv.Itoa(rander.Intn(64))
+	}
+	score := func() float64 {
+		if sameScore {
+			return 0
+		}
+		return float64(rander.Intn(16)) / 2
+	}
+	rank := func() int64 {
+		return int64(rander.Intn(int(want.Len())))
+	}
+	interval := func() Interval {
+		if sameScore {
+			border := func() LexBorder {
+				switch rander.Intn(8) {
+				case 0:
+					return LexBorder{Inf: -1}
+				case 1:
+					return LexBorder{Inf: 1}
+				default:
+					return LexBorder{Value: member(), Exclude: rander.Intn(2) == 0}
+				}
+			}
+			return &LexInterval{Min: border(), Max: border()}
+		}
+
+		border := func() ScoreBorder {
+			switch rander.Intn(8) {
+			case 0:
+				return ScoreBorder{Value: math.Inf(-1)}
+			case 1:
+				return ScoreBorder{Value: math.Inf(1)}
+			default:
+				return ScoreBorder{Value: score(), Exclude: rander.Intn(2) == 0}
+			}
+		}
+		return &ScoreInterval{Min: border(), Max: border()}
+	}
+
+	for i := 0; i < 20000; i++ {
+		switch op := rander.Intn(10); op {
+		case 0, 1, 2:
+			s, m := score(), member()
+			if g, w := got.Add(s, m), want.Add(s, m); g != w {
+				t.Fatalf("op %d: add %v %s => %d, want %d", i, s, m, g, w)
+			}
+		case 3:
+			m := member()
+			if g, w := got.Rem(m), want.Rem(m); g != w {
+				t.Fatalf("op %d: rem %s => %d, want %d", i, m, g, w)
+			}
+		case 4:
+			m := member()
+			g, gok := got.Rank(m)
+			w, wok := want.Rank(m)
+			if g != w || gok != wok {
+				t.Fatalf("op %d: rank %s => %d %v, want %d %v", i, m, g, gok, w, wok)
+			}
+		case 5:
+			if want.Len() == 0 {
+				continue
+			}
+			start, stop, reverse := rank(), rank(), rander.Intn(2) == 0
+			start, stop = min(start, stop), max(start, stop)
+			assertElements(t, i, got.RangeByRank(start, stop, reverse), want.RangeByRank(start, stop, reverse))
+		case 6:
+			in, reverse := interval(), rander.Intn(2) == 0
+			offset, count := int64(rander.Intn(4)), int64(rander.Intn(8)-1)
+			assertElements(t, i, got.RangeBy(in, offset, count, reverse), want.RangeBy(in, offset, count, reverse))
+		case 7:
+			in := interval()
+			if g, w := got.Count(in), int64(len(want.in(in))); g != w {
+				t.Fatalf("op %d: count => %d, want %d", i, g, w)
+			}
+		case 8:
+			if want.Len() == 0 || rander.Intn(10) != 0 {
+				continue
+			}
+			start, stop := rank(), rank()
+			start, stop = min(start, stop), max(start, stop)
+			if g, w := got.RemRangeByRank(start, stop), want.RemRangeByRank(start, stop); g != w {
+				t.Fatalf("op %d: remrangebyrank %d %d => %d, want %d", i, start, stop, g, w)
+			}
+		case 9:
+			if rander.Intn(10) != 0 {
+				continue
+			}
+			in := interval()
+			if g, w := got.RemRangeBy(in), want.RemRangeBy(in); g != w {
+				t.Fatalf("op %d: remrangeby => %d, want %d", i, g, w)
+			}
+		}
+
+		if got.Len() != want.Len() {
+			t.Fatalf("op %d: len %d, want %d", i, got.Len(), want.Len())
+		}
+	}
+
+	// 逐个校验排名，覆盖所有节点的跨度
+	for i, elem := range want.elems {
+		if r, ok := got.Rank(elem.Member); !ok || r != int64(i) {
+			t.Fatalf("rank %s => %d %v, want %d", elem.Member, r, ok, i)
+		}
+	}
+}
+
+func assertElements(t *testing.T, op int, got, want []Element) {
+	t.Helper()
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("op %d: got %v, want %v", op, got, want)
+	}
+}
@@ -1,29 +1,108 @@
 package mstring
 
-import def "github.com/lovelydayss/goredis/interface"
+import (
+	mbitmap "github.com/lovelydayss/goredis/datastruct/bitmap"
+	def "github.com/lovelydayss/goredis/interface"
+)
 
 // String 字符串类型接口
 type String interface {
 	Bytes() []byte
+	Len() int64
+	Set(value []byte)
+	Append(value []byte) int64
+	GetRange(start, end int64) []byte
+	SetRange(offset int64, value []byte) int64
+	BitMap() mbitmap.BitMap
 	def.CmdAdapter
+	def.MemoryAdapter
 }
 
-// stringEntity 字符串类型实体
+// stringOverhead 字符串实体结构自身的内存开销
+const stringOverhead = 48
+
+// stringEntity 字符串类型实体，以字节切片存储以支持原地修改
 type stringEntity struct {
-	key, str string
+	key string
+	str []byte
 }
 
 // NewString 初始化
 func NewString(key, str string) String {
-	return &stringEntity{key: key, str: str}
+	return &stringEntity{key: key, str: []byte(str)}
 }
 
-// Bytes 字节转换
+// Bytes 字节转换，返回副本，避免回包期间被后续指令原地修改
 func (s *stringEntity) Bytes() []byte {
-	return []byte(s.str)
+	return append([]byte{}, s.str...)
+}
+
+// Len 字符串长度
+func (s *stringEntity) Len() int64 {
+	return int64(len(s.str))
+}
+
+// Set 覆盖写入
+func (s *stringEntity) Set(value []byte) {
+	s.str = append(s.str[:0], value...)
+}
+
+// Append 追加写入，返回追加后的长度
+func (s *stringEntity) Append(value []byte) int64 {
+	s.str = append(s.str, value...)
+	return int64(len(s.str))
+}
+
+// GetRange 获取闭区间 [start, end] 内的子串，支持负数下标
+func (s *stringEntity) GetRange(start, end int64) []byte {
+	size := int64(len(s.str))
+	if start < 0 {
+		start += size
+	}
+	if end < 0 {
+		end += size
+	}
+	if start < 0 {
+		start = 0
+	}
+	if end >= size {
+		end = size - 1
+	}
+	if size == 0 || start > end {
+		return []byte{}
+	}
+	return append([]byte{}, s.str[start:end+1]...)
+}
+
+// SetRange 从 offset 处开始覆盖写入，长度不足时以零字节填充，返回修改后的长度
+func (s *stringEntity) SetRange(offset int64, value []byte) int64 {
+	if len(value) == 0 {
+		return int64(len(s.str))
+	}
+
+	if need := offset + int64(len(value)); need > int64(len(s.str)) {
+		s.str = append(s.str, make([]byte, need-int64(len(s.str)))...)
+	}
+	copy(s.str[offset:], value)
+	return int64(len(s.str))
+}
+
+// BitMap 以位图方式读写，与字符串共享同一份数据，不发生拷贝
+func (s *stringEntity) BitMap() mbitmap.BitMap {
+	return mbitmap.NewBitMap(&s.str)
+}
+
+// MemoryUsage 估算内存占用
+func (s *stringEntity) MemoryUsage() int64 {
+	return stringOverhead + int64(cap(s.str))
 }
 
 // ToCmd 转换为命令
 func (s *stringEntity) ToCmd() [][]byte {
-	return [][]byte{[]byte(def.CmdTypeSet), []byte(s.key), []byte(s.str)}
+	return [][]byte{[]byte(def.CmdTypeSet), []byte(s.key), s.Bytes()}
+}
+
+// SetKey 更新实体对应的 key
+func (s *stringEntity) SetKey(key string) {
+	s.key = key
 }
@@ -9,6 +9,7 @@ import (
 
 	"git.code.oa.com/trpc-go/trpc-go/log"
 	def "github.com/lovelydayss/goredis/interface"
+	"github.com/lovelydayss/goredis/lib/pool"
 )
 
 // Handler 是命令分发的具体实现
@@ -86,9 +87,15 @@ func (h *Handler) Handle(ctx context.Context, conn net.Conn) {
 
 // handle 处理请求
 func (h *Handler) handle(ctx context.Context, conn io.ReadWriter) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
 
 	// 逐个处理 conn 中请求指令-协程并发
 	stream := h.parser.ParseStream(conn)
+	droplets := make(chan *def.Droplet)
+	pool.Submit(func() {
+		h.forward(ctx, cancel, stream, droplets)
+	})
 
 	for {
 		select {
@@ -97,7 +104,7 @@ func (h *Handler) handle(ctx context.Context, conn io.ReadWriter) {
 			return
 
 		// chan 解耦，有指令到达对指令处理
-		case droplet := <-stream:
+		case droplet := <-droplets:
 			if err := h.handleDroplet(ctx, conn, droplet); err != nil {
 				log.Errorf("[handler]conn terminated, err: %s", droplet.Err.Error())
 				return
@@ -106,6 +113,40 @@ func (h *Handler) handle(ctx context.Context, conn io.ReadWriter) {
 	}
 }
 
+// forward 转发解析得到的指令
+// 指令处理期间（如阻塞指令挂起）仍持续读取连接，连接断开时立即取消 ctx，释放挂起的指令
+func (h *Handler) forward(ctx context.Context, cancel context.CancelFunc,
+	stream <-chan *def.Droplet, droplets chan<- *def.Droplet) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case droplet := <-stream:
+			// aof 加载阶段指令不会挂起，结束信号排在最后一笔指令之后投递，保证回放完整
+			if droplet.Terminated() && def.IsLoadingPattern(ctx) {
+				select {
+				case droplets <- droplet:
+				case <-ctx.Done():
+				}
+				return
+			}
+
+			if droplet.Terminated() {
+				log.Errorf("[handler]conn terminated, err: %s", droplet.Err.Error())
+				cancel()
+				return
+			}
+
+			select {
+			case droplets <- droplet:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
 // handleDroplet 处理每一笔指令
 func (h *Handler) handleDroplet(ctx context.Context, conn io.ReadWriter, droplet *def.Droplet) error {
 	if droplet.Terminated() {
@@ -0,0 +1,64 @@
+package handler
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lovelydayss/goredis/datastore"
+	def "github.com/lovelydayss/goredis/interface"
+)
+
+// nopPersister 不做任何持久化
+type nopPersister struct{}
+
+func (nopPersister) Reloader() (io.ReadCloser, error) { return nil, nil }
+
+func (nopPersister) PersistCmd(ctx context.Context, cmd [][]byte) {}
+
+func (nopPersister) Close() {}
+
+// TestCloseReleasesBlocked 关闭时挂起的阻塞指令立即返回错误，不会永久阻塞调用方
+func TestCloseReleasesBlocked(t *testing.T) {
+	tests := []string{
+		"blpop q 0",
+		"brpop a b 0",
+		"blmove src dst left right 0",
+		"blmpop 0 1 q left",
+		"bzpopmin z 0",
+		"bzpopmax a b 0",
+		"bzmpop 0 1 z min",
+	}
+
+	for _, line := range tests {
+		t.Run(line, func(t *testing.T) {
+			executor := datastore.NewDBExecutor(datastore.NewKVStore(nopPersister{}))
+			h, _ := NewHandler(NewDBTrigger(executor), nopPersister{}, nil)
+
+			var cmdLine [][]byte
+			for _, field := range strings.Fields(line) {
+				cmdLine = append(cmdLine, []byte(field))
+			}
+			cmd := &def.Command{
+				Ctx:      context.Background(),
+				Cmd:      def.CmdType(cmdLine[0]),
+				Args:     cmdLine[1:],
+				Receiver: make(chan def.Reply, 1),
+			}
+			// 执行器接收指令后才会处理关闭信号，此时指令已被挂起
+			executor.Entrance() <- cmd
+			h.Close()
+
+			select {
+			case reply := <-cmd.Receiver:
+				if got, want := string(reply.ToBytes()), "-ERR server is shutting down\r\n"; got != want {
+					t.Errorf("%s => %q after close, want %q", line, got, want)
+				}
+			case <-time.After(time.Second):
+				t.Fatalf("%s still blocked after close", line)
+			}
+		})
+	}
+}
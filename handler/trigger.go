@@ -3,6 +3,7 @@ package handler
 import (
 	"context"
 	"fmt"
+	"strings"
 	"sync"
 
 	def "github.com/lovelydayss/goredis/interface"
@@ -22,12 +23,12 @@ func NewDBTrigger(executor def.Executor) def.DB {
 
 // Do 执行实际指令转换
 func (d *DBTrigger) Do(ctx context.Context, cmdLine [][]byte) def.Reply {
-	if len(cmdLine) < 2 {
+	if len(cmdLine) < 1 {
 		return def.NewErrReply(fmt.Sprintf("invalid cmd line: %v", cmdLine))
 	}
 
-	// 获取格式化指令类型名称
-	cmdType := def.CmdType(cmdLine[0])
+	// 获取格式化指令类型名称，指令名称不区分大小写
+	cmdType := def.CmdType(strings.ToLower(string(cmdLine[0])))
 	if !d.executor.ValidCommand(cmdType) {
 		return def.NewErrReply(fmt.Sprintf("unknown cmd '%s'", cmdLine[0]))
 	}
@@ -37,14 +38,19 @@ func (d *DBTrigger) Do(ctx context.Context, cmdLine [][]byte) def.Reply {
 		Ctx:      ctx,
 		Cmd:      cmdType,
 		Args:     cmdLine[1:],
-		Receiver: make(chan def.Reply),
+		Receiver: make(chan def.Reply, 1), // 连接断开后执行器的回复无人接收，带缓冲避免阻塞执行器
 	}
 
 	// 投递给到 executor，实现从多连接并发到单个协程依次处理请求
 	d.executor.Entrance() <- &cmd
 
-	// 监听 chan，直到接收到返回的 reply
-	return <-cmd.Receiver
+	// 监听 chan，直到接收到返回的 reply 或连接断开
+	select {
+	case reply := <-cmd.Receiver:
+		return reply
+	case <-ctx.Done():
+		return def.NewErrReply(fmt.Sprintf("ERR connection closed: %s", ctx.Err()))
+	}
 }
 
 // Close 关闭触发器
@@ -7,44 +7,206 @@ import (
 
 const (
 
+	// server
+	CmdTypeInfo   CmdType = "info"
+	CmdTypeMemory CmdType = "memory"
+
+	// key
+	CmdTypeDel      CmdType = "del"
+	CmdTypeExists   CmdType = "exists"
+	CmdTypeType     CmdType = "type"
+	CmdTypeUnlink   CmdType = "unlink"
+	CmdTypeRename   CmdType = "rename"
+	CmdTypeRenameNX CmdType = "renamenx"
+	CmdTypeScan     CmdType = "scan"
+	CmdTypeKeys     CmdType = "keys"
+	CmdTypeObject   CmdType = "object"
+
 	// 设置过期时间
 	CmdTypeExpire   CmdType = "expire"
 	CmdTypeExpireAt CmdType = "expireat"
 
+	CmdTypePExpire   CmdType = "pexpire"
+	CmdTypePExpireAt CmdType = "pexpireat"
+
+	// 查询、移除过期时间
+	CmdTypeTTL         CmdType = "ttl"
+	CmdTypePTTL        CmdType = "pttl"
+	CmdTypeExpireTime  CmdType = "expiretime"
+	CmdTypePExpireTime CmdType = "pexpiretime"
+	CmdTypePersist     CmdType = "persist"
+
 	// string
-	CmdTypeGet  CmdType = "get"
-	CmdTypeSet  CmdType = "set"
-	CmdTypeMGet CmdType = "mget"
-	CmdTypeMSet CmdType = "mset"
+	CmdTypeGet         CmdType = "get"
+	CmdTypeSet         CmdType = "set"
+	CmdTypeMGet        CmdType = "mget"
+	CmdTypeMSet        CmdType = "mset"
+	CmdTypeMSetNX      CmdType = "msetnx"
+	CmdTypeSetNX       CmdType = "setnx"
+	CmdTypeSetEX       CmdType = "setex"
+	CmdTypePSetEX      CmdType = "psetex"
+	CmdTypeGetSet      CmdType = "getset"
+	CmdTypeGetDel      CmdType = "getdel"
+	CmdTypeGetEX       CmdType = "getex"
+	CmdTypeIncr        CmdType = "incr"
+	CmdTypeDecr        CmdType = "decr"
+	CmdTypeIncrBy      CmdType = "incrby"
+	CmdTypeDecrBy      CmdType = "decrby"
+	CmdTypeIncrByFloat CmdType = "incrbyfloat"
+	CmdTypeAppend      CmdType = "append"
+	CmdTypeStrLen      CmdType = "strlen"
+	CmdTypeGetRange    CmdType = "getrange"
+	CmdTypeSetRange    CmdType = "setrange"
 
 	// list
-	CmdTypeLPush  CmdType = "lpush"
-	CmdTypeLPop   CmdType = "lpop"
-	CmdTypeRPush  CmdType = "rpush"
-	CmdTypeRPop   CmdType = "rpop"
-	CmdTypeLRange CmdType = "lrange"
+	CmdTypeLPush     CmdType = "lpush"
+	CmdTypeLPop      CmdType = "lpop"
+	CmdTypeRPush     CmdType = "rpush"
+	CmdTypeRPop      CmdType = "rpop"
+	CmdTypeLRange    CmdType = "lrange"
+	CmdTypeLLen      CmdType = "llen"
+	CmdTypeLIndex    CmdType = "lindex"
+	CmdTypeLSet      CmdType = "lset"
+	CmdTypeLInsert   CmdType = "linsert"
+	CmdTypeLRem      CmdType = "lrem"
+	CmdTypeLTrim     CmdType = "ltrim"
+	CmdTypeLPos      CmdType = "lpos"
+	CmdTypeLMove     CmdType = "lmove"
+	CmdTypeRPopLPush CmdType = "rpoplpush"
+	CmdTypeBLPop     CmdType = "blpop"
+	CmdTypeBRPop     CmdType = "brpop"
+	CmdTypeBLMove    CmdType = "blmove"
+	CmdTypeLMPop     CmdType = "lmpop"
+	CmdTypeBLMPop    CmdType = "blmpop"
 
 	// hash
-	CmdTypeHSet CmdType = "hset"
-	CmdTypeHGet CmdType = "hget"
-	CmdTypeHDel CmdType = "hdel"
+	CmdTypeHSet         CmdType = "hset"
+	CmdTypeHGet         CmdType = "hget"
+	CmdTypeHDel         CmdType = "hdel"
+	CmdTypeHScan        CmdType = "hscan"
+	CmdTypeHSetNX       CmdType = "hsetnx"
+	CmdTypeHMGet        CmdType = "hmget"
+	CmdTypeHExists      CmdType = "hexists"
+	CmdTypeHLen         CmdType = "hlen"
+	CmdTypeHStrLen      CmdType = "hstrlen"
+	CmdTypeHGetAll      CmdType = "hgetall"
+	CmdTypeHKeys        CmdType = "hkeys"
+	CmdTypeHVals        CmdType = "hvals"
+	CmdTypeHIncrBy      CmdType = "hincrby"
+	CmdTypeHIncrByFloat CmdType = "hincrbyfloat"
+	CmdTypeHRandField   CmdType = "hrandfield"
+	CmdTypeHExpire      CmdType = "hexpire"
+	CmdTypeHPExpire     CmdType = "hpexpire"
+	CmdTypeHExpireAt    CmdType = "hexpireat"
+	CmdTypeHPExpireAt   CmdType = "hpexpireat"
+	CmdTypeHTTL         CmdType = "httl"
+	CmdTypeHPTTL        CmdType = "hpttl"
+	CmdTypeHExpireTime  CmdType = "hexpiretime"
+	CmdTypeHPExpireTime CmdType = "hpexpiretime"
+	CmdTypeHPersist     CmdType = "hpersist"
 
 	// set
-	CmdTypeSAdd      CmdType = "sadd"
-	CmdTypeSIsMember CmdType = "sismember"
-	CmdTypeSRem      CmdType = "srem"
+	CmdTypeSAdd        CmdType = "sadd"
+	CmdTypeSIsMember   CmdType = "sismember"
+	CmdTypeSRem        CmdType = "srem"
+	CmdTypeSScan       CmdType = "sscan"
+	CmdTypeSMIsMember  CmdType = "smismember"
+	CmdTypeSMembers    CmdType = "smembers"
+	CmdTypeSCard       CmdType = "scard"
+	CmdTypeSMove       CmdType = "smove"
+	CmdTypeSPop        CmdType = "spop"
+	CmdTypeSRandMember CmdType = "srandmember"
+	CmdTypeSInter      CmdType = "sinter"
+	CmdTypeSUnion      CmdType = "sunion"
+	CmdTypeSDiff       CmdType = "sdiff"
+	CmdTypeSInterStore CmdType = "sinterstore"
+	CmdTypeSUnionStore CmdType = "sunionstore"
+	CmdTypeSDiffStore  CmdType = "sdiffstore"
+	CmdTypeSInterCard  CmdType = "sintercard"
 
 	// sorted set
-	CmdTypeZAdd          CmdType = "zadd"
-	CmdTypeZRangeByScore CmdType = "zrangebyscore"
-	CmdTypeZRem          CmdType = "zrem"
+	CmdTypeZAdd             CmdType = "zadd"
+	CmdTypeZRangeByScore    CmdType = "zrangebyscore"
+	CmdTypeZRem             CmdType = "zrem"
+	CmdTypeZScan            CmdType = "zscan"
+	CmdTypeZRange           CmdType = "zrange"
+	CmdTypeZCard            CmdType = "zcard"
+	CmdTypeZScore           CmdType = "zscore"
+	CmdTypeZMScore          CmdType = "zmscore"
+	CmdTypeZCount           CmdType = "zcount"
+	CmdTypeZRank            CmdType = "zrank"
+	CmdTypeZRevRank         CmdType = "zrevrank"
+	CmdTypeZRevRangeByScore CmdType = "zrevrangebyscore"
+	CmdTypeZRangeByLex      CmdType = "zrangebylex"
+	CmdTypeZLexCount        CmdType = "zlexcount"
+	CmdTypeZRemRangeByRank  CmdType = "zremrangebyrank"
+	CmdTypeZRemRangeByScore CmdType = "zremrangebyscore"
+	CmdTypeZRemRangeByLex   CmdType = "zremrangebylex"
+	CmdTypeZIncrBy          CmdType = "zincrby"
+	CmdTypeZPopMin          CmdType = "zpopmin"
+	CmdTypeZPopMax          CmdType = "zpopmax"
+	CmdTypeZMPop            CmdType = "zmpop"
+	CmdTypeZUnion           CmdType = "zunion"
+	CmdTypeZInter           CmdType = "zinter"
+	CmdTypeZDiff            CmdType = "zdiff"
+	CmdTypeZUnionStore      CmdType = "zunionstore"
+	CmdTypeZInterStore      CmdType = "zinterstore"
+	CmdTypeZDiffStore       CmdType = "zdiffstore"
+	CmdTypeBZPopMin         CmdType = "bzpopmin"
+	CmdTypeBZPopMax         CmdType = "bzpopmax"
+	CmdTypeBZMPop           CmdType = "bzmpop"
 
 	// bitmap
 	CmdTypeBitmapGet   CmdType = "getbit"
 	CmdTypeBitmapSet   CmdType = "setbit"
 	CmdTypeBitmapCount CmdType = "bitcount"
+	CmdTypeBitmapOp    CmdType = "bitop"
+	CmdTypeBitmapPos   CmdType = "bitpos"
+	CmdTypeBitmapField CmdType = "bitfield"
 )
 
+// denyOOMCmdTypes 可能增加内存占用的指令，内存超出上限时需要先执行淘汰
+var denyOOMCmdTypes = map[CmdType]struct{}{
+	CmdTypeSet:          {},
+	CmdTypeMSet:         {},
+	CmdTypeMSetNX:       {},
+	CmdTypeSetNX:        {},
+	CmdTypeSetEX:        {},
+	CmdTypePSetEX:       {},
+	CmdTypeGetSet:       {},
+	CmdTypeIncr:         {},
+	CmdTypeDecr:         {},
+	CmdTypeIncrBy:       {},
+	CmdTypeDecrBy:       {},
+	CmdTypeIncrByFloat:  {},
+	CmdTypeAppend:       {},
+	CmdTypeSetRange:     {},
+	CmdTypeLPush:        {},
+	CmdTypeRPush:        {},
+	CmdTypeLSet:         {},
+	CmdTypeLInsert:      {},
+	CmdTypeLMove:        {},
+	CmdTypeRPopLPush:    {},
+	CmdTypeBLMove:       {},
+	CmdTypeHSet:         {},
+	CmdTypeHSetNX:       {},
+	CmdTypeHIncrBy:      {},
+	CmdTypeHIncrByFloat: {},
+	CmdTypeSAdd:         {},
+	CmdTypeSMove:        {},
+	CmdTypeSInterStore:  {},
+	CmdTypeSUnionStore:  {},
+	CmdTypeSDiffStore:   {},
+	CmdTypeZAdd:         {},
+	CmdTypeZIncrBy:      {},
+	CmdTypeZUnionStore:  {},
+	CmdTypeZInterStore:  {},
+	CmdTypeZDiffStore:   {},
+	CmdTypeBitmapSet:    {},
+	CmdTypeBitmapOp:     {},
+	CmdTypeBitmapField:  {},
+}
+
 // CmdType 指令类型
 type CmdType string
 
@@ -53,6 +215,12 @@ func (c CmdType) String() string {
 	return strings.ToLower(string(c))
 }
 
+// DenyOOM 判断指令在内存不足时是否需要被拒绝
+func (c CmdType) DenyOOM() bool {
+	_, ok := denyOOMCmdTypes[c]
+	return ok
+}
+
 // Command 指令封装类型
 type Command struct {
 	Ctx      context.Context
@@ -69,4 +237,15 @@ func (c *Command) GetCmd() [][]byte {
 // CmdAdapter 指令执行适配器接口
 type CmdAdapter interface {
 	ToCmd() [][]byte
+	SetKey(key string) // rename 时同步更新实体记录的 key
+}
+
+// MultiCmdAdapter 需要多条指令才能还原的实体，如带字段过期时间的 hash 表，重写 aof 时优先使用
+type MultiCmdAdapter interface {
+	ToCmds() [][][]byte
+}
+
+// MemoryAdapter 内存占用估算接口
+type MemoryAdapter interface {
+	MemoryUsage() int64 // 估算实体占用的内存字节数，要求 O(1) 复杂度
 }
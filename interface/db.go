@@ -23,16 +23,58 @@ type DataStore interface {
 	ForEach(task func(key string, adapter CmdAdapter, expireAt *time.Time))
 
 	ExpirePreprocess(key string)
-	GC() // 定时回收过期 key-value
+	GC() // 定时执行一轮主动过期回收
+
+	Touch()                   // 更新本笔指令访问过的 key 的访问信息
+	FreeMemoryIfNeeded() bool // 内存超出上限时执行淘汰，返回 false 表示内存不足
+	ReadyKeys() []string      // 获取并清空本笔指令写入数据、可能唤醒阻塞指令的 key
+
+	// server
+	Info(*Command) Reply
+	Memory(*Command) Reply
+
+	// key
+	Del(*Command) Reply
+	Exists(*Command) Reply
+	Type(*Command) Reply
+	Unlink(*Command) Reply
+	Rename(*Command) Reply
+	RenameNX(*Command) Reply
+	Scan(*Command) Reply
+	Keys(*Command) Reply
+	Object(*Command) Reply
 
 	Expire(*Command) Reply
 	ExpireAt(*Command) Reply
+	PExpire(*Command) Reply
+	PExpireAt(*Command) Reply
+	TTL(*Command) Reply
+	PTTL(*Command) Reply
+	ExpireTime(*Command) Reply
+	PExpireTime(*Command) Reply
+	Persist(*Command) Reply
 
 	// string
 	Get(*Command) Reply
 	MGet(*Command) Reply
 	Set(*Command) Reply
 	MSet(*Command) Reply
+	MSetNX(*Command) Reply
+	SetNX(*Command) Reply
+	SetEX(*Command) Reply
+	PSetEX(*Command) Reply
+	GetSet(*Command) Reply
+	GetDel(*Command) Reply
+	GetEX(*Command) Reply
+	Incr(*Command) Reply
+	Decr(*Command) Reply
+	IncrBy(*Command) Reply
+	DecrBy(*Command) Reply
+	IncrByFloat(*Command) Reply
+	Append(*Command) Reply
+	StrLen(*Command) Reply
+	GetRange(*Command) Reply
+	SetRange(*Command) Reply
 
 	// list
 	LPush(*Command) Reply
@@ -40,24 +82,103 @@ type DataStore interface {
 	RPush(*Command) Reply
 	RPop(*Command) Reply
 	LRange(*Command) Reply
+	LLen(*Command) Reply
+	LIndex(*Command) Reply
+	LSet(*Command) Reply
+	LInsert(*Command) Reply
+	LRem(*Command) Reply
+	LTrim(*Command) Reply
+	LPos(*Command) Reply
+	LMove(*Command) Reply
+	RPopLPush(*Command) Reply
+	BLPop(*Command) Reply
+	BRPop(*Command) Reply
+	BLMove(*Command) Reply
+	LMPop(*Command) Reply
+	BLMPop(*Command) Reply
 
 	// set
 	SAdd(*Command) Reply
 	SIsMember(*Command) Reply
 	SRem(*Command) Reply
+	SScan(*Command) Reply
+	SMIsMember(*Command) Reply
+	SMembers(*Command) Reply
+	SCard(*Command) Reply
+	SMove(*Command) Reply
+	SPop(*Command) Reply
+	SRandMember(*Command) Reply
+	SInter(*Command) Reply
+	SUnion(*Command) Reply
+	SDiff(*Command) Reply
+	SInterStore(*Command) Reply
+	SUnionStore(*Command) Reply
+	SDiffStore(*Command) Reply
+	SInterCard(*Command) Reply
 
 	// hash
 	HSet(*Command) Reply
 	HGet(*Command) Reply
 	HDel(*Command) Reply
+	HScan(*Command) Reply
+	HSetNX(*Command) Reply
+	HMGet(*Command) Reply
+	HExists(*Command) Reply
+	HLen(*Command) Reply
+	HStrLen(*Command) Reply
+	HGetAll(*Command) Reply
+	HKeys(*Command) Reply
+	HVals(*Command) Reply
+	HIncrBy(*Command) Reply
+	HIncrByFloat(*Command) Reply
+	HRandField(*Command) Reply
+	HExpire(*Command) Reply
+	HPExpire(*Command) Reply
+	HExpireAt(*Command) Reply
+	HPExpireAt(*Command) Reply
+	HTTL(*Command) Reply
+	HPTTL(*Command) Reply
+	HExpireTime(*Command) Reply
+	HPExpireTime(*Command) Reply
+	HPersist(*Command) Reply
 
 	// sorted set
 	ZAdd(*Command) Reply
 	ZRangeByScore(*Command) Reply
 	ZRem(*Command) Reply
+	ZScan(*Command) Reply
+	ZRange(*Command) Reply
+	ZCard(*Command) Reply
+	ZScore(*Command) Reply
+	ZMScore(*Command) Reply
+	ZCount(*Command) Reply
+	ZRank(*Command) Reply
+	ZRevRank(*Command) Reply
+	ZRevRangeByScore(*Command) Reply
+	ZRangeByLex(*Command) Reply
+	ZLexCount(*Command) Reply
+	ZRemRangeByRank(*Command) Reply
+	ZRemRangeByScore(*Command) Reply
+	ZRemRangeByLex(*Command) Reply
+	ZIncrBy(*Command) Reply
+	ZPopMin(*Command) Reply
+	ZPopMax(*Command) Reply
+	ZMPop(*Command) Reply
+	ZUnion(*Command) Reply
+	ZInter(*Command) Reply
+	ZDiff(*Command) Reply
+	ZUnionStore(*Command) Reply
+	ZInterStore(*Command) Reply
+	ZDiffStore(*Command) Reply
+	BZPopMin(*Command) Reply
+	BZPopMax(*Command) Reply
+	BZMPop(*Command) Reply
 
 	// bitmap
 	SetBit(*Command) Reply
 	GetBit(*Command) Reply
 	BitCount(*Command) Reply
+	BitOp(*Command) Reply
+	BitPos(*Command) Reply
+	BitField(*Command) Reply
 }
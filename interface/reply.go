@@ -115,6 +115,10 @@ func (e *ErrReply) ToBytes() []byte {
 	return []byte("-" + e.ErrStr + CRLF)
 }
 
+func (e *ErrReply) Error() string {
+	return e.ErrStr
+}
+
 var (
 	nillReply     = &NillReply{}
 	nillBulkBytes = []byte("$-1\r\n")
@@ -178,6 +182,26 @@ func (m *MultiBulkReply) ToBytes() []byte {
 	return []byte(strBuf.String())
 }
 
+// 嵌套数组类型. 协议为 【*】【arr.length】【CRLF】+ arr.length * 【各元素自身的协议】
+type ArrayReply struct {
+	replies []Reply
+}
+
+func NewArrayReply(replies []Reply) *ArrayReply {
+	return &ArrayReply{
+		replies: replies,
+	}
+}
+
+func (a *ArrayReply) ToBytes() []byte {
+	var strBuf strings.Builder
+	strBuf.WriteString("*" + strconv.Itoa(len(a.replies)) + CRLF)
+	for _, reply := range a.replies {
+		strBuf.Write(reply.ToBytes())
+	}
+	return []byte(strBuf.String())
+}
+
 var emptyMultiBulkBytes = []byte("*0\r\n")
 
 // 空数组类型. 采用单例，协议固定为【*】【0】【CRLF】
@@ -190,3 +214,18 @@ func NewEmptyMultiBulkReply() *EmptyMultiBulkReply {
 func (r *EmptyMultiBulkReply) ToBytes() []byte {
 	return emptyMultiBulkBytes
 }
+
+var nillMultiBulkBytes = []byte("*-1\r\n")
+
+// nil 数组类型，用于区分 key 不存在与空数组. 采用单例，协议固定为【*】【-1】【CRLF】
+type NillMultiBulkReply struct{}
+
+var theNillMultiBulkReply = &NillMultiBulkReply{}
+
+func NewNillMultiBulkReply() *NillMultiBulkReply {
+	return theNillMultiBulkReply
+}
+
+func (r *NillMultiBulkReply) ToBytes() []byte {
+	return nillMultiBulkBytes
+}
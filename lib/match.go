@@ -0,0 +1,109 @@
+package lib
+
+import "strings"
+
+// maxGlobNesting * 的最大递归深度，超过时视为不匹配，防止恶意 pattern 耗尽栈空间
+const maxGlobNesting = 1000
+
+// GlobMatch 判断 str 是否匹配 glob 风格的 pattern，语义与 redis stringmatchlen 一致
+// 支持 * 任意长度、? 单个字符、[abc] [^abc] [a-z] 字符集合以及 \ 转义
+func GlobMatch(pattern, str string) bool {
+	skipLongerMatches := false
+	return globMatch(pattern, str, &skipLongerMatches, 0)
+}
+
+// globMatch 移植自 redis stringmatchlen_impl
+// 某个 * 之后的 pattern 从 str 的任意位置开始都无法匹配时置 skipLongerMatches，
+// 此时之前的 * 匹配更长的子串同样不可能成功，直接结束回溯，避免指数级回溯（CVE-2022-36021）
+func globMatch(pattern, str string, skipLongerMatches *bool, nesting int) bool {
+	if nesting > maxGlobNesting {
+		return false
+	}
+
+	for len(pattern) > 0 && len(str) > 0 {
+		switch pattern[0] {
+		case '*':
+			// 合并连续的 *
+			for len(pattern) > 1 && pattern[1] == '*' {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 1 {
+				return true
+			}
+			for ; len(str) > 0; str = str[1:] {
+				if globMatch(pattern[1:], str, skipLongerMatches, nesting+1) {
+					return true
+				}
+				if *skipLongerMatches {
+					return false
+				}
+			}
+			*skipLongerMatches = true
+			return false
+		case '?':
+			pattern = pattern[1:]
+		case '[':
+			var matched bool
+			matched, pattern = matchClass(pattern[1:], str[0])
+			if !matched {
+				return false
+			}
+		case '\\':
+			if len(pattern) >= 2 {
+				pattern = pattern[1:]
+			}
+			fallthrough
+		default:
+			if pattern[0] != str[0] {
+				return false
+			}
+			pattern = pattern[1:]
+		}
+		str = str[1:]
+	}
+
+	// str 已耗尽时，剩余的 * 只能匹配空串
+	if len(str) == 0 {
+		pattern = strings.TrimLeft(pattern, "*")
+	}
+	return len(pattern) == 0 && len(str) == 0
+}
+
+// matchClass 匹配 [] 字符集合，pattern 以 [ 之后的内容开始
+// 返回是否匹配及 ] 之后剩余的 pattern，缺少 ] 时视为集合延续到 pattern 末尾
+func matchClass(pattern string, c byte) (bool, string) {
+	not := len(pattern) > 0 && pattern[0] == '^'
+	if not {
+		pattern = pattern[1:]
+	}
+
+	var matched bool
+	for len(pattern) > 0 && pattern[0] != ']' {
+		switch {
+		case pattern[0] == '\\' && len(pattern) >= 2:
+			pattern = pattern[1:]
+			if pattern[0] == c {
+				matched = true
+			}
+		case len(pattern) >= 3 && pattern[1] == '-':
+			start, end := pattern[0], pattern[2]
+			if start > end {
+				start, end = end, start
+			}
+			if c >= start && c <= end {
+				matched = true
+			}
+			pattern = pattern[2:]
+		default:
+			if pattern[0] == c {
+				matched = true
+			}
+		}
+		pattern = pattern[1:]
+	}
+
+	if len(pattern) > 0 {
+		pattern = pattern[1:] // 跳过 ]
+	}
+	return matched != not, pattern
+}
@@ -0,0 +1,92 @@
+package lib
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestGlobMatch glob 规则与 redis stringmatchlen 的行为一致
+func TestGlobMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		str     string
+		want    bool
+	}{
+		// 字面量与 ?
+		{"hello", "hello", true},
+		{"hello", "hell", false},
+		{"hello", "hello!", false},
+		{"h?llo", "hallo", true},
+		{"h?llo", "hllo", false},
+		{"", "", true},
+		{"", "a", false},
+
+		// *
+		{"*", "", true},
+		{"*", "anything", true},
+		{"h*llo", "hllo", true},
+		{"h*llo", "heeeello", true},
+		{"h**llo", "hello", true},
+		{"*o", "hello", true},
+		{"*x", "hello", false},
+		{"a*", "a", true},
+		{"a*b*c", "abc", true},
+		{"a*b*c", "aXbYcZ", false},
+		{"a*b*c", "aXbYcZc", true},
+		{"*a*", "bab", true},
+
+		// 字符集合
+		{"h[ae]llo", "hello", true},
+		{"h[ae]llo", "hillo", false},
+		{"h[^e]llo", "hallo", true},
+		{"h[^e]llo", "hello", false},
+		{"h[a-b]llo", "hbllo", true},
+		{"h[b-a]llo", "hbllo", true},
+		{"h[a-b]llo", "hcllo", false},
+		{"h[\\]]llo", "h]llo", true},
+		{"h[a", "ha", true},
+		{"h[", "h", false},
+		{"[", "a", false},
+
+		// 转义
+		{"h\\*llo", "h*llo", true},
+		{"h\\*llo", "hello", false},
+		{"h\\?llo", "h?llo", true},
+		{"a\\", "a\\", true},
+	}
+
+	for _, tt := range tests {
+		if got := GlobMatch(tt.pattern, tt.str); got != tt.want {
+			t.Errorf("GlobMatch(%q, %q) => %v, want %v", tt.pattern, tt.str, got, tt.want)
+		}
+	}
+}
+
+// TestGlobMatchAbusivePatterns 恶意 pattern 不会触发指数级回溯或耗尽栈空间
+func TestGlobMatchAbusivePatterns(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		str     string
+		want    bool
+	}{
+		{"many stars no match", strings.Repeat("a*", 30) + "b", strings.Repeat("a", 100), false},
+		{"many stars match", strings.Repeat("a*", 30) + "b", strings.Repeat("a", 100) + "b", true},
+		{"star question marks", strings.Repeat("*?", 50) + "x", strings.Repeat("y", 200), false},
+		{"nesting limit", strings.Repeat("*a", 2000), strings.Repeat("a", 2000), false},
+		{"within nesting limit", strings.Repeat("*a", 500), strings.Repeat("a", 500), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start := time.Now()
+			if got := GlobMatch(tt.pattern, tt.str); got != tt.want {
+				t.Errorf("GlobMatch => %v, want %v", got, tt.want)
+			}
+			if cost := time.Since(start); cost > time.Second {
+				t.Errorf("GlobMatch took %v", cost)
+			}
+		})
+	}
+}
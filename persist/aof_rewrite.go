@@ -3,12 +3,12 @@ package persist
 import (
 	"io"
 	"os"
+	"strconv"
 	"time"
 
 	"github.com/lovelydayss/goredis/datastore"
 	"github.com/lovelydayss/goredis/handler"
 	def "github.com/lovelydayss/goredis/interface"
-	"github.com/lovelydayss/goredis/lib"
 	"github.com/lovelydayss/goredis/parser"
 )
 
@@ -57,13 +57,19 @@ func (a *aofPersister) doRewrite(tmpFile *os.File, fileSize int64) error {
 
 	// 将 db 数据转为 aof cmd
 	forkedDB.ForEach(func(key string, adapter def.CmdAdapter, expireAt *time.Time) {
-		_, _ = tmpFile.Write(def.NewMultiBulkReply(adapter.ToCmd()).ToBytes())
+		if multi, ok := adapter.(def.MultiCmdAdapter); ok {
+			for _, cmd := range multi.ToCmds() {
+				_, _ = tmpFile.Write(def.NewMultiBulkReply(cmd).ToBytes())
+			}
+		} else {
+			_, _ = tmpFile.Write(def.NewMultiBulkReply(adapter.ToCmd()).ToBytes())
+		}
 
 		if expireAt == nil {
 			return
 		}
 
-		expireCmd := [][]byte{[]byte(def.CmdTypeExpireAt), []byte(key), []byte(lib.TimeSecondFormat(*expireAt))}
+		expireCmd := [][]byte{[]byte(def.CmdTypePExpireAt), []byte(key), []byte(strconv.FormatInt(expireAt.UnixMilli(), 10))}
 		_, _ = tmpFile.Write(def.NewMultiBulkReply(expireCmd).ToBytes())
 	})
 
@@ -85,6 +91,8 @@ func (a *aofPersister) forkDB(fileSize int64) (def.DataStore, error) {
 	if err != nil {
 		return nil, err
 	}
+	// 回放完成后关闭临时执行器，避免其主动过期回收与快照遍历并发修改数据
+	defer trigger.Close()
 	if err = h.Start(); err != nil {
 		return nil, err
 	}